@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/service"
+)
+
+// batchStudentsHandler serves POST /students:batch.
+func batchStudentsHandler(studentService service.Students) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.BatchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Printf("Invalid batch request: %v", err)
+			c.JSON(http.StatusBadRequest, model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		atomic := req.Atomic == nil || *req.Atomic
+		log.Printf("Running batch of %d operations (atomic: %t) - Request from %s", len(req.Operations), atomic, c.ClientIP())
+		outcomes, err := tenantStudents(c, studentService).RunBatch(c.Request.Context(), req.Operations, atomic)
+		if err != nil {
+			log.Printf("Failed to run batch: %v", err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		results := make([]model.BatchResult, len(outcomes))
+		succeeded := 0
+		for i, outcome := range outcomes {
+			results[i] = model.BatchResult{
+				Index:   i,
+				Op:      outcome.Op,
+				ID:      outcome.ID,
+				Success: outcome.Err == nil,
+				Data:    outcome.Student,
+			}
+			switch {
+			case outcome.Err != nil:
+				results[i].Status = errorStatus(outcome.Err)
+				results[i].Message = outcome.Err.Error()
+			case outcome.Op == model.BatchCreate:
+				results[i].Status = http.StatusCreated
+				succeeded++
+			default:
+				results[i].Status = http.StatusOK
+				succeeded++
+			}
+		}
+
+		log.Printf("Batch finished: %d of %d operations succeeded", succeeded, len(results))
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: succeeded == len(results),
+			Data:    results,
+		})
+	}
+}
@@ -0,0 +1,16 @@
+// Package cache provides the key/value stores used to cache service reads.
+package cache
+
+import "time"
+
+// Store is a byte-oriented key/value store with per-entry TTLs. Its shape
+// follows the Redis commands GET, SET EX, DEL and INCR so a networked backend
+// can be swapped in for the in-process LRU.
+type Store interface {
+	Get(key string) ([]byte, bool, error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(keys ...string) error
+	// Incr atomically increments the integer stored at key, starting from
+	// zero, and returns the new value. Counters never expire.
+	Incr(key string) (int64, error)
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Fake is an unbounded in-memory Store for tests. It ignores TTLs and can be
+// told to fail, to check that callers degrade gracefully.
+type Fake struct {
+	mu      sync.Mutex
+	data    map[string][]byte
+	Err     error
+	Gets    int
+	Sets    int
+	Deletes int
+}
+
+func NewFake() *Fake {
+	return &Fake{data: make(map[string][]byte)}
+}
+
+func (f *Fake) Get(key string) ([]byte, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Gets++
+	if f.Err != nil {
+		return nil, false, f.Err
+	}
+	value, ok := f.data[key]
+	return value, ok, nil
+}
+
+func (f *Fake) Set(key string, value []byte, _ time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Sets++
+	if f.Err != nil {
+		return f.Err
+	}
+	f.data[key] = value
+	return nil
+}
+
+func (f *Fake) Delete(keys ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Deletes++
+	if f.Err != nil {
+		return f.Err
+	}
+	for _, key := range keys {
+		delete(f.data, key)
+	}
+	return nil
+}
+
+func (f *Fake) Incr(key string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return 0, f.Err
+	}
+	n, _ := strconv.ParseInt(string(f.data[key]), 10, 64)
+	n++
+	f.data[key] = []byte(strconv.FormatInt(n, 10))
+	return n, nil
+}
+
+// Has reports whether key is currently stored.
+func (f *Fake) Has(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.data[key]
+	return ok
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU(2)
+	assert.NoError(t, c.Set("a", []byte("1"), 0))
+	assert.NoError(t, c.Set("b", []byte("2"), 0))
+
+	_, ok, _ := c.Get("a")
+	assert.True(t, ok)
+
+	assert.NoError(t, c.Set("c", []byte("3"), 0))
+	_, ok, _ = c.Get("b")
+	assert.False(t, ok, "b was least recently used")
+	value, ok, _ := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", string(value))
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestLRUExpiry(t *testing.T) {
+	c := NewLRU(10)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	assert.NoError(t, c.Set("a", []byte("1"), time.Minute))
+	_, ok, _ := c.Get("a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Minute)
+	_, ok, _ = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestLRUIncrAndDelete(t *testing.T) {
+	c := NewLRU(10)
+	n, err := c.Incr("counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	n, _ = c.Incr("counter")
+	assert.Equal(t, int64(2), n)
+
+	assert.NoError(t, c.Delete("counter", "missing"))
+	n, _ = c.Incr("counter")
+	assert.Equal(t, int64(1), n)
+}
@@ -0,0 +1,202 @@
+// Package events fans student change events out to live subscribers.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/one2n/student-api/model"
+)
+
+// Event types.
+const (
+	StudentCreated = "student.created"
+	StudentUpdated = "student.updated"
+	StudentDeleted = "student.deleted"
+	// StudentErased follows a data subject erasure. Consumers should drop
+	// anything they hold about the student; the Broker drops the student
+	// data of its buffered events.
+	StudentErased = "student.erased"
+)
+
+type Event struct {
+	ID        uint64         `json:"id"`
+	Type      string         `json:"type"`
+	TenantID  string         `json:"-"`
+	StudentID string         `json:"student_id"`
+	Student   *model.Student `json:"student,omitempty"`
+	Time      time.Time      `json:"time"`
+	// TraceParent is the W3C traceparent of the request that made the
+	// change, so consumers can continue its trace.
+	TraceParent string `json:"traceparent,omitempty"`
+}
+
+// Publisher receives events after the change they describe is committed.
+type Publisher interface {
+	Publish(event Event)
+}
+
+// Broker assigns event IDs, keeps the most recent events in a bounded buffer
+// for resuming subscribers, and fans every event out to the subscribers of
+// its tenant. Each tenant has its own IDs, buffer and subscribers, so a burst
+// of writes in one school neither evicts another's replay window nor fills
+// its subscribers' queues. A subscriber that falls too far behind is
+// disconnected rather than allowed to block publishers; it can reconnect and
+// resume from its last event ID.
+type Broker struct {
+	mu        sync.Mutex
+	streams   map[string]*stream
+	size      int
+	queueSize int
+}
+
+// stream holds the events and subscribers of one tenant.
+type stream struct {
+	nextID      uint64
+	buffer      []Event
+	start       int
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription is one live listener. Replay holds buffered events newer than
+// the requested ID; Resync is set when that ID has already been evicted, in
+// which case the client should refetch its state.
+type Subscription struct {
+	Events <-chan Event
+	Replay []Event
+	Resync bool
+
+	ch     chan Event
+	broker *Broker
+	stream *stream
+	once   sync.Once
+}
+
+// NewBroker creates a broker that keeps the last bufferSize events of each
+// tenant.
+func NewBroker(bufferSize int) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+	return &Broker{
+		streams:   make(map[string]*stream),
+		size:      bufferSize,
+		queueSize: 64,
+	}
+}
+
+func (b *Broker) stream(tenantID string) *stream {
+	st, ok := b.streams[tenantID]
+	if !ok {
+		st = &stream{nextID: 1, subscribers: make(map[*Subscription]struct{})}
+		b.streams[tenantID] = st
+	}
+	return st
+}
+
+func (b *Broker) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st := b.stream(event.TenantID)
+	event.ID = st.nextID
+	st.nextID++
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	if event.Type == StudentErased {
+		st.scrub(event.StudentID)
+	}
+	if len(st.buffer) < b.size {
+		st.buffer = append(st.buffer, event)
+	} else {
+		st.buffer[st.start] = event
+		st.start = (st.start + 1) % b.size
+	}
+
+	for sub := range st.subscribers {
+		select {
+		case sub.ch <- event:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// scrub drops the student payloads of the buffered events about studentID,
+// so an erased student's data is not replayed to resuming subscribers. The
+// events themselves stay, keeping the IDs contiguous.
+func (st *stream) scrub(studentID string) {
+	for i := range st.buffer {
+		if st.buffer[i].StudentID == studentID {
+			st.buffer[i].Student = nil
+		}
+	}
+}
+
+// Subscribe registers a listener for the events of tenantID after
+// lastEventID. Pass zero to receive only new events.
+func (b *Broker) Subscribe(tenantID string, lastEventID uint64) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st := b.stream(tenantID)
+	ch := make(chan Event, b.queueSize)
+	sub := &Subscription{Events: ch, ch: ch, broker: b, stream: st}
+	last := st.nextID - 1
+	oldest := st.nextID - uint64(len(st.buffer))
+	switch {
+	case lastEventID == 0 || lastEventID == last:
+	case lastEventID > last || lastEventID+1 < oldest:
+		// Either the ID predates the buffer or it comes from before a restart.
+		sub.Resync = true
+	default:
+		for i := 0; i < len(st.buffer); i++ {
+			event := st.buffer[(st.start+i)%len(st.buffer)]
+			if event.ID > lastEventID {
+				sub.Replay = append(sub.Replay, event)
+			}
+		}
+	}
+	st.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Subscribers returns the number of live subscriptions across tenants.
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, st := range b.streams {
+		n += len(st.subscribers)
+	}
+	return n
+}
+
+// Close disconnects every subscriber of every tenant, as when the server
+// shuts down; they can resume from their last event ID elsewhere. Publishing
+// and subscribing still work afterwards.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, st := range b.streams {
+		for sub := range st.subscribers {
+			b.drop(sub)
+		}
+	}
+}
+
+// Close stops the subscription and closes its Events channel.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.drop(s)
+}
+
+func (b *Broker) drop(sub *Subscription) {
+	sub.once.Do(func() {
+		delete(sub.stream.subscribers, sub)
+		close(sub.ch)
+	})
+}
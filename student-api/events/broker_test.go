@@ -0,0 +1,151 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/one2n/student-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrokerFanOut(t *testing.T) {
+	b := NewBroker(10)
+	first := b.Subscribe("", 0)
+	second := b.Subscribe("", 0)
+	assert.Equal(t, 2, b.Subscribers())
+
+	b.Publish(Event{Type: StudentCreated, StudentID: "a"})
+
+	for _, sub := range []*Subscription{first, second} {
+		event := <-sub.Events
+		assert.Equal(t, uint64(1), event.ID)
+		assert.Equal(t, "a", event.StudentID)
+		assert.False(t, event.Time.IsZero())
+	}
+
+	first.Close()
+	first.Close()
+	assert.Equal(t, 1, b.Subscribers())
+	_, open := <-first.Events
+	assert.False(t, open)
+}
+
+func TestBrokerResume(t *testing.T) {
+	b := NewBroker(3)
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		b.Publish(Event{Type: StudentUpdated, StudentID: id})
+	}
+
+	tests := []struct {
+		name       string
+		lastID     uint64
+		wantReplay []string
+		wantResync bool
+	}{
+		{name: "new subscriber", lastID: 0},
+		{name: "up to date", lastID: 5},
+		{name: "inside buffer", lastID: 3, wantReplay: []string{"d", "e"}},
+		{name: "oldest buffered", lastID: 2, wantReplay: []string{"c", "d", "e"}},
+		{name: "evicted", lastID: 1, wantResync: true},
+		{name: "from before a restart", lastID: 42, wantResync: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := b.Subscribe("", tt.lastID)
+			defer sub.Close()
+
+			var replay []string
+			for _, event := range sub.Replay {
+				replay = append(replay, event.StudentID)
+			}
+			assert.Equal(t, tt.wantReplay, replay)
+			assert.Equal(t, tt.wantResync, sub.Resync)
+		})
+	}
+}
+
+func TestBrokerDropsSlowSubscribers(t *testing.T) {
+	b := NewBroker(10)
+	b.queueSize = 2
+	slow := b.Subscribe("", 0)
+
+	for i := 0; i < 3; i++ {
+		b.Publish(Event{Type: StudentCreated})
+	}
+
+	assert.Equal(t, 0, b.Subscribers())
+	received := 0
+	for range slow.Events {
+		received++
+	}
+	assert.Equal(t, 2, received)
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker(10)
+	north := b.Subscribe("north", 0)
+	south := b.Subscribe("south", 0)
+
+	b.Close()
+	assert.Equal(t, 0, b.Subscribers())
+	for _, sub := range []*Subscription{north, south} {
+		_, open := <-sub.Events
+		assert.False(t, open)
+	}
+	north.Close()
+
+	b.Publish(Event{Type: StudentCreated, TenantID: "north"})
+	resumed := b.Subscribe("north", 0)
+	defer resumed.Close()
+	assert.Equal(t, 1, b.Subscribers())
+}
+
+func TestBrokerTenants(t *testing.T) {
+	b := NewBroker(2)
+	b.queueSize = 2
+	north := b.Subscribe("north", 0)
+	defer north.Close()
+	south := b.Subscribe("south", 0)
+
+	b.Publish(Event{Type: StudentCreated, TenantID: "north", StudentID: "n1"})
+	b.Publish(Event{Type: StudentUpdated, TenantID: "north", StudentID: "n1"})
+	for i := 0; i < 5; i++ {
+		b.Publish(Event{Type: StudentCreated, TenantID: "south"})
+	}
+
+	assert.Equal(t, 1, b.Subscribers(), "the south subscriber fell behind, the north one did not")
+	for _, want := range []uint64{1, 2} {
+		event := <-north.Events
+		assert.Equal(t, want, event.ID, "each tenant numbers its own events")
+		assert.Equal(t, "north", event.TenantID)
+	}
+	for range south.Events {
+	}
+
+	// South's burst did not evict north's replay window.
+	resumed := b.Subscribe("north", 1)
+	defer resumed.Close()
+	assert.False(t, resumed.Resync)
+	if assert.Len(t, resumed.Replay, 1) {
+		assert.Equal(t, StudentUpdated, resumed.Replay[0].Type)
+	}
+}
+
+func TestBrokerScrubsErasedStudents(t *testing.T) {
+	b := NewBroker(10)
+	b.Publish(Event{Type: StudentDeleted, StudentID: "z"})
+	b.Publish(Event{Type: StudentCreated, StudentID: "a", Student: &model.Student{ID: "a", Email: "a@example.com"}})
+	b.Publish(Event{Type: StudentCreated, StudentID: "b", Student: &model.Student{ID: "b", Email: "b@example.com"}})
+	b.Publish(Event{Type: StudentErased, TenantID: "north", StudentID: "b"})
+	b.Publish(Event{Type: StudentErased, StudentID: "a"})
+
+	sub := b.Subscribe("", 1)
+	defer sub.Close()
+	if assert.Len(t, sub.Replay, 3) {
+		assert.Equal(t, "a", sub.Replay[0].StudentID)
+		assert.Nil(t, sub.Replay[0].Student, "the erased student's data is not replayed")
+		assert.Equal(t, "b", sub.Replay[1].StudentID)
+		assert.NotNil(t, sub.Replay[1].Student, "another tenant's erasure leaves this one alone")
+		assert.Equal(t, StudentErased, sub.Replay[2].Type)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/service"
+)
+
+func registerFileRoutes(v1 *gin.RouterGroup, studentService service.Students) {
+	files := v1.Group("/students/:id/files")
+
+	// Uploads are streamed part by part rather than parsed up front, so a
+	// large file goes straight to storage instead of memory or temp files.
+	files.POST("", func(c *gin.Context) {
+		id := c.Param("id")
+		log.Printf("Uploading file for student %s - Request from %s", id, c.ClientIP())
+		reader, err := c.Request.MultipartReader()
+		if err != nil {
+			log.Printf("Invalid upload: %v", err)
+			c.JSON(http.StatusBadRequest, model.StudentResponse{
+				Success: false,
+				Message: "expected a multipart/form-data body",
+			})
+			return
+		}
+
+		kind := c.DefaultQuery("kind", model.FileKindDocument)
+		var uploaded *model.StudentFile
+		for uploaded == nil {
+			part, err := reader.NextPart()
+			if errors.Is(err, io.EOF) {
+				c.JSON(http.StatusBadRequest, model.StudentResponse{
+					Success: false,
+					Message: `missing "file" field`,
+				})
+				return
+			} else if err != nil {
+				log.Printf("Invalid upload: %v", err)
+				c.JSON(http.StatusBadRequest, model.StudentResponse{
+					Success: false,
+					Message: err.Error(),
+				})
+				return
+			}
+
+			switch part.FormName() {
+			case "kind":
+				value, _ := io.ReadAll(io.LimitReader(part, 64))
+				kind = string(value)
+			case "file":
+				uploaded, err = tenantStudents(c, studentService).UploadFile(c.Request.Context(), id, kind, part.FileName(), part)
+				if err != nil {
+					log.Printf("Failed to upload file for student %s: %v", id, err)
+					c.JSON(errorStatus(err), model.StudentResponse{
+						Success: false,
+						Message: err.Error(),
+					})
+					return
+				}
+			}
+			part.Close()
+		}
+
+		log.Printf("Successfully uploaded %s %s for student %s", uploaded.Kind, uploaded.ID, id)
+		c.JSON(http.StatusCreated, model.StudentResponse{
+			Success: true,
+			Data:    uploaded,
+		})
+	})
+
+	files.GET("", func(c *gin.Context) {
+		id := c.Param("id")
+		log.Printf("Fetching files of student %s - Request from %s", id, c.ClientIP())
+		list, err := tenantStudents(c, studentService).ListFiles(c.Request.Context(), id)
+		if err != nil {
+			log.Printf("Failed to fetch files of student %s: %v", id, err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Data:    list,
+		})
+	})
+
+	files.GET("/:fileId", serveFile(studentService, false))
+	files.GET("/:fileId/thumbnail", serveFile(studentService, true))
+
+	files.DELETE("/:fileId", func(c *gin.Context) {
+		id, fileID := c.Param("id"), c.Param("fileId")
+		log.Printf("Deleting file %s of student %s - Request from %s", fileID, id, c.ClientIP())
+		if err := tenantStudents(c, studentService).DeleteFile(c.Request.Context(), id, fileID); err != nil {
+			log.Printf("Failed to delete file %s of student %s: %v", fileID, id, err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Message: "File deleted successfully",
+		})
+	})
+}
+
+// serveFile streams a stored file, or its thumbnail. http.ServeContent takes
+// care of Range, If-Range and conditional requests.
+func serveFile(studentService service.Students, thumbnail bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, fileID := c.Param("id"), c.Param("fileId")
+		file, content, err := tenantStudents(c, studentService).OpenFile(c.Request.Context(), id, fileID, thumbnail)
+		if err != nil {
+			log.Printf("Failed to open file %s of student %s: %v", fileID, id, err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		defer content.Close()
+
+		contentType, etag := file.ContentType, `"`+file.SHA256+`"`
+		if thumbnail {
+			contentType, etag = "image/jpeg", `"`+file.SHA256+`-thumb"`
+		}
+		c.Header("Content-Type", contentType)
+		c.Header("ETag", etag)
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": file.Filename}))
+		http.ServeContent(c.Writer, c.Request, file.Filename, file.CreatedAt, content)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/middleware"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/operations"
+	"github.com/one2n/student-api/pii"
+	"github.com/one2n/student-api/service"
+)
+
+// registerGDPRRoutes adds the data subject endpoints. Both deal in the full,
+// unmasked record, so they are limited to admins. Erasing a student also
+// drops the idempotent responses in idempotency that mention them and, when
+// ops is set, the results of the tenant's roster exports.
+func registerGDPRRoutes(v1 *gin.RouterGroup, studentService service.Students, idempotency middleware.IdempotencyStore, ops *operations.Manager) {
+	student := v1.Group("/students/:id", middleware.RequireRole(pii.RoleAdmin))
+
+	student.GET("/export", func(c *gin.Context) {
+		id := c.Param("id")
+		log.Printf("Exporting student %s - Request from %s", id, c.ClientIP())
+		export, err := tenantStudents(c, studentService).ExportStudent(c.Request.Context(), id)
+		if err != nil {
+			log.Printf("Failed to export student %s: %v", id, err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.Header("Content-Disposition", `attachment; filename="student-`+id+`.json"`)
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Data:    export,
+		})
+	})
+
+	student.POST("/erase", func(c *gin.Context) {
+		id := c.Param("id")
+		log.Printf("Erasing student %s - Request from %s", id, c.ClientIP())
+		tombstone, err := tenantStudents(c, studentService).EraseStudent(c.Request.Context(), id)
+		if err != nil {
+			log.Printf("Failed to erase student %s: %v", id, err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		// A retried erasure finds the tombstone and purges again, so a
+		// failure here is reported for the client to retry.
+		if _, err := idempotency.PurgeMentioning(id); err != nil {
+			log.Printf("Failed to purge idempotent responses of student %s: %v", id, err)
+			c.JSON(http.StatusInternalServerError, model.StudentResponse{
+				Success: false,
+				Message: "Failed to erase stored responses",
+			})
+			return
+		}
+		if ops != nil {
+			if _, err := ops.DiscardResults(c.Request.Context(), requestTenantID(c), model.OperationExport); err != nil {
+				log.Printf("Failed to discard the exports holding student %s: %v", id, err)
+				c.JSON(http.StatusInternalServerError, model.StudentResponse{
+					Success: false,
+					Message: "Failed to erase exports",
+				})
+				return
+			}
+		}
+
+		log.Printf("Successfully erased student %s", id)
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Message: "Student erased successfully",
+			Data:    tombstone,
+		})
+	})
+}
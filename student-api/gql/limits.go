@@ -0,0 +1,209 @@
+package gql
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Limits bounds how expensive a single query may be. Zero disables a limit.
+// Introspection, under __schema and __type, is bounded separately: tools
+// send deeper queries than applications, but nesting it must still be
+// capped.
+type Limits struct {
+	MaxDepth                   int
+	MaxComplexity              int
+	MaxIntrospectionDepth      int
+	MaxIntrospectionComplexity int
+}
+
+func DefaultLimits() Limits {
+	return Limits{MaxDepth: 8, MaxComplexity: 2000, MaxIntrospectionDepth: 15, MaxIntrospectionComplexity: 50000}
+}
+
+// listMultipliers estimates how many items a list field returns, so the cost
+// of its selection is counted once per item.
+var listMultipliers = map[string]func(args []*ast.Argument, vars map[string]any) int{
+	"students":  pageLimit,
+	"guardians": func([]*ast.Argument, map[string]any) int { return 5 },
+}
+
+// introspectionSizes holds, for each list field of the introspection types,
+// the most items it returns for the schema, so introspection is costed like
+// other lists.
+func introspectionSizes(schema graphql.Schema) map[string]int {
+	sizes := map[string]int{
+		"types":      len(schema.TypeMap()),
+		"directives": len(schema.Directives()),
+	}
+	grow := func(field string, n int) {
+		sizes[field] = max(sizes[field], n)
+	}
+	fields := func(defs graphql.FieldDefinitionMap) {
+		grow("fields", len(defs))
+		for _, def := range defs {
+			grow("args", len(def.Args))
+		}
+	}
+	for _, t := range schema.TypeMap() {
+		switch t := t.(type) {
+		case *graphql.Object:
+			fields(t.Fields())
+			grow("interfaces", len(t.Interfaces()))
+		case *graphql.Interface:
+			fields(t.Fields())
+			grow("possibleTypes", len(schema.PossibleTypes(t)))
+		case *graphql.Union:
+			grow("possibleTypes", len(t.Types()))
+		case *graphql.Enum:
+			grow("enumValues", len(t.Values()))
+		case *graphql.InputObject:
+			grow("inputFields", len(t.Fields()))
+		}
+	}
+	for _, directive := range schema.Directives() {
+		grow("args", len(directive.Args))
+	}
+	return sizes
+}
+
+// checkLimits rejects operations that nest deeper or cost more than allowed.
+// Every field costs one, and the selection under a list field costs once per
+// expected item. Introspection is measured the same way, with sizes giving
+// the length of its lists, against its own limits.
+func checkLimits(doc *ast.Document, vars map[string]any, limits Limits, sizes map[string]int) error {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		w := limitWalker{fragments: fragments, vars: vars, sizes: sizes, visiting: make(map[string]bool)}
+		depth, cost := w.selectionSet(op.SelectionSet)
+		if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+			return fmt.Errorf("query depth %d exceeds the limit of %d", depth, limits.MaxDepth)
+		}
+		if limits.MaxComplexity > 0 && cost > limits.MaxComplexity {
+			return fmt.Errorf("query complexity %d exceeds the limit of %d", cost, limits.MaxComplexity)
+		}
+		if limits.MaxIntrospectionDepth > 0 && w.introspectionDepth > limits.MaxIntrospectionDepth {
+			return fmt.Errorf("introspection depth %d exceeds the limit of %d", w.introspectionDepth, limits.MaxIntrospectionDepth)
+		}
+		if limits.MaxIntrospectionComplexity > 0 && w.introspectionCost > limits.MaxIntrospectionComplexity {
+			return fmt.Errorf("introspection complexity %d exceeds the limit of %d", w.introspectionCost, limits.MaxIntrospectionComplexity)
+		}
+	}
+	return nil
+}
+
+type limitWalker struct {
+	fragments map[string]*ast.FragmentDefinition
+	vars      map[string]any
+	sizes     map[string]int
+	visiting  map[string]bool
+	// introspecting is set under __schema and __type, whose depth and cost
+	// are added up here rather than in the query's.
+	introspecting      bool
+	introspectionDepth int
+	introspectionCost  int
+}
+
+func (w *limitWalker) selectionSet(set *ast.SelectionSet) (depth, cost int) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, selection := range set.Selections {
+		var d, c int
+		switch sel := selection.(type) {
+		case *ast.Field:
+			name := sel.Name.Value
+			if (name == "__schema" || name == "__type") && !w.introspecting {
+				w.introspecting = true
+				childDepth, childCost := w.selectionSet(sel.SelectionSet)
+				w.introspecting = false
+				w.introspectionDepth = max(w.introspectionDepth, childDepth+1)
+				w.introspectionCost += 1 + childCost
+				continue
+			}
+			childDepth, childCost := w.selectionSet(sel.SelectionSet)
+			multiplier := 1
+			if w.introspecting {
+				if size, ok := w.sizes[name]; ok {
+					multiplier = max(size, 1)
+				}
+			} else if estimate, ok := listMultipliers[name]; ok {
+				multiplier = estimate(sel.Arguments, w.vars)
+			}
+			d, c = childDepth+1, 1+multiplier*childCost
+		case *ast.InlineFragment:
+			d, c = w.selectionSet(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			name := sel.Name.Value
+			fragment, ok := w.fragments[name]
+			if !ok || w.visiting[name] {
+				// Unknown or cyclic fragments are reported by validation.
+				continue
+			}
+			w.visiting[name] = true
+			d, c = w.selectionSet(fragment.SelectionSet)
+			delete(w.visiting, name)
+		}
+		depth = max(depth, d)
+		cost += c
+	}
+	return depth, cost
+}
+
+// pageLimit reads page.limit from a students field, falling back to the
+// default page size.
+func pageLimit(args []*ast.Argument, vars map[string]any) int {
+	limit := defaultPageSize
+	for _, arg := range args {
+		if arg.Name.Value != "page" {
+			continue
+		}
+		switch page := arg.Value.(type) {
+		case *ast.ObjectValue:
+			for _, field := range page.Fields {
+				if field.Name.Value == "limit" {
+					limit = intValue(field.Value, vars, limit)
+				}
+			}
+		case *ast.Variable:
+			if value, ok := vars[page.Name.Value].(map[string]any); ok {
+				limit = toInt(value["limit"], limit)
+			}
+		}
+	}
+	return min(max(limit, 1), maxPageSize)
+}
+
+func intValue(value ast.Value, vars map[string]any, fallback int) int {
+	switch v := value.(type) {
+	case *ast.IntValue:
+		if n, err := strconv.Atoi(v.Value); err == nil {
+			return n
+		}
+	case *ast.Variable:
+		return toInt(vars[v.Name.Value], fallback)
+	}
+	return fallback
+}
+
+func toInt(value any, fallback int) int {
+	switch n := value.(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	}
+	return fallback
+}
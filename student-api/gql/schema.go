@@ -0,0 +1,385 @@
+// Package gql serves the student domain over GraphQL. Resolvers go through
+// service.Students, so validation and business rules are shared with REST.
+package gql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/graphql-go/graphql"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/pii"
+	"github.com/one2n/student-api/service"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// requestLoaders batches lookups made while resolving one request.
+type requestLoaders struct {
+	students  *loader[string, *model.Student]
+	guardians *loader[string, []model.StudentGuardian]
+}
+
+type loadersKey struct{}
+
+func newRequestLoaders(ctx context.Context, students service.Students) *requestLoaders {
+	return &requestLoaders{
+		students: newLoader(func(ids []string) (map[string]*model.Student, error) {
+			found, err := students.GetStudentsByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := make(map[string]*model.Student, len(found))
+			for _, student := range found {
+				byID[student.ID] = student
+			}
+			return byID, nil
+		}),
+		guardians: newLoader(func(ids []string) (map[string][]model.StudentGuardian, error) {
+			return students.ListGuardiansForStudents(ctx, ids)
+		}),
+	}
+}
+
+func loadersFrom(ctx context.Context) *requestLoaders {
+	return ctx.Value(loadersKey{}).(*requestLoaders)
+}
+
+type studentsKey struct{}
+
+// studentsFrom returns the student operations of the request's tenant.
+func studentsFrom(ctx context.Context) service.Students {
+	return ctx.Value(studentsKey{}).(service.Students)
+}
+
+// resolveError attaches a machine-readable code to service errors.
+type resolveError struct {
+	err  error
+	code string
+}
+
+func (e *resolveError) Error() string { return e.err.Error() }
+
+func (e *resolveError) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+func wrapError(err error) error {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return &resolveError{err: err, code: "BAD_USER_INPUT"}
+	case errors.Is(err, service.ErrStudentNotFound), errors.Is(err, service.ErrGuardianNotFound):
+		return &resolveError{err: err, code: "NOT_FOUND"}
+	case errors.Is(err, service.ErrEmailExists):
+		return &resolveError{err: err, code: "CONFLICT"}
+	case errors.Is(err, context.Canceled):
+		return &resolveError{err: err, code: "CANCELED"}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &resolveError{err: err, code: "TIMEOUT"}
+	default:
+		return &resolveError{err: err, code: "INTERNAL"}
+	}
+}
+
+var guardianType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Guardian",
+	Fields: graphql.Fields{
+		"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (any, error) {
+			return p.Source.(model.StudentGuardian).GuardianID, nil
+		}},
+		"name": masked(pii.FieldName, guardianField(func(g *model.Guardian) any { return g.Name })),
+		"email": masked(pii.FieldEmail, guardianField(func(g *model.Guardian) any {
+			if g.Email == "" {
+				return nil
+			}
+			return g.Email
+		})),
+		"phone": masked(pii.FieldPhone, guardianField(func(g *model.Guardian) any { return g.Phone })),
+		"address": guardianField(func(g *model.Guardian) any {
+			if g.Address == "" {
+				return nil
+			}
+			return g.Address
+		}),
+		"relationship": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (any, error) {
+			return p.Source.(model.StudentGuardian).Relationship, nil
+		}},
+		"isPrimary": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Resolve: func(p graphql.ResolveParams) (any, error) {
+			return p.Source.(model.StudentGuardian).IsPrimary, nil
+		}},
+	},
+})
+
+func guardianField(get func(*model.Guardian) any) *graphql.Field {
+	return &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+		link := p.Source.(model.StudentGuardian)
+		if link.Guardian == nil {
+			return nil, nil
+		}
+		return get(link.Guardian), nil
+	}}
+}
+
+var studentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Student",
+	Fields: graphql.Fields{
+		"id":    studentField(graphql.NewNonNull(graphql.ID), func(s *model.Student) any { return s.ID }),
+		"name":  masked(pii.FieldName, studentField(graphql.NewNonNull(graphql.String), func(s *model.Student) any { return s.Name })),
+		"email": masked(pii.FieldEmail, studentField(graphql.NewNonNull(graphql.String), func(s *model.Student) any { return s.Email })),
+		"age":   studentField(graphql.NewNonNull(graphql.Int), func(s *model.Student) any { return s.Age }),
+		"grade": studentField(graphql.NewNonNull(graphql.String), func(s *model.Student) any { return s.Grade }),
+		"status": studentField(graphql.NewNonNull(graphql.String), func(s *model.Student) any {
+			return s.Status
+		}),
+		"createdAt": studentField(graphql.NewNonNull(graphql.DateTime), func(s *model.Student) any { return s.CreatedAt }),
+		"updatedAt": studentField(graphql.NewNonNull(graphql.DateTime), func(s *model.Student) any { return s.UpdatedAt }),
+		"guardians": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(guardianType))),
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				thunk := loadersFrom(p.Context).guardians.Load(p.Source.(*model.Student).ID)
+				return func() (any, error) {
+					links, err := thunk()
+					if err != nil {
+						return nil, wrapError(err)
+					}
+					if links == nil {
+						links = []model.StudentGuardian{}
+					}
+					return links, nil
+				}, nil
+			},
+		},
+	},
+})
+
+func studentField(t graphql.Output, get func(*model.Student) any) *graphql.Field {
+	return &graphql.Field{Type: t, Resolve: func(p graphql.ResolveParams) (any, error) {
+		return get(p.Source.(*model.Student)), nil
+	}}
+}
+
+// masked applies the request's masking policy (see pii.WithPolicy) to the
+// string a field resolves to.
+func masked(field string, f *graphql.Field) *graphql.Field {
+	resolve := f.Resolve
+	f.Resolve = func(p graphql.ResolveParams) (any, error) {
+		value, err := resolve(p)
+		if s, ok := value.(string); ok && err == nil {
+			return pii.PolicyFromContext(p.Context).Value(field, s), nil
+		}
+		return value, err
+	}
+	return f
+}
+
+var studentPageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "StudentPage",
+	Fields: graphql.Fields{
+		"items": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(studentType)))},
+		"total": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var studentFilterType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "StudentFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"grade":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"status":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"nameContains": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"minAge":       &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		"maxAge":       &graphql.InputObjectFieldConfig{Type: graphql.Int},
+	},
+})
+
+var pageType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "Page",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"limit":  &graphql.InputObjectFieldConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+		"offset": &graphql.InputObjectFieldConfig{Type: graphql.Int, DefaultValue: 0},
+	},
+})
+
+var guardianInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "GuardianInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"guardianId":   &graphql.InputObjectFieldConfig{Type: graphql.ID},
+		"relationship": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"isPrimary":    &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+		"name":         &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"email":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"phone":        &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"address":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+var studentInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "StudentInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name":      &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"email":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"age":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"grade":     &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"guardians": &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(guardianInputType))},
+	},
+})
+
+// NewSchema builds the GraphQL schema. Resolvers act on the Students stored
+// in the request context by Server.Execute.
+func NewSchema() (graphql.Schema, error) {
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"student": &graphql.Field{
+				Type: studentType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					thunk := loadersFrom(p.Context).students.Load(p.Args["id"].(string))
+					return func() (any, error) {
+						student, err := thunk()
+						if err != nil {
+							return nil, wrapError(err)
+						}
+						if student == nil {
+							return nil, nil
+						}
+						return student, nil
+					}, nil
+				},
+			},
+			"students": &graphql.Field{
+				Type: graphql.NewNonNull(studentPageType),
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: studentFilterType},
+					"page":   &graphql.ArgumentConfig{Type: pageType},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					filterArg, _ := p.Args["filter"].(map[string]any)
+					filter := model.StudentFilter{
+						Grade:        stringArg(filterArg, "grade"),
+						Status:       stringArg(filterArg, "status"),
+						NameContains: stringArg(filterArg, "nameContains"),
+						MinAge:       intArg(filterArg, "minAge", 0),
+						MaxAge:       intArg(filterArg, "maxAge", 0),
+					}
+					pageArg, _ := p.Args["page"].(map[string]any)
+					limit := intArg(pageArg, "limit", defaultPageSize)
+					offset := intArg(pageArg, "offset", 0)
+
+					items, total, err := studentsFrom(p.Context).ListStudents(p.Context, filter, limit, offset)
+					if err != nil {
+						return nil, wrapError(err)
+					}
+					return map[string]any{"items": items, "total": int(total)}, nil
+				},
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createStudent": &graphql.Field{
+				Type: graphql.NewNonNull(studentType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(studentInputType)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					student, err := studentFromInput(p.Args["input"].(map[string]any))
+					if err != nil {
+						return nil, err
+					}
+					created, err := studentsFrom(p.Context).CreateStudent(p.Context, student)
+					if err != nil {
+						return nil, wrapError(err)
+					}
+					return created, nil
+				},
+			},
+			"updateStudent": &graphql.Field{
+				Type: graphql.NewNonNull(studentType),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(studentInputType)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					student, err := studentFromInput(p.Args["input"].(map[string]any))
+					if err != nil {
+						return nil, err
+					}
+					updated, err := studentsFrom(p.Context).UpdateStudent(p.Context, p.Args["id"].(string), student)
+					if err != nil {
+						return nil, wrapError(err)
+					}
+					return updated, nil
+				},
+			},
+			"deleteStudent": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					if err := studentsFrom(p.Context).DeleteStudent(p.Context, p.Args["id"].(string)); err != nil {
+						return nil, wrapError(err)
+					}
+					return true, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+// studentFromInput converts a StudentInput and checks it against the same
+// binding rules the REST endpoints apply.
+func studentFromInput(input map[string]any) (*model.Student, error) {
+	student := &model.Student{
+		Name:  stringArg(input, "name"),
+		Email: stringArg(input, "email"),
+		Age:   intArg(input, "age", 0),
+		Grade: stringArg(input, "grade"),
+	}
+	guardians, _ := input["guardians"].([]any)
+	for _, raw := range guardians {
+		g, _ := raw.(map[string]any)
+		link := model.StudentGuardian{
+			GuardianID:   stringArg(g, "guardianId"),
+			Relationship: stringArg(g, "relationship"),
+			IsPrimary:    g["isPrimary"] == true,
+		}
+		if link.GuardianID == "" {
+			link.Guardian = &model.Guardian{
+				Name:    stringArg(g, "name"),
+				Email:   stringArg(g, "email"),
+				Phone:   stringArg(g, "phone"),
+				Address: stringArg(g, "address"),
+			}
+		}
+		student.Guardians = append(student.Guardians, link)
+	}
+
+	if err := binding.Validator.ValidateStruct(student); err != nil {
+		return nil, &resolveError{err: err, code: "BAD_USER_INPUT"}
+	}
+	return student, nil
+}
+
+func stringArg(args map[string]any, name string) string {
+	value, _ := args[name].(string)
+	return value
+}
+
+func intArg(args map[string]any, name string, fallback int) int {
+	if value, ok := args[name].(int); ok {
+		return value
+	}
+	return fallback
+}
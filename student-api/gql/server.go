@@ -0,0 +1,92 @@
+package gql
+
+import (
+	"context"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/one2n/student-api/service"
+)
+
+// Request is a GraphQL request as sent over HTTP.
+type Request struct {
+	Query         string         `json:"query" form:"query"`
+	OperationName string         `json:"operationName" form:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type Server struct {
+	schema   graphql.Schema
+	students service.Students
+	limits   Limits
+	// sizes holds the lengths of the schema's introspection lists.
+	sizes map[string]int
+}
+
+func NewServer(students service.Students, limits Limits) (*Server, error) {
+	schema, err := NewSchema()
+	if err != nil {
+		return nil, err
+	}
+	return &Server{schema: schema, students: students, limits: limits, sizes: introspectionSizes(schema)}, nil
+}
+
+// Operation returns the type of the operation req would run, such as
+// "query" or "mutation". It returns "" when the query does not parse or names
+// no single operation; Execute reports why.
+func Operation(req Request) string {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return ""
+	}
+	operation := ""
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if req.OperationName == "" && operation != "" {
+			return ""
+		}
+		if req.OperationName == "" || (op.Name != nil && op.Name.Value == req.OperationName) {
+			operation = op.Operation
+		}
+	}
+	return operation
+}
+
+// Execute runs a request on behalf of tenantID. The boolean reports whether
+// execution started; it is false when the request was rejected while parsing,
+// validating or checking limits.
+func (s *Server) Execute(ctx context.Context, tenantID string, req Request) (*graphql.Result, bool) {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}, false
+	}
+
+	validation := graphql.ValidateDocument(&s.schema, doc, nil)
+	if !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}, false
+	}
+	if err := checkLimits(doc, req.Variables, s.limits, s.sizes); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(&resolveError{err: err, code: "QUERY_TOO_COMPLEX"})}, false
+	}
+
+	students := s.students.ForTenant(tenantID)
+	ctx = context.WithValue(ctx, studentsKey{}, students)
+	ctx = context.WithValue(ctx, loadersKey{}, newRequestLoaders(ctx, students))
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	}), true
+}
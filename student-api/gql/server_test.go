@@ -0,0 +1,204 @@
+package gql
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/pii"
+	"github.com/one2n/student-api/service"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// countingStudents counts the batched lookups that reach the service.
+type countingStudents struct {
+	service.Students
+	byIDs, guardians int
+}
+
+// ForTenant keeps counting; the tests only use the default tenant.
+func (c *countingStudents) ForTenant(string) service.Students {
+	return c
+}
+
+func (c *countingStudents) GetStudentsByIDs(ctx context.Context, ids []string) ([]*model.Student, error) {
+	c.byIDs++
+	return c.Students.GetStudentsByIDs(ctx, ids)
+}
+
+func (c *countingStudents) ListGuardiansForStudents(ctx context.Context, ids []string) (map[string][]model.StudentGuardian, error) {
+	c.guardians++
+	return c.Students.ListGuardiansForStudents(ctx, ids)
+}
+
+func setupTestServer(t *testing.T, limits Limits) (*Server, *countingStudents) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	students := &countingStudents{Students: service.NewStudentService(db)}
+	server, err := NewServer(students, limits)
+	assert.NoError(t, err)
+	return server, students
+}
+
+func execute(t *testing.T, server *Server, query string, variables map[string]any) (map[string]any, []map[string]any, bool) {
+	admin, _ := pii.PolicyFor(pii.RoleAdmin)
+	result, executed := server.Execute(pii.WithPolicy(context.Background(), admin), model.DefaultTenantID, Request{Query: query, Variables: variables})
+	raw, err := json.Marshal(result)
+	assert.NoError(t, err)
+	var decoded struct {
+		Data   map[string]any   `json:"data"`
+		Errors []map[string]any `json:"errors"`
+	}
+	assert.NoError(t, json.Unmarshal(raw, &decoded))
+	return decoded.Data, decoded.Errors, executed
+}
+
+func TestQueriesBatchLookups(t *testing.T) {
+	server, students := setupTestServer(t, DefaultLimits())
+
+	var ids []string
+	for _, s := range []*model.Student{
+		{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10"},
+		{Name: "Ben", Email: "ben@example.com", Age: 20, Grade: "10"},
+		{Name: "Cat", Email: "cat@example.com", Age: 12, Grade: "7", Guardians: []model.StudentGuardian{{
+			Relationship: model.RelationshipMother,
+			Guardian:     &model.Guardian{Name: "Cathy", Phone: "+1-555-0100"},
+		}}},
+	} {
+		created, err := students.CreateStudent(context.Background(), s)
+		assert.NoError(t, err)
+		ids = append(ids, created.ID)
+	}
+
+	data, errs, _ := execute(t, server, `
+		query($page: Page) {
+			students(filter: {grade: "10th"}, page: $page) {
+				total
+				items { name guardians { name } }
+			}
+		}`, map[string]any{"page": map[string]any{"limit": 1}})
+	assert.Empty(t, errs)
+	page := data["students"].(map[string]any)
+	assert.Equal(t, float64(2), page["total"])
+	assert.Len(t, page["items"], 1)
+
+	students.guardians = 0
+	data, errs, _ = execute(t, server, `{
+		students { items { name guardians { name relationship isPrimary } } }
+	}`, nil)
+	assert.Empty(t, errs)
+	assert.Len(t, data["students"].(map[string]any)["items"], 3)
+	assert.Equal(t, 1, students.guardians, "guardians of every student load in one batch")
+
+	data, errs, _ = execute(t, server, `query($a: ID!, $b: ID!, $c: ID!) {
+		a: student(id: $a) { name }
+		b: student(id: $b) { name }
+		c: student(id: $c) { name guardians { name } }
+		missing: student(id: "missing") { name }
+	}`, map[string]any{"a": ids[0], "b": ids[1], "c": ids[2]})
+	assert.Empty(t, errs)
+	assert.Equal(t, "Ann", data["a"].(map[string]any)["name"])
+	assert.Equal(t, "Cathy", data["c"].(map[string]any)["guardians"].([]any)[0].(map[string]any)["name"])
+	assert.Nil(t, data["missing"])
+	assert.Equal(t, 1, students.byIDs, "aliased student lookups load in one batch")
+}
+
+func TestMutations(t *testing.T) {
+	server, _ := setupTestServer(t, DefaultLimits())
+
+	data, errs, _ := execute(t, server, `mutation {
+		createStudent(input: {name: "Ann", email: "ann@example.com", age: 20, grade: "Grade 10"}) { id grade status }
+	}`, nil)
+	assert.Empty(t, errs)
+	created := data["createStudent"].(map[string]any)
+	assert.Equal(t, "10", created["grade"])
+	id := created["id"].(string)
+
+	data, errs, _ = execute(t, server, `mutation($id: ID!) {
+		updateStudent(id: $id, input: {name: "Ann B", email: "ann@example.com", age: 21, grade: "11"}) { name age }
+	}`, map[string]any{"id": id})
+	assert.Empty(t, errs)
+	assert.Equal(t, "Ann B", data["updateStudent"].(map[string]any)["name"])
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode string
+	}{
+		{
+			name:     "binding rules apply",
+			query:    `mutation { createStudent(input: {name: "Ben", email: "not-an-email", age: 20, grade: "10"}) { id } }`,
+			wantCode: "BAD_USER_INPUT",
+		},
+		{
+			name:     "service rules apply",
+			query:    `mutation { createStudent(input: {name: "Ben", email: "ben@example.com", age: 20, grade: "A"}) { id } }`,
+			wantCode: "BAD_USER_INPUT",
+		},
+		{
+			name:     "duplicate email",
+			query:    `mutation { createStudent(input: {name: "Ann", email: "ann@example.com", age: 20, grade: "10"}) { id } }`,
+			wantCode: "CONFLICT",
+		},
+		{
+			name:     "delete unknown",
+			query:    `mutation { deleteStudent(id: "missing") }`,
+			wantCode: "NOT_FOUND",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errs, executed := execute(t, server, tt.query, nil)
+			assert.True(t, executed)
+			if assert.Len(t, errs, 1) {
+				assert.Equal(t, tt.wantCode, errs[0]["extensions"].(map[string]any)["code"])
+			}
+		})
+	}
+
+	data, errs, _ = execute(t, server, `mutation($id: ID!) { deleteStudent(id: $id) }`, map[string]any{"id": id})
+	assert.Empty(t, errs)
+	assert.Equal(t, true, data["deleteStudent"])
+}
+
+func TestLimits(t *testing.T) {
+	limits := DefaultLimits()
+	limits.MaxDepth, limits.MaxComplexity = 3, 50
+	server, _ := setupTestServer(t, limits)
+
+	tests := []struct {
+		name         string
+		query        string
+		variables    map[string]any
+		wantExecuted bool
+	}{
+		{name: "within limits", query: `{ students(page: {limit: 5}) { items { name } } }`, wantExecuted: true},
+		{name: "too deep", query: `{ students(page: {limit: 1}) { items { guardians { name } } } }`},
+		{name: "too complex", query: `{ students(page: {limit: 60}) { items { name } } }`},
+		{
+			name:      "too complex through variables",
+			query:     `query($page: Page) { students(page: $page) { items { name } } }`,
+			variables: map[string]any{"page": map[string]any{"limit": 60}},
+		},
+		{
+			name:  "fragments count",
+			query: `{ students(page: {limit: 1}) { ...items } } fragment items on StudentPage { items { guardians { name } } }`,
+		},
+		{name: "introspection has its own limits", query: `{ __schema { types { name fields { name type { name ofType { name } } } } } }`, wantExecuted: true},
+		{name: "tools can introspect", query: testutil.IntrospectionQuery, wantExecuted: true},
+		{name: "introspection too deep", query: `{ __type(name: "Student") { fields { type { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { ofType { name } } } } } } } } } } } } } } } }`},
+		{name: "introspection too complex", query: `{ __schema { types { fields { type { fields { type { fields { type { fields { name } } } } } } } } } }`},
+		{name: "invalid query", query: `{ students { nope } }`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, executed := execute(t, server, tt.query, tt.variables)
+			assert.Equal(t, tt.wantExecuted, executed)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/service"
+)
+
+func registerGradeRoutes(v1 *gin.RouterGroup, studentService service.Students) {
+	v1.GET("/grades", func(c *gin.Context) {
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Data:    studentService.Grades(),
+		})
+	})
+}
+
+// promoteStudentsHandler serves POST /students:promote.
+func promoteStudentsHandler(studentService service.Students) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.PromotionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			log.Printf("Invalid promotion request: %v", err)
+			c.JSON(http.StatusBadRequest, model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		log.Printf("Promoting grade %s (dry run: %t) - Request from %s", req.Grade, req.DryRun, c.ClientIP())
+		batch, err := tenantStudents(c, studentService).PromoteGrade(c.Request.Context(), req.Grade, req.DryRun)
+		if err != nil {
+			log.Printf("Failed to promote grade %s: %v", req.Grade, err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		status := http.StatusCreated
+		if batch.DryRun {
+			status = http.StatusOK
+		}
+		log.Printf("Promotion of grade %s affected %d students", batch.FromGrade, batch.StudentCount)
+		c.JSON(status, model.StudentResponse{
+			Success: true,
+			Data:    batch,
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/one2n/student-api/gql"
+	"github.com/one2n/student-api/middleware"
+)
+
+// graphqlHandler serves GraphQL over POST (JSON body) and GET (query string).
+// GET only runs queries: mutations get 405, since GET requests are neither
+// protected from cross-site forgery nor expected to change anything.
+// Requests rejected before execution get 400; once execution starts the
+// response is 200 with any resolver errors listed under "errors".
+func graphqlHandler(server *gql.Server) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req gql.Request
+		var err error
+		if c.Request.Method == http.MethodGet {
+			err = c.ShouldBindQuery(&req)
+		} else {
+			err = c.ShouldBindJSON(&req)
+		}
+		if err != nil || req.Query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"errors": []gin.H{{"message": "request must contain a query"}}})
+			return
+		}
+
+		if c.Request.Method == http.MethodGet {
+			if op := gql.Operation(req); op != "" && op != ast.OperationTypeQuery {
+				log.Printf("Rejected GraphQL %s over GET from %s", op, c.ClientIP())
+				c.Header("Allow", http.MethodPost)
+				c.JSON(http.StatusMethodNotAllowed, gin.H{"errors": []gin.H{{"message": op + " operations must be sent with POST"}}})
+				return
+			}
+		}
+
+		result, executed := server.Execute(c.Request.Context(), middleware.TenantID(c), req)
+		if !executed {
+			log.Printf("Rejected GraphQL request from %s: %v", c.ClientIP(), result.Errors)
+			c.JSON(http.StatusBadRequest, result)
+			return
+		}
+		if status := middleware.ContextStatus(c.Request.Context().Err()); status != 0 {
+			c.JSON(status, result)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
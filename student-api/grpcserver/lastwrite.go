@@ -0,0 +1,31 @@
+package grpcserver
+
+import (
+	"context"
+
+	"github.com/one2n/student-api/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// lastWriteMetadataKey mirrors the X-Last-Write header of the HTTP API.
+const lastWriteMetadataKey = "x-last-write"
+
+// lastWriteInterceptor reads the client's last write from "x-last-write"
+// metadata into a service.WriteClock for the call, and answers calls that
+// write with the new value in the response headers.
+func lastWriteInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var value string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(lastWriteMetadataKey); len(values) > 0 {
+		value = values[0]
+	}
+	clock := service.ParseWriteClock(value)
+	resp, err := handler(service.WithWriteClock(ctx, clock), req)
+	if clock.Wrote() {
+		if err := grpc.SetHeader(ctx, metadata.Pairs(lastWriteMetadataKey, clock.Header())); err != nil {
+			return nil, err
+		}
+	}
+	return resp, err
+}
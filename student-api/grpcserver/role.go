@@ -0,0 +1,54 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+
+	"github.com/one2n/student-api/pii"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// roleMetadataKey mirrors the X-Role header of the HTTP API.
+const roleMetadataKey = "x-role"
+
+// RoleInterceptor resolves the caller's role from "x-role" metadata through
+// cfg, and stores its masking policy in the context so responses are masked
+// like those of the HTTP API. Unknown roles fail with InvalidArgument, and
+// roles the peer may not claim with PermissionDenied.
+func RoleInterceptor(cfg pii.RoleConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var claimed string
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get(roleMetadataKey); len(values) > 0 {
+			claimed = values[0]
+		}
+		role, err := cfg.Resolve(peerAddr(ctx), claimed)
+		switch {
+		case errors.Is(err, pii.ErrUntrustedRole):
+			return nil, status.Error(codes.PermissionDenied, "role "+claimed+" may not be claimed from this address")
+		case err != nil:
+			return nil, status.Error(codes.InvalidArgument, "unknown role "+claimed)
+		}
+		policy, _ := pii.PolicyFor(role)
+		return handler(pii.WithPolicy(ctx, policy), req)
+	}
+}
+
+// peerAddr returns the address of the caller's connection, or the zero
+// address for transports without one.
+func peerAddr(ctx context.Context) netip.Addr {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return netip.Addr{}
+	}
+	addrPort, err := netip.ParseAddrPort(p.Addr.String())
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addrPort.Addr()
+}
@@ -0,0 +1,170 @@
+// Package grpcserver exposes service.Students over gRPC.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/pii"
+	"github.com/one2n/student-api/proto/studentpb"
+	"github.com/one2n/student-api/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type StudentServer struct {
+	studentpb.UnimplementedStudentServiceServer
+	students service.Students
+}
+
+func NewStudentServer(students service.Students) *StudentServer {
+	return &StudentServer{students: students}
+}
+
+// NewServer builds a gRPC server with the student service, the standard
+// health service and server reflection registered. Student calls act for the
+// tenant resolved through tenants; with a nil resolver every call acts for the
+// default tenant.
+func NewServer(students service.Students, tenants service.TenantResolver, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(tenantInterceptor(tenants), lastWriteInterceptor))
+	s := grpc.NewServer(opts...)
+	studentpb.RegisterStudentServiceServer(s, NewStudentServer(students))
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(studentpb.StudentService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
+
+	reflection.Register(s)
+	return s
+}
+
+func (s *StudentServer) CreateStudent(ctx context.Context, req *studentpb.CreateStudentRequest) (*studentpb.Student, error) {
+	student := &model.Student{
+		Name:  req.GetName(),
+		Email: req.GetEmail(),
+		Age:   int(req.GetAge()),
+		Grade: req.GetGrade(),
+	}
+	if err := binding.Validator.ValidateStruct(student); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	created, err := s.studentsFor(ctx).CreateStudent(ctx, student)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	log.Printf("gRPC: created student with ID: %s", created.ID)
+	return toProto(ctx, created), nil
+}
+
+func (s *StudentServer) GetStudent(ctx context.Context, req *studentpb.GetStudentRequest) (*studentpb.Student, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	student, err := s.studentsFor(ctx).GetStudentByID(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(ctx, student), nil
+}
+
+func (s *StudentServer) ListStudents(ctx context.Context, req *studentpb.ListStudentsRequest) (*studentpb.ListStudentsResponse, error) {
+	offset := 0
+	if token := req.GetPageToken(); token != "" {
+		var err error
+		if offset, err = strconv.Atoi(token); err != nil || offset < 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+	}
+
+	students, total, err := s.studentsFor(ctx).ListStudents(ctx, model.StudentFilter{Grade: req.GetGrade()}, int(req.GetPageSize()), offset)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &studentpb.ListStudentsResponse{TotalSize: total}
+	for _, student := range students {
+		resp.Students = append(resp.Students, toProto(ctx, student))
+	}
+	if next := offset + len(students); len(students) > 0 && int64(next) < total {
+		resp.NextPageToken = strconv.Itoa(next)
+	}
+	return resp, nil
+}
+
+func (s *StudentServer) UpdateStudent(ctx context.Context, req *studentpb.UpdateStudentRequest) (*studentpb.Student, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	student := &model.Student{
+		Name:  req.GetName(),
+		Email: req.GetEmail(),
+		Age:   int(req.GetAge()),
+		Grade: req.GetGrade(),
+	}
+	if err := binding.Validator.ValidateStruct(student); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	updated, err := s.studentsFor(ctx).UpdateStudent(ctx, req.GetId(), student)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(ctx, updated), nil
+}
+
+func (s *StudentServer) DeleteStudent(ctx context.Context, req *studentpb.DeleteStudentRequest) (*emptypb.Empty, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+	if err := s.studentsFor(ctx).DeleteStudent(ctx, req.GetId()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// toStatus maps service errors onto gRPC status codes.
+func toStatus(err error) error {
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrStudentNotFound), errors.Is(err, service.ErrGuardianNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrEmailExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		log.Printf("gRPC: internal error: %v", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+// toProto converts a student, masked by the policy in ctx.
+func toProto(ctx context.Context, student *model.Student) *studentpb.Student {
+	student = pii.PolicyFromContext(ctx).Student(student)
+	return &studentpb.Student{
+		Id:         student.ID,
+		Name:       student.Name,
+		Email:      student.Email,
+		Age:        int32(student.Age),
+		Grade:      student.Grade,
+		Status:     student.Status,
+		CreateTime: timestamppb.New(student.CreatedAt),
+		UpdateTime: timestamppb.New(student.UpdatedAt),
+	}
+}
@@ -0,0 +1,272 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/pii"
+	"github.com/one2n/student-api/proto/studentpb"
+	"github.com/one2n/student-api/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestClient(t *testing.T) *grpc.ClientConn {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	admin := RoleInterceptor(pii.RoleConfig{Default: pii.RoleAdmin})
+	return dialTestServer(t, NewServer(service.NewStudentService(db), nil, grpc.ChainUnaryInterceptor(admin)))
+}
+
+func dialTestServer(t *testing.T, server *grpc.Server) *grpc.ClientConn {
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestStudentService(t *testing.T) {
+	conn := setupTestClient(t)
+	client := studentpb.NewStudentServiceClient(conn)
+	ctx := context.Background()
+
+	created, err := client.CreateStudent(ctx, &studentpb.CreateStudentRequest{
+		Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10th",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "10", created.GetGrade())
+	assert.NotNil(t, created.GetCreateTime())
+
+	_, err = client.CreateStudent(ctx, &studentpb.CreateStudentRequest{Name: "Jane", Email: "jane@doe.com", Age: 21, Grade: "11"})
+	assert.NoError(t, err)
+
+	got, err := client.GetStudent(ctx, &studentpb.GetStudentRequest{Id: created.GetId()})
+	assert.NoError(t, err)
+	assert.Equal(t, "John Doe", got.GetName())
+
+	page, err := client.ListStudents(ctx, &studentpb.ListStudentsRequest{PageSize: 1})
+	assert.NoError(t, err)
+	assert.Len(t, page.GetStudents(), 1)
+	assert.Equal(t, int64(2), page.GetTotalSize())
+	assert.NotEmpty(t, page.GetNextPageToken())
+	page, err = client.ListStudents(ctx, &studentpb.ListStudentsRequest{PageSize: 1, PageToken: page.GetNextPageToken()})
+	assert.NoError(t, err)
+	assert.Len(t, page.GetStudents(), 1)
+	assert.Empty(t, page.GetNextPageToken())
+
+	updated, err := client.UpdateStudent(ctx, &studentpb.UpdateStudentRequest{
+		Id: created.GetId(), Name: "John Updated", Email: "john@doe.com", Age: 21, Grade: "11",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "John Updated", updated.GetName())
+
+	_, err = client.DeleteStudent(ctx, &studentpb.DeleteStudentRequest{Id: created.GetId()})
+	assert.NoError(t, err)
+}
+
+func TestStudentServiceErrorCodes(t *testing.T) {
+	conn := setupTestClient(t)
+	client := studentpb.NewStudentServiceClient(conn)
+	ctx := context.Background()
+
+	_, err := client.CreateStudent(ctx, &studentpb.CreateStudentRequest{Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		call     func() error
+		wantCode codes.Code
+	}{
+		{
+			name: "invalid email",
+			call: func() error {
+				_, err := client.CreateStudent(ctx, &studentpb.CreateStudentRequest{Name: "A", Email: "nope", Age: 20, Grade: "10"})
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "invalid grade",
+			call: func() error {
+				_, err := client.CreateStudent(ctx, &studentpb.CreateStudentRequest{Name: "A", Email: "a@doe.com", Age: 20, Grade: "A"})
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "duplicate email",
+			call: func() error {
+				_, err := client.CreateStudent(ctx, &studentpb.CreateStudentRequest{Name: "A", Email: "john@doe.com", Age: 20, Grade: "10"})
+				return err
+			},
+			wantCode: codes.AlreadyExists,
+		},
+		{
+			name: "missing student",
+			call: func() error {
+				_, err := client.GetStudent(ctx, &studentpb.GetStudentRequest{Id: "missing"})
+				return err
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "missing id",
+			call: func() error {
+				_, err := client.DeleteStudent(ctx, &studentpb.DeleteStudentRequest{})
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "bad page token",
+			call: func() error {
+				_, err := client.ListStudents(ctx, &studentpb.ListStudentsRequest{PageToken: "x"})
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantCode, status.Code(tt.call()))
+		})
+	}
+}
+
+func TestHealthAndReflection(t *testing.T) {
+	conn := setupTestClient(t)
+	ctx := context.Background()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: studentpb.StudentService_ServiceDesc.ServiceName,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	reply, err := stream.Recv()
+	assert.NoError(t, err)
+	var services []string
+	for _, svc := range reply.GetListServicesResponse().GetService() {
+		services = append(services, svc.GetName())
+	}
+	assert.Contains(t, services, "student.v1.StudentService")
+	assert.Contains(t, services, "grpc.health.v1.Health")
+}
+
+func TestStudentServiceTenants(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	tenants := service.NewTenantService(db)
+	north, err := tenants.CreateTenant(context.Background(), &model.Tenant{Name: "North", Slug: "north"})
+	assert.NoError(t, err)
+	client := studentpb.NewStudentServiceClient(dialTestServer(t, NewServer(service.NewStudentService(db), tenants)))
+
+	asNorth := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+north.APIKey)
+	created, err := client.CreateStudent(asNorth, &studentpb.CreateStudentRequest{
+		Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10",
+	})
+	assert.NoError(t, err)
+
+	_, err = client.GetStudent(context.Background(), &studentpb.GetStudentRequest{Id: created.GetId()})
+	assert.Equal(t, codes.NotFound, status.Code(err), "the default tenant cannot see it")
+	_, err = client.GetStudent(asNorth, &studentpb.GetStudentRequest{Id: created.GetId()})
+	assert.NoError(t, err)
+	asNorthBySlug := metadata.AppendToOutgoingContext(context.Background(), "x-tenant-id", "north")
+	_, err = client.GetStudent(asNorthBySlug, &studentpb.GetStudentRequest{Id: created.GetId()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "naming a tenant is not enough to act for it")
+
+	bad := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer sk_unknown")
+	_, err = client.ListStudents(bad, &studentpb.ListStudentsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	mismatch := metadata.AppendToOutgoingContext(asNorth, "x-tenant-id", model.DefaultTenantID)
+	_, err = client.ListStudents(mismatch, &studentpb.ListStudentsRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestStudentServiceRoles(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	students := service.NewStudentService(db)
+	server := NewServer(students, nil, grpc.ChainUnaryInterceptor(RoleInterceptor(pii.RoleConfig{Default: pii.RoleAdmin})))
+	client := studentpb.NewStudentServiceClient(dialTestServer(t, server))
+
+	created, err := client.CreateStudent(context.Background(), &studentpb.CreateStudentRequest{
+		Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "john@doe.com", created.GetEmail())
+
+	asViewer := metadata.AppendToOutgoingContext(context.Background(), "x-role", pii.RoleViewer)
+	found, err := client.GetStudent(asViewer, &studentpb.GetStudentRequest{Id: created.GetId()})
+	assert.NoError(t, err)
+	assert.Equal(t, "J*** D***", found.GetName())
+	assert.Equal(t, "j***@doe.com", found.GetEmail())
+
+	unknown := metadata.AppendToOutgoingContext(context.Background(), "x-role", "owner")
+	_, err = client.ListStudents(unknown, &studentpb.ListStudentsRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// By default callers see masked data and cannot claim more.
+	strict := NewServer(students, nil, grpc.ChainUnaryInterceptor(RoleInterceptor(pii.DefaultRoleConfig())))
+	client = studentpb.NewStudentServiceClient(dialTestServer(t, strict))
+	found, err = client.GetStudent(context.Background(), &studentpb.GetStudentRequest{Id: created.GetId()})
+	assert.NoError(t, err)
+	assert.Equal(t, "j***@doe.com", found.GetEmail())
+	asAdmin := metadata.AppendToOutgoingContext(context.Background(), "x-role", pii.RoleAdmin)
+	_, err = client.GetStudent(asAdmin, &studentpb.GetStudentRequest{Id: created.GetId()})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// A server without the interceptor masks everything too.
+	client = studentpb.NewStudentServiceClient(dialTestServer(t, NewServer(students, nil)))
+	found, err = client.GetStudent(asAdmin, &studentpb.GetStudentRequest{Id: created.GetId()})
+	assert.NoError(t, err)
+	assert.Equal(t, "J*** D***", found.GetName())
+	assert.Equal(t, "j***@doe.com", found.GetEmail())
+}
+
+func TestStudentServiceLastWrite(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	cfg := service.DefaultConfig()
+	cfg.Replicas = service.NewReplicas(nil, time.Minute)
+	client := studentpb.NewStudentServiceClient(dialTestServer(t, NewServer(service.NewStudentServiceWithConfig(db, cfg), nil)))
+
+	var header metadata.MD
+	created, err := client.CreateStudent(context.Background(), &studentpb.CreateStudentRequest{
+		Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10",
+	}, grpc.Header(&header))
+	assert.NoError(t, err)
+	assert.Len(t, header.Get("x-last-write"), 1, "writes tell the client when it last wrote")
+
+	header = nil
+	_, err = client.GetStudent(context.Background(), &studentpb.GetStudentRequest{Id: created.GetId()}, grpc.Header(&header))
+	assert.NoError(t, err)
+	assert.Empty(t, header.Get("x-last-write"))
+}
@@ -0,0 +1,69 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/proto/studentpb"
+	"github.com/one2n/student-api/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tenantMetadataKey mirrors the X-Tenant-ID header of the HTTP API.
+const tenantMetadataKey = "x-tenant-id"
+
+type tenantKey struct{}
+
+// tenantInterceptor resolves the tenant of every StudentService call from the
+// "authorization" (bearer API key) and "x-tenant-id" metadata, with the same
+// rules as the HTTP API. Health and reflection calls are not tenant scoped.
+func tenantInterceptor(tenants service.TenantResolver) grpc.UnaryServerInterceptor {
+	prefix := "/" + studentpb.StudentService_ServiceDesc.ServiceName + "/"
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !strings.HasPrefix(info.FullMethod, prefix) {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		var apiKey, ref string
+		if values := md.Get("authorization"); len(values) > 0 {
+			if token, ok := strings.CutPrefix(values[0], "Bearer "); ok {
+				apiKey = strings.TrimSpace(token)
+			}
+		}
+		if values := md.Get(tenantMetadataKey); len(values) > 0 {
+			ref = values[0]
+		}
+
+		tenant, err := service.ResolveRequestTenant(ctx, tenants, apiKey, ref)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidAPIKey), errors.Is(err, service.ErrAPIKeyRequired):
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			case errors.Is(err, service.ErrTenantMismatch):
+				return nil, status.Error(codes.PermissionDenied, err.Error())
+			case errors.Is(err, service.ErrTenantNotFound):
+				return nil, status.Error(codes.NotFound, err.Error())
+			default:
+				log.Printf("gRPC: failed to resolve tenant: %v", err)
+				return nil, status.Error(codes.Internal, "failed to resolve tenant")
+			}
+		}
+		return handler(context.WithValue(ctx, tenantKey{}, tenant.ID), req)
+	}
+}
+
+// studentsFor returns the student operations of the call's tenant.
+func (s *StudentServer) studentsFor(ctx context.Context) service.Students {
+	tenantID, ok := ctx.Value(tenantKey{}).(string)
+	if !ok {
+		tenantID = model.DefaultTenantID
+	}
+	return s.students.ForTenant(tenantID)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/service"
+)
+
+func registerGuardianRoutes(v1 *gin.RouterGroup, studentService service.Students) {
+	guardians := v1.Group("/students/:id/guardians")
+
+	guardians.GET("", func(c *gin.Context) {
+		id := c.Param("id")
+		log.Printf("Fetching guardians of student %s - Request from %s", id, c.ClientIP())
+		links, err := tenantStudents(c, studentService).ListGuardians(c.Request.Context(), id)
+		if err != nil {
+			log.Printf("Failed to fetch guardians of student %s: %v", id, err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Data:    links,
+		})
+	})
+
+	guardians.POST("", func(c *gin.Context) {
+		id := c.Param("id")
+		log.Printf("Adding guardian to student %s - Request from %s", id, c.ClientIP())
+		var link model.StudentGuardian
+		if err := c.ShouldBindJSON(&link); err != nil {
+			log.Printf("Invalid guardian data: %v", err)
+			c.JSON(http.StatusBadRequest, model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		created, err := tenantStudents(c, studentService).AddGuardian(c.Request.Context(), id, &link)
+		if err != nil {
+			log.Printf("Failed to add guardian to student %s: %v", id, err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		log.Printf("Successfully linked guardian %s to student %s", created.GuardianID, id)
+		c.JSON(http.StatusCreated, model.StudentResponse{
+			Success: true,
+			Data:    created,
+		})
+	})
+
+	guardians.PUT("/:guardianId", func(c *gin.Context) {
+		id, guardianID := c.Param("id"), c.Param("guardianId")
+		log.Printf("Updating guardian %s of student %s - Request from %s", guardianID, id, c.ClientIP())
+		var link model.StudentGuardian
+		if err := c.ShouldBindJSON(&link); err != nil {
+			log.Printf("Invalid guardian data for update: %v", err)
+			c.JSON(http.StatusBadRequest, model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		updated, err := tenantStudents(c, studentService).UpdateGuardian(c.Request.Context(), id, guardianID, &link)
+		if err != nil {
+			log.Printf("Failed to update guardian %s of student %s: %v", guardianID, id, err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Data:    updated,
+		})
+	})
+
+	guardians.DELETE("/:guardianId", func(c *gin.Context) {
+		id, guardianID := c.Param("id"), c.Param("guardianId")
+		log.Printf("Removing guardian %s from student %s - Request from %s", guardianID, id, c.ClientIP())
+		if err := tenantStudents(c, studentService).RemoveGuardian(c.Request.Context(), id, guardianID); err != nil {
+			log.Printf("Failed to remove guardian %s from student %s: %v", guardianID, id, err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Message: "Guardian removed successfully",
+		})
+	})
+}
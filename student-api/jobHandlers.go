@@ -0,0 +1,79 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/scheduler"
+)
+
+const (
+	defaultJobRunsLimit = 20
+	maxJobRunsLimit     = 100
+)
+
+func registerJobRoutes(admin *gin.RouterGroup, jobs *scheduler.Scheduler) {
+	admin.GET("/jobs", func(c *gin.Context) {
+		statuses, err := jobs.Jobs(c.Request.Context())
+		if err != nil {
+			log.Printf("Failed to fetch jobs: %v", err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Data:    statuses,
+		})
+	})
+
+	admin.POST("/jobs/:name/run", func(c *gin.Context) {
+		name := c.Param("name")
+		log.Printf("Triggering job %s - Request from %s", name, c.ClientIP())
+		run, err := jobs.Trigger(c.Request.Context(), name)
+		if err != nil {
+			log.Printf("Failed to trigger job %s: %v", name, err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, model.StudentResponse{
+			Success: true,
+			Data:    run,
+		})
+	})
+
+	admin.GET("/jobs/:name/runs", func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultJobRunsLimit)))
+		if err != nil || limit < 1 || limit > maxJobRunsLimit {
+			c.JSON(http.StatusBadRequest, model.StudentResponse{
+				Success: false,
+				Message: "limit must be between 1 and " + strconv.Itoa(maxJobRunsLimit),
+			})
+			return
+		}
+
+		runs, err := jobs.Runs(c.Request.Context(), c.Param("name"), limit)
+		if err != nil {
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Data:    runs,
+		})
+	})
+}
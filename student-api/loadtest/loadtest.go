@@ -0,0 +1,396 @@
+// Package loadtest drives a mix of student API calls against a running
+// server at a set concurrency and rate, and reports throughput, latency
+// percentiles and errors.
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Operations of the mix.
+const (
+	OpCreate = "create"
+	OpGet    = "get"
+	OpList   = "list"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+var operations = []string{OpCreate, OpGet, OpList, OpUpdate, OpDelete}
+
+// Mix weighs the operations against each other: with create=1 and get=3,
+// one call in four creates a student.
+type Mix map[string]int
+
+func DefaultMix() Mix {
+	return Mix{OpCreate: 20, OpGet: 40, OpList: 10, OpUpdate: 20, OpDelete: 10}
+}
+
+// ParseMix parses a mix written as "create=20,get=40,list=10".
+// Operations left out are not run.
+func ParseMix(s string) (Mix, error) {
+	mix := make(Mix)
+	for _, part := range strings.Split(s, ",") {
+		op, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid mix entry %q: want op=weight", part)
+		}
+		if !validOp(op) {
+			return nil, fmt.Errorf("unknown operation %q", op)
+		}
+		n, err := strconv.Atoi(weight)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid weight %q for %s", weight, op)
+		}
+		mix[op] = n
+	}
+	if mix.total() == 0 {
+		return nil, errors.New("mix has no operation with a weight")
+	}
+	return mix, nil
+}
+
+func validOp(op string) bool {
+	for _, known := range operations {
+		if op == known {
+			return true
+		}
+	}
+	return false
+}
+
+func (m Mix) total() int {
+	total := 0
+	for _, weight := range m {
+		total += weight
+	}
+	return total
+}
+
+// pick draws an operation with probability proportional to its weight.
+func (m Mix) pick(rng *rand.Rand) string {
+	n := rng.IntN(m.total())
+	for _, op := range operations {
+		if n < m[op] {
+			return op
+		}
+		n -= m[op]
+	}
+	return OpCreate
+}
+
+func (m Mix) String() string {
+	var parts []string
+	for _, op := range operations {
+		if m[op] > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", op, m[op]))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+type Config struct {
+	// BaseURL is where the server is, such as http://localhost:8080.
+	BaseURL string
+	Mix     Mix
+	// Concurrency is the number of calls in flight at once.
+	Concurrency int
+	// RPS caps the rate calls are started at. Zero means as fast as
+	// Concurrency allows.
+	RPS float64
+	// Duration bounds the run.
+	Duration time.Duration
+	// Requests, if set, ends the run after that many calls, even before
+	// Duration is up.
+	Requests int
+	// Prefill is the number of students created, and not measured, before
+	// the run so get, update and delete have something to work on.
+	Prefill int
+	// Timeout bounds one call.
+	Timeout time.Duration
+	// Seed picks the operations and the data sent.
+	Seed uint64
+	// Header is sent with every call, for example a tenant API key.
+	Header http.Header
+	// Client defaults to a client sized for Concurrency.
+	Client *http.Client
+}
+
+func DefaultConfig() Config {
+	return Config{
+		BaseURL:     "http://localhost:8080",
+		Mix:         DefaultMix(),
+		Concurrency: 10,
+		Duration:    30 * time.Second,
+		Prefill:     50,
+		Timeout:     10 * time.Second,
+		Seed:        1,
+	}
+}
+
+// student is a student the run created, with what update has to resend.
+type student struct {
+	id    string
+	email string
+}
+
+type runner struct {
+	cfg    Config
+	client *http.Client
+	grades []string
+	// run tells this run's emails apart from earlier runs' against the same
+	// database.
+	run  string
+	next atomic.Int64
+
+	mu       sync.Mutex
+	students []student
+}
+
+// Run runs the load test and reports on it. It fails only when the server
+// cannot be used at all; failed calls are counted in the report.
+func Run(ctx context.Context, cfg Config) (*Report, error) {
+	if cfg.Concurrency <= 0 {
+		return nil, errors.New("concurrency must be positive")
+	}
+	if cfg.Mix.total() == 0 {
+		return nil, errors.New("mix has no operation with a weight")
+	}
+	if cfg.Duration <= 0 && cfg.Requests <= 0 {
+		return nil, errors.New("a duration or a number of requests is required")
+	}
+	client := cfg.Client
+	if client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = cfg.Concurrency
+		client = &http.Client{Transport: transport}
+	}
+	r := &runner{
+		cfg:    cfg,
+		client: client,
+		run:    strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+
+	if err := r.loadGrades(ctx); err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", cfg.BaseURL, err)
+	}
+	rng := rand.New(rand.NewPCG(cfg.Seed, 0))
+	for range cfg.Prefill {
+		if status, err := r.create(ctx, rng); err != nil || status != http.StatusCreated {
+			return nil, fmt.Errorf("failed to create students before the run: %s", outcome(status, err))
+		}
+	}
+
+	runCtx := ctx
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+	tokens := pace(runCtx, cfg.RPS)
+
+	var issued atomic.Int64
+	results := make([]*recorder, cfg.Concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := range cfg.Concurrency {
+		results[w] = newRecorder()
+		wg.Add(1)
+		go func(rec *recorder, rng *rand.Rand) {
+			defer wg.Done()
+			for {
+				if tokens != nil {
+					if _, ok := <-tokens; !ok {
+						return
+					}
+				} else if runCtx.Err() != nil {
+					return
+				}
+				if cfg.Requests > 0 && issued.Add(1) > int64(cfg.Requests) {
+					return
+				}
+				op := cfg.Mix.pick(rng)
+				began := time.Now()
+				op, status, err := r.call(ctx, op, rng)
+				rec.record(op, time.Since(began), status, err)
+			}
+		}(results[w], rand.New(rand.NewPCG(cfg.Seed, uint64(w)+1)))
+	}
+	wg.Wait()
+
+	return newReport(cfg, time.Since(start), results), nil
+}
+
+// pace returns a channel that yields rps tokens a second until ctx is done,
+// or nil when rps is not limited. Tokens are not saved up while every
+// worker is busy, so a slow server brings the rate down rather than being
+// hit with a burst afterwards.
+func pace(ctx context.Context, rps float64) <-chan struct{} {
+	if rps <= 0 {
+		return nil
+	}
+	tokens := make(chan struct{})
+	interval := time.Duration(float64(time.Second) / rps)
+	go func() {
+		defer close(tokens)
+		next := time.Now()
+		for {
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			next = next.Add(interval)
+			if now := time.Now(); next.Before(now) {
+				next = now
+			}
+			select {
+			case <-time.After(time.Until(next)):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return tokens
+}
+
+// call runs op, or a create when op needs a student and there is none. It
+// returns the operation it ran.
+func (r *runner) call(ctx context.Context, op string, rng *rand.Rand) (string, int, error) {
+	if op == OpCreate {
+		status, err := r.create(ctx, rng)
+		return op, status, err
+	}
+	if op == OpList {
+		status, err := r.do(ctx, http.MethodGet, "/v1/api/students", nil, nil)
+		return op, status, err
+	}
+
+	target, ok := r.pick(rng, op == OpDelete)
+	if !ok {
+		status, err := r.create(ctx, rng)
+		return OpCreate, status, err
+	}
+	path := "/v1/api/students/" + target.id
+	switch op {
+	case OpGet:
+		status, err := r.do(ctx, http.MethodGet, path, nil, nil)
+		return op, status, err
+	case OpUpdate:
+		status, err := r.do(ctx, http.MethodPut, path, r.body(rng, target.email), nil)
+		return op, status, err
+	default:
+		status, err := r.do(ctx, http.MethodDelete, path, nil, nil)
+		return op, status, err
+	}
+}
+
+func (r *runner) create(ctx context.Context, rng *rand.Rand) (int, error) {
+	email := fmt.Sprintf("loadtest-%s-%d@example.com", r.run, r.next.Add(1))
+	var created struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	status, err := r.do(ctx, http.MethodPost, "/v1/api/students", r.body(rng, email), &created)
+	if err == nil && status == http.StatusCreated {
+		r.mu.Lock()
+		r.students = append(r.students, student{id: created.Data.ID, email: email})
+		r.mu.Unlock()
+	}
+	return status, err
+}
+
+// body is a student adult enough to need no guardian.
+func (r *runner) body(rng *rand.Rand, email string) map[string]any {
+	return map[string]any{
+		"name":  fmt.Sprintf("Load Test %d", rng.IntN(100000)),
+		"email": email,
+		"age":   18 + rng.IntN(40),
+		"grade": r.grades[rng.IntN(len(r.grades))],
+	}
+}
+
+// pick returns a student created during the run. Students picked for
+// deletion are taken out so no other call picks them afterwards.
+func (r *runner) pick(rng *rand.Rand, remove bool) (student, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.students) == 0 {
+		return student{}, false
+	}
+	i := rng.IntN(len(r.students))
+	picked := r.students[i]
+	if remove {
+		last := len(r.students) - 1
+		r.students[i] = r.students[last]
+		r.students = r.students[:last]
+	}
+	return picked, true
+}
+
+func (r *runner) loadGrades(ctx context.Context) error {
+	var grades struct {
+		Data []string `json:"data"`
+	}
+	status, err := r.do(ctx, http.MethodGet, "/v1/api/grades", nil, &grades)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK || len(grades.Data) == 0 {
+		return fmt.Errorf("GET /v1/api/grades answered %s", outcome(status, nil))
+	}
+	r.grades = grades.Data
+	return nil
+}
+
+// do sends one call and decodes a successful response into out, if given.
+func (r *runner) do(ctx context.Context, method, path string, body any, out any) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+
+	var content io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		content = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(r.cfg.BaseURL, "/")+path, content)
+	if err != nil {
+		return 0, err
+	}
+	for name, values := range r.cfg.Header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+	// Drain the body so the connection is reused.
+	_, err = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, err
+}
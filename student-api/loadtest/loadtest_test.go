@@ -0,0 +1,153 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeServer answers the calls a load test makes, failing every failEvery-th
+// get with 500.
+type fakeServer struct {
+	failEvery int64
+	gets      atomic.Int64
+	tenant    atomic.Value
+	mu        sync.Mutex
+	created   int
+	students  map[string]bool
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.tenant.Store(r.Header.Get("X-Tenant-ID"))
+	reply := func(status int, data any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{"success": status < 300, "data": data})
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/v1/api/students/")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.URL.Path == "/v1/api/grades":
+		reply(http.StatusOK, []string{"1", "2"})
+	case r.URL.Path == "/v1/api/students" && r.Method == http.MethodPost:
+		f.created++
+		id := fmt.Sprint(f.created)
+		f.students[id] = true
+		reply(http.StatusCreated, map[string]string{"id": id})
+	case r.URL.Path == "/v1/api/students":
+		reply(http.StatusOK, []any{})
+	case !f.students[id]:
+		reply(http.StatusNotFound, nil)
+	case r.Method == http.MethodGet && f.gets.Add(1)%f.failEvery == 0:
+		reply(http.StatusInternalServerError, nil)
+	case r.Method == http.MethodDelete:
+		delete(f.students, id)
+		reply(http.StatusOK, nil)
+	default:
+		reply(http.StatusOK, map[string]string{"id": id})
+	}
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, *httptest.Server) {
+	fake := &fakeServer{failEvery: 10, students: make(map[string]bool)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func TestParseMix(t *testing.T) {
+	mix, err := ParseMix("create=1, get=3")
+	assert.NoError(t, err)
+	assert.Equal(t, Mix{OpCreate: 1, OpGet: 3}, mix)
+	assert.Equal(t, "create=1,get=3", mix.String())
+
+	for _, bad := range []string{"", "create", "fetch=1", "get=-1", "get=x", "get=0"} {
+		_, err := ParseMix(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 200; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	l := newLatency(latencies)
+	assert.Equal(t, Latency{Mean: 100.5, P50: 100, P90: 180, P95: 190, P99: 198, Max: 200}, l)
+	assert.Equal(t, Latency{Mean: 7, P50: 7, P90: 7, P95: 7, P99: 7, Max: 7}, newLatency([]time.Duration{7 * time.Millisecond}))
+}
+
+func TestRun(t *testing.T) {
+	fake, server := newFakeServer(t)
+	cfg := DefaultConfig()
+	cfg.BaseURL = server.URL
+	cfg.Mix = Mix{OpCreate: 1, OpGet: 4, OpList: 1, OpUpdate: 1}
+	cfg.Concurrency = 4
+	cfg.Requests = 300
+	cfg.Prefill = 5
+	cfg.Header = http.Header{"X-Tenant-Id": {"acme"}}
+
+	report, err := Run(context.Background(), cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, 300, report.Requests)
+	assert.Equal(t, "acme", fake.tenant.Load())
+	sum := 0
+	for _, op := range []string{OpCreate, OpGet, OpList, OpUpdate} {
+		assert.Greater(t, report.Operations[op].Requests, 0, op)
+		sum += report.Operations[op].Requests
+	}
+	assert.Equal(t, 300, sum)
+	assert.NotContains(t, report.Operations, OpDelete)
+
+	get := report.Operations[OpGet]
+	assert.Equal(t, get.Errors, get.ErrorBreakdown["500 Internal Server Error"])
+	assert.Greater(t, get.Errors, 0)
+	assert.Equal(t, get.Errors, report.Errors)
+	assert.Equal(t, get.Errors, report.ErrorBreakdown["get: 500 Internal Server Error"])
+	assert.Greater(t, report.Throughput, 0.0)
+	assert.LessOrEqual(t, report.Latency.P50, report.Latency.P99)
+
+	var text bytes.Buffer
+	assert.NoError(t, report.WriteText(&text))
+	assert.Contains(t, text.String(), "Requests:    300")
+	assert.Contains(t, text.String(), "get: 500 Internal Server Error")
+	encoded, err := json.Marshal(report)
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), `"requests":300`)
+	assert.Contains(t, string(encoded), `"p99_ms":`)
+}
+
+func TestRunPacesCalls(t *testing.T) {
+	_, server := newFakeServer(t)
+	cfg := DefaultConfig()
+	cfg.BaseURL = server.URL
+	cfg.Mix = Mix{OpList: 1}
+	cfg.Prefill = 0
+	cfg.RPS = 100
+	cfg.Duration = 300 * time.Millisecond
+
+	report, err := Run(context.Background(), cfg)
+	assert.NoError(t, err)
+	assert.InDelta(t, 30, report.Requests, 8)
+	assert.InDelta(t, 100, report.Throughput, 25)
+}
+
+func TestRunUnreachable(t *testing.T) {
+	_, server := newFakeServer(t)
+	server.Close()
+	cfg := DefaultConfig()
+	cfg.BaseURL = server.URL
+	_, err := Run(context.Background(), cfg)
+	assert.ErrorContains(t, err, "failed to reach")
+}
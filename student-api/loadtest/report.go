@@ -0,0 +1,217 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// recorder collects the calls of one worker, so workers never contend.
+type recorder struct {
+	latencies map[string][]time.Duration
+	errors    map[string]map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]map[string]int),
+	}
+}
+
+func (r *recorder) record(op string, latency time.Duration, status int, err error) {
+	r.latencies[op] = append(r.latencies[op], latency)
+	if err == nil && status < 400 {
+		return
+	}
+	if r.errors[op] == nil {
+		r.errors[op] = make(map[string]int)
+	}
+	r.errors[op][outcome(status, err)]++
+}
+
+// outcome names a failed call: its status, such as "409 Conflict", or the
+// kind of transport error.
+func outcome(status int, err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case err != nil && status != 0:
+		return fmt.Sprintf("%d %s: invalid response", status, http.StatusText(status))
+	case err != nil:
+		return "connection error"
+	}
+	return fmt.Sprintf("%d %s", status, http.StatusText(status))
+}
+
+// Latency summarizes call latencies, in milliseconds.
+type Latency struct {
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P90  float64 `json:"p90_ms"`
+	P95  float64 `json:"p95_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+func newLatency(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+	slices.Sort(latencies)
+	var sum time.Duration
+	for _, latency := range latencies {
+		sum += latency
+	}
+	return Latency{
+		Mean: ms(sum / time.Duration(len(latencies))),
+		P50:  ms(percentile(latencies, 50)),
+		P90:  ms(percentile(latencies, 90)),
+		P95:  ms(percentile(latencies, 95)),
+		P99:  ms(percentile(latencies, 99)),
+		Max:  ms(latencies[len(latencies)-1]),
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank-1, 0)]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Stats are the figures for a set of calls.
+type Stats struct {
+	Requests int `json:"requests"`
+	Errors   int `json:"errors"`
+	// Throughput is in calls per second.
+	Throughput float64 `json:"throughput_rps"`
+	Latency    Latency `json:"latency"`
+	// ErrorBreakdown counts failed calls by outcome.
+	ErrorBreakdown map[string]int `json:"error_breakdown,omitempty"`
+}
+
+type Report struct {
+	Target      string  `json:"target"`
+	Mix         string  `json:"mix"`
+	Concurrency int     `json:"concurrency"`
+	TargetRPS   float64 `json:"target_rps,omitempty"`
+	Elapsed     float64 `json:"elapsed_seconds"`
+	Stats
+	Operations map[string]Stats `json:"operations"`
+}
+
+func newReport(cfg Config, elapsed time.Duration, recorders []*recorder) *Report {
+	report := &Report{
+		Target:      cfg.BaseURL,
+		Mix:         cfg.Mix.String(),
+		Concurrency: cfg.Concurrency,
+		TargetRPS:   cfg.RPS,
+		Elapsed:     elapsed.Seconds(),
+		Operations:  make(map[string]Stats),
+	}
+	var all []time.Duration
+	allErrors := make(map[string]int)
+	for _, op := range operations {
+		var latencies []time.Duration
+		errs := make(map[string]int)
+		for _, rec := range recorders {
+			latencies = append(latencies, rec.latencies[op]...)
+			for kind, n := range rec.errors[op] {
+				errs[kind] += n
+				allErrors[op+": "+kind] += n
+			}
+		}
+		if len(latencies) == 0 {
+			continue
+		}
+		all = append(all, latencies...)
+		report.Operations[op] = newStats(latencies, errs, elapsed)
+	}
+	report.Stats = newStats(all, allErrors, elapsed)
+	return report
+}
+
+func newStats(latencies []time.Duration, errs map[string]int, elapsed time.Duration) Stats {
+	stats := Stats{
+		Requests: len(latencies),
+		Latency:  newLatency(latencies),
+	}
+	if elapsed > 0 {
+		stats.Throughput = float64(len(latencies)) / elapsed.Seconds()
+	}
+	for _, n := range errs {
+		stats.Errors += n
+	}
+	if stats.Errors > 0 {
+		stats.ErrorBreakdown = errs
+	}
+	return stats
+}
+
+// WriteText writes the report as a table for people.
+func (r *Report) WriteText(w io.Writer) error {
+	rate := "unlimited"
+	if r.TargetRPS > 0 {
+		rate = fmt.Sprintf("%g req/s", r.TargetRPS)
+	}
+	fmt.Fprintf(w, "Target:      %s\n", r.Target)
+	fmt.Fprintf(w, "Mix:         %s\n", r.Mix)
+	fmt.Fprintf(w, "Load:        %d concurrent, %s\n", r.Concurrency, rate)
+	fmt.Fprintf(w, "Elapsed:     %.1fs\n", r.Elapsed)
+	fmt.Fprintf(w, "Requests:    %d (%d errors, %.2f%%)\n", r.Requests, r.Errors, errorRate(r.Stats))
+	fmt.Fprintf(w, "Throughput:  %.1f req/s\n\n", r.Throughput)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "operation\trequests\terrors\tmean\tp50\tp90\tp95\tp99\tmax\t")
+	row := func(name string, s Stats) {
+		l := s.Latency
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1fms\t%.1fms\t%.1fms\t%.1fms\t%.1fms\t%.1fms\t\n",
+			name, s.Requests, s.Errors, l.Mean, l.P50, l.P90, l.P95, l.P99, l.Max)
+	}
+	for _, op := range operations {
+		if s, ok := r.Operations[op]; ok {
+			row(op, s)
+		}
+	}
+	row("total", r.Stats)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.ErrorBreakdown) > 0 {
+		fmt.Fprintln(w, "\nErrors:")
+		kinds := make([]string, 0, len(r.ErrorBreakdown))
+		for kind := range r.ErrorBreakdown {
+			kinds = append(kinds, kind)
+		}
+		sort.Slice(kinds, func(i, j int) bool {
+			return r.ErrorBreakdown[kinds[i]] > r.ErrorBreakdown[kinds[j]] ||
+				r.ErrorBreakdown[kinds[i]] == r.ErrorBreakdown[kinds[j]] && kinds[i] < kinds[j]
+		})
+		for _, kind := range kinds {
+			if _, err := fmt.Fprintf(w, "  %6d  %s\n", r.ErrorBreakdown[kind], kind); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func errorRate(s Stats) float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return 100 * float64(s.Errors) / float64(s.Requests)
+}
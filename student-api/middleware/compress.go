@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings Compress can apply, most preferred first.
+const (
+	EncodingZstd = "zstd"
+	EncodingGzip = "gzip"
+)
+
+var encodings = []string{EncodingZstd, EncodingGzip}
+
+// encoder is what gzip.Writer and zstd.Encoder have in common.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	EncodingGzip: {New: func() any {
+		return gzip.NewWriter(nil)
+	}},
+	EncodingZstd: {New: func() any {
+		// One goroutine per encoder: responses are small and there are many
+		// of them at once.
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return enc
+	}},
+}
+
+// Compress compresses responses with zstd or gzip, as negotiated through
+// Accept-Encoding. Only bodies of at least minSize bytes and of text-like
+// content types are compressed, and event streams, partial content and
+// responses that already have a Content-Encoding are left alone.
+func Compress(minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		encoding := NegotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		writer := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, minSize: minSize}
+		c.Writer = writer
+		c.Next()
+		if err := writer.close(); err != nil {
+			log.Printf("Failed to compress response: %v", err)
+		}
+		c.Writer = writer.ResponseWriter
+	}
+}
+
+// NegotiateEncoding picks the content coding to answer an Accept-Encoding
+// header with, or "" for none. Quality values are honored, "*" stands for
+// every coding not listed, and ties go to the coding listed first in
+// encodings.
+func NegotiateEncoding(header string) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "x-gzip" {
+			name = EncodingGzip
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				var err error
+				if q, err = strconv.ParseFloat(value, 64); err != nil {
+					q = 0
+				}
+			}
+		}
+		qualities[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether a body of contentType is worth compressing.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/event-stream":
+		// Proxies hold back compressed event streams.
+		return false
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	}
+	for _, kind := range []string{"json", "xml", "javascript", "msgpack", "csv"} {
+		if strings.Contains(mediaType, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter holds back the start of the body until it knows whether
+// to compress: once minSize bytes are written, or the handler flushes, it
+// decides from the status and headers, and passes everything after that
+// through the encoder or straight to the client.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+	buffered []byte
+	decided  bool
+	enc      encoder
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buffered = append(w.buffered, b...)
+		if len(w.buffered) < w.minSize {
+			return len(b), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow sends the headers without a body so far, so the response
+// is not compressed.
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide(false)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *compressWriter) Written() bool {
+	return w.ResponseWriter.Written() || len(w.buffered) > 0
+}
+
+// Flush sends what has been written so far, compressing it if the response
+// is compressible at all: a flushed response is a stream, and its size is
+// not known up front.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+	if w.enc != nil {
+		if err := w.enc.Flush(); err != nil {
+			return
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide sets the headers for the response, compressed when compress is
+// set and the response allows it, and writes out the buffered body.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	if compressible(header.Get("Content-Type")) {
+		header.Add("Vary", "Accept-Encoding")
+	} else {
+		compress = false
+	}
+	switch w.Status() {
+	case http.StatusNoContent, http.StatusPartialContent, http.StatusNotModified:
+		compress = false
+	}
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		compress = false
+	}
+
+	buffered := w.buffered
+	w.buffered = nil
+	if compress {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.enc = encoderPools[w.encoding].Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+		_, err := w.enc.Write(buffered)
+		return err
+	}
+	if len(buffered) == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(buffered)
+	return err
+}
+
+// close finishes the response: a body that stayed under minSize goes out
+// as it is.
+func (w *compressWriter) close() error {
+	if !w.decided {
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+	if w.enc == nil {
+		return nil
+	}
+	err := w.enc.Close()
+	w.enc.Reset(nil)
+	encoderPools[w.encoding].Put(w.enc)
+	w.enc = nil
+	return err
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                          "",
+		"identity":                  "",
+		"gzip":                      EncodingGzip,
+		"x-gzip":                    EncodingGzip,
+		"gzip, deflate, br, zstd":   EncodingZstd,
+		"gzip;q=1.0, zstd;q=0.5":    EncodingGzip,
+		"GZIP;q=0.8, br":            EncodingGzip,
+		"*":                         EncodingZstd,
+		"*, zstd;q=0":               EncodingGzip,
+		"gzip;q=0, zstd;q=0":        "",
+		"zstd;q=bogus, gzip;q=0.1":  EncodingGzip,
+		"deflate;q=1, gzip;q=0.001": EncodingGzip,
+	} {
+		assert.Equal(t, want, NegotiateEncoding(header), header)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	large := strings.Repeat(`{"name":"Ada Lovelace"},`, 100)
+	r := gin.New()
+	r.Use(Compress(1024))
+	r.GET("/large", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(large))
+	})
+	r.GET("/small", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", []byte(`{"ok":true}`))
+	})
+	r.GET("/image", func(c *gin.Context) {
+		c.Data(http.StatusOK, "image/png", []byte(large))
+	})
+	r.GET("/partial", func(c *gin.Context) {
+		c.Header("Content-Range", "bytes 0-99/2400")
+		c.Data(http.StatusPartialContent, "text/csv", []byte(large))
+	})
+	r.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		c.Writer.WriteString("{\"n\":1}\n")
+		c.Writer.Flush()
+		c.Writer.WriteString("{\"n\":2}\n")
+	})
+	r.GET("/empty", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	gunzip := func(body []byte) string {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		assert.NoError(t, err)
+		plain, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		return string(plain)
+	}
+
+	w := get("/large", "gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Less(t, w.Body.Len(), len(large))
+	assert.Equal(t, large, gunzip(w.Body.Bytes()))
+
+	w = get("/large", "gzip, zstd")
+	assert.Equal(t, "zstd", w.Header().Get("Content-Encoding"))
+	decoder, err := zstd.NewReader(bytes.NewReader(w.Body.Bytes()))
+	assert.NoError(t, err)
+	plain, err := io.ReadAll(decoder)
+	decoder.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, large, string(plain))
+
+	// The pooled encoders are reused cleanly.
+	w = get("/large", "gzip")
+	assert.Equal(t, large, gunzip(w.Body.Bytes()))
+
+	w = get("/large", "")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, w.Body.String())
+
+	w = get("/small", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "bodies under the threshold are sent as they are")
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal(t, `{"ok":true}`, w.Body.String())
+
+	w = get("/image", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, w.Body.String())
+
+	w = get("/partial", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, w.Body.String())
+
+	w = get("/stream", "gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"), "flushed streams are compressed")
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "{\"n\":1}\n{\"n\":2}\n", gunzip(w.Body.Bytes()))
+
+	w = get("/empty", "gzip")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Zero(t, w.Body.Len())
+}
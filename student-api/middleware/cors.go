@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CORSConfig struct {
+	// AllowedOrigins lists the origins, such as "https://admin.example.com",
+	// that may call the API from a browser. "https://*.example.com" allows
+	// every subdomain, and "*" allows any origin but never with credentials.
+	AllowedOrigins []string
+	AllowedMethods []string
+	// AllowedHeaders are the request headers a cross-origin request may
+	// send; "*" allows any.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers scripts may read.
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and read the response.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		AllowedHeaders: []string{"Authorization", "Content-Type", IdempotencyKeyHeader, RoleHeader, TenantHeader, CSRFHeader, LastWriteHeader, "Last-Event-ID"},
+		ExposedHeaders: []string{"Location", IdempotencyReplayedHeader, CSRFHeader, LastWriteHeader},
+		MaxAge:         10 * time.Minute,
+	}
+}
+
+// CORS lets the browsers of the allowed origins call the API. Preflight
+// requests are answered here: with 204 when the origin, method and headers
+// are allowed and 403 otherwise. Other requests from an origin that is not
+// allowed go through without CORS headers, so the browser keeps the
+// response from the page.
+func CORS(cfg CORSConfig) gin.HandlerFunc {
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	anyHeader := false
+	allowedHeaders := make(map[string]bool, len(cfg.AllowedHeaders))
+	for _, name := range cfg.AllowedHeaders {
+		anyHeader = anyHeader || name == "*"
+		allowedHeaders[http.CanonicalHeaderKey(name)] = true
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		header := c.Writer.Header()
+		header.Add("Vary", "Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		allowed, wildcard := originAllowed(cfg.AllowedOrigins, origin)
+		if !allowed {
+			if preflight {
+				abort(c, http.StatusForbidden, "origin "+origin+" is not allowed")
+				return
+			}
+			c.Next()
+			return
+		}
+		if wildcard && !cfg.AllowCredentials {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials && !wildcard {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if exposed != "" {
+				header.Set("Access-Control-Expose-Headers", exposed)
+			}
+			c.Next()
+			return
+		}
+
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		method := c.GetHeader("Access-Control-Request-Method")
+		if !contains(cfg.AllowedMethods, method) {
+			abort(c, http.StatusForbidden, "method "+method+" is not allowed")
+			return
+		}
+		requested := c.GetHeader("Access-Control-Request-Headers")
+		for _, name := range strings.Split(requested, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" && !anyHeader && !allowedHeaders[name] {
+				abort(c, http.StatusForbidden, "header "+name+" is not allowed")
+				return
+			}
+		}
+		header.Set("Access-Control-Allow-Methods", methods)
+		if requested != "" {
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+		if cfg.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// originAllowed reports whether origin is one of allowed, and whether it
+// only matched "*".
+func originAllowed(allowed []string, origin string) (ok, wildcard bool) {
+	origin = strings.ToLower(origin)
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		switch {
+		case pattern == origin:
+			return true, false
+		case pattern == "*":
+			wildcard = true
+		case strings.Contains(pattern, "://*."):
+			scheme, domain, _ := strings.Cut(pattern, "://*")
+			if rest, found := strings.CutPrefix(origin, scheme+"://"); found && strings.HasSuffix(rest, domain) && len(rest) > len(domain) {
+				return true, false
+			}
+		}
+	}
+	return wildcard, wildcard
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func(cfg CORSConfig) *gin.Engine {
+		r := gin.New()
+		r.Use(CORS(cfg))
+		r.GET("/students", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+		})
+		return r
+	}
+	cfg := DefaultCORSConfig()
+	cfg.AllowedOrigins = []string{"https://admin.example.com", "https://*.school.test"}
+	cfg.AllowCredentials = true
+	r := newRouter(cfg)
+
+	do := func(r *gin.Engine, method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/students", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	preflight := func(r *gin.Engine, origin, method, headers string) *httptest.ResponseRecorder {
+		return do(r, http.MethodOptions, origin, map[string]string{
+			"Access-Control-Request-Method":  method,
+			"Access-Control-Request-Headers": headers,
+		})
+	}
+
+	w := do(r, http.MethodGet, "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Origin", w.Header().Get("Vary"), "responses vary by origin even without one")
+
+	w = do(r, http.MethodGet, "https://admin.example.com", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://admin.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), CSRFHeader)
+
+	w = do(r, http.MethodGet, "https://evil.test", nil)
+	assert.Equal(t, http.StatusOK, w.Code, "the browser, not the API, withholds the response")
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = preflight(r, "https://admin.example.com", http.MethodPost, "content-type, idempotency-key")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://admin.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), http.MethodPost)
+	assert.Equal(t, "content-type, idempotency-key", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, w.Header().Values("Vary"))
+
+	w = preflight(r, "https://north.school.test", http.MethodDelete, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://north.school.test", w.Header().Get("Access-Control-Allow-Origin"))
+
+	for _, origin := range []string{"https://school.test", "http://north.school.test", "https://evilschool.test", "https://evil.test"} {
+		w = preflight(r, origin, http.MethodGet, "")
+		assert.Equal(t, http.StatusForbidden, w.Code, origin)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+	}
+
+	w = preflight(r, "https://admin.example.com", "TRACE", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = preflight(r, "https://admin.example.com", http.MethodGet, "X-Debug")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// "*" allows any origin, but never with credentials.
+	cfg.AllowedOrigins = []string{"*"}
+	r = newRouter(cfg)
+	w = do(r, http.MethodGet, "https://anywhere.test", nil)
+	assert.Equal(t, "https://anywhere.test", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+
+	cfg.AllowCredentials = false
+	cfg.AllowedHeaders = []string{"*"}
+	r = newRouter(cfg)
+	w = preflight(r, "https://anywhere.test", http.MethodPut, "X-Debug")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := DefaultCSRFConfig()
+	r := gin.New()
+	r.Use(CSRF(cfg))
+	r.GET("/students", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.POST("/students", func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	do := func(method string, cookies map[string]string, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/students", nil)
+		for name, value := range cookies {
+			req.AddCookie(&http.Cookie{Name: name, Value: value})
+		}
+		if token != "" {
+			req.Header.Set(CSRFHeader, token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	issued := func(w *httptest.ResponseRecorder) *http.Cookie {
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == cfg.CookieName {
+				return cookie
+			}
+		}
+		return nil
+	}
+
+	// Without a session cookie, as with API keys, nothing is checked.
+	w := do(http.MethodPost, nil, "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Nil(t, issued(w))
+	assert.Empty(t, w.Header().Get(CSRFHeader))
+
+	session := map[string]string{cfg.SessionCookie: "abc"}
+	w = do(http.MethodGet, session, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	cookie := issued(w)
+	if assert.NotNil(t, cookie) {
+		assert.Len(t, cookie.Value, csrfTokenLength)
+		assert.Equal(t, cookie.Value, w.Header().Get(CSRFHeader))
+		assert.True(t, cookie.Secure)
+		assert.False(t, cookie.HttpOnly)
+		assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	}
+	token := cookie.Value
+
+	// A session without a token cannot make an unsafe request, even if it
+	// guesses a header.
+	w = do(http.MethodPost, session, token)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotNil(t, issued(w))
+
+	withToken := map[string]string{cfg.SessionCookie: "abc", cfg.CookieName: token}
+	w = do(http.MethodPost, withToken, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "missing or invalid CSRF token")
+
+	w = do(http.MethodPost, withToken, strings.Repeat("0", csrfTokenLength))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = do(http.MethodPost, withToken, token)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Nil(t, issued(w), "a valid token is kept")
+
+	w = do(http.MethodGet, withToken, "")
+	assert.Equal(t, token, w.Header().Get(CSRFHeader))
+}
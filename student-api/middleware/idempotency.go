@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/model"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+)
+
+// IdempotencyStore keeps the state of requests made with an Idempotency-Key.
+type IdempotencyStore interface {
+	// Reserve claims key for a request with the given fingerprint. If the key
+	// is already taken and not expired, the existing record is returned and
+	// reserved is false.
+	Reserve(key, fingerprint string, ttl time.Duration) (record *model.IdempotencyRecord, reserved bool, err error)
+	// Complete stores the response for a reserved key.
+	Complete(key string, statusCode int, contentType string, body []byte) error
+	// Release drops a reservation so the request can be retried.
+	Release(key string) error
+	// PurgeMentioning drops the completed records whose stored response
+	// contains text, such as the ID of an erased student, and returns how
+	// many it dropped.
+	PurgeMentioning(text string) (int64, error)
+}
+
+// Idempotency replays the stored response when a request is retried with the
+// same Idempotency-Key, query and body. Reusing a key with a different request is
+// rejected with 422, and a retry that arrives while the first attempt is still
+// running gets 409. Requests without the header pass through untouched.
+// Server errors are not stored, so they can be retried. Keys are scoped to
+// the tenant resolved by the Tenant middleware, if it ran first.
+func Idempotency(store IdempotencyStore, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			abort(c, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		storeKey := key
+		if tenantID := TenantID(c); tenantID != "" {
+			storeKey = tenantID + ":" + key
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abort(c, http.StatusBadRequest, "failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(c.Request, body)
+
+		record, reserved, err := store.Reserve(storeKey, fingerprint, ttl)
+		if err != nil {
+			log.Printf("Failed to reserve idempotency key %q: %v", key, err)
+			abort(c, http.StatusInternalServerError, "failed to check idempotency key")
+			return
+		}
+		if !reserved {
+			switch {
+			case record.Fingerprint != fingerprint:
+				abort(c, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request")
+			case !record.Completed:
+				abort(c, http.StatusConflict, "a request with this Idempotency-Key is still being processed")
+			default:
+				log.Printf("Replaying stored response for idempotency key %q", key)
+				c.Header(IdempotencyReplayedHeader, "true")
+				c.Data(record.StatusCode, record.ContentType, record.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		// If the handler panics, gin.Recovery answers instead, and the key
+		// must be freed for the retry.
+		finished := false
+		defer func() {
+			if finished {
+				return
+			}
+			if err := store.Release(storeKey); err != nil {
+				log.Printf("Failed to release idempotency key %q: %v", key, err)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		finished = true
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError || status == StatusClientClosedRequest {
+			err = store.Release(storeKey)
+		} else {
+			err = store.Complete(storeKey, status, recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+		}
+		if err != nil {
+			log.Printf("Failed to store idempotent response for key %q: %v", key, err)
+		}
+	}
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method)
+	h.Write([]byte{0})
+	io.WriteString(h, r.URL.Path)
+	h.Write([]byte{0})
+	io.WriteString(h, r.URL.Query().Encode())
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func abort(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, model.StudentResponse{
+		Success: false,
+		Message: message,
+	})
+}
+
+// responseRecorder keeps a copy of everything written to the response.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore for a single process.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*model.IdempotencyRecord
+	now     func() time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		records: make(map[string]*model.IdempotencyRecord),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryIdempotencyStore) Reserve(key, fingerprint string, ttl time.Duration) (*model.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if record, ok := s.records[key]; ok && now.Before(record.ExpiresAt) {
+		copied := *record
+		return &copied, false, nil
+	}
+	s.records[key] = &model.IdempotencyRecord{
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+	}
+	return nil, true, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(key string, statusCode int, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok {
+		record.Completed = true
+		record.StatusCode = statusCode
+		record.ContentType = contentType
+		record.Body = append([]byte(nil), body...)
+	}
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) PurgeMentioning(text string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for key, record := range s.records {
+		if record.Completed && bytes.Contains(record.Body, []byte(text)) {
+			delete(s.records, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
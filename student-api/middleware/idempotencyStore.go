@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/one2n/student-api/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormIdempotencyStore keeps idempotency records in the database, so every
+// replica behind a load balancer sees the same keys.
+type GormIdempotencyStore struct {
+	db *gorm.DB
+}
+
+func NewGormIdempotencyStore(db *gorm.DB) (*GormIdempotencyStore, error) {
+	if err := db.AutoMigrate(&model.IdempotencyRecord{}); err != nil {
+		return nil, err
+	}
+	return &GormIdempotencyStore{db: db}, nil
+}
+
+func (s *GormIdempotencyStore) Reserve(key, fingerprint string, ttl time.Duration) (*model.IdempotencyRecord, bool, error) {
+	now := time.Now()
+	record := model.IdempotencyRecord{
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(ttl),
+	}
+
+	// Clear an expired record first so the insert below can claim the key.
+	if err := s.db.Where("key = ? AND expires_at <= ?", key, now).Delete(&model.IdempotencyRecord{}).Error; err != nil {
+		return nil, false, err
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, true, nil
+	}
+
+	var existing model.IdempotencyRecord
+	err := s.db.First(&existing, "key = ?", key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Released between our insert and this read; let the client retry.
+		return &model.IdempotencyRecord{Key: key, Fingerprint: fingerprint}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+func (s *GormIdempotencyStore) Complete(key string, statusCode int, contentType string, body []byte) error {
+	// Updating from a struct, unlike from a map, runs the body through its
+	// serializer, which encrypts it.
+	return s.db.Model(&model.IdempotencyRecord{}).Where("key = ?", key).
+		Select("completed", "status_code", "content_type", "body").
+		Updates(&model.IdempotencyRecord{
+			Completed:   true,
+			StatusCode:  statusCode,
+			ContentType: contentType,
+			Body:        body,
+		}).Error
+}
+
+func (s *GormIdempotencyStore) Release(key string) error {
+	return s.db.Where("key = ?", key).Delete(&model.IdempotencyRecord{}).Error
+}
+
+// PurgeExpired removes records whose TTL has passed.
+func (s *GormIdempotencyStore) PurgeExpired() (int64, error) {
+	result := s.db.Where("expires_at <= ?", time.Now()).Delete(&model.IdempotencyRecord{})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeMentioning scans the completed records in Go rather than in SQL, as
+// the bodies are stored as bytea on Postgres and blobs on SQLite. Records
+// only live for their TTL, so there are never many.
+func (s *GormIdempotencyStore) PurgeMentioning(text string) (int64, error) {
+	var keys []string
+	var batch []model.IdempotencyRecord
+	err := s.db.Select("key", "body").Where("completed = ?", true).
+		FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for _, record := range batch {
+				if bytes.Contains(record.Body, []byte(text)) {
+					keys = append(keys, record.Key)
+				}
+			}
+			return nil
+		}).Error
+	if err != nil || len(keys) == 0 {
+		return 0, err
+	}
+	result := s.db.Where("key IN ?", keys).Delete(&model.IdempotencyRecord{})
+	return result.RowsAffected, result.Error
+}
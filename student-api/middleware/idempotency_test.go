@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/pii"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newIdempotentRouter(store IdempotencyStore) (*gin.Engine, *int) {
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	r := gin.New()
+	r.Use(gin.RecoveryWithWriter(io.Discard))
+	r.POST("/things", Idempotency(store, time.Hour), func(c *gin.Context) {
+		calls++
+		if c.Query("panic") != "" {
+			panic("handler failed")
+		}
+		if c.Query("fail") != "" {
+			c.JSON(http.StatusInternalServerError, gin.H{"calls": calls})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"calls": calls})
+	})
+	return r, &calls
+}
+
+func post(r *gin.Engine, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	gormStore, err := NewGormIdempotencyStore(db)
+	assert.NoError(t, err)
+
+	stores := map[string]IdempotencyStore{
+		"memory": NewMemoryIdempotencyStore(),
+		"gorm":   gormStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			r, calls := newIdempotentRouter(store)
+
+			first := post(r, "/things", "key-1", `{"a":1}`)
+			assert.Equal(t, http.StatusCreated, first.Code)
+
+			replay := post(r, "/things", "key-1", `{"a":1}`)
+			assert.Equal(t, http.StatusCreated, replay.Code)
+			assert.Equal(t, first.Body.String(), replay.Body.String())
+			assert.Equal(t, "true", replay.Header().Get(IdempotencyReplayedHeader))
+			assert.Equal(t, 1, *calls)
+
+			mismatch := post(r, "/things", "key-1", `{"a":2}`)
+			assert.Equal(t, http.StatusUnprocessableEntity, mismatch.Code)
+			assert.Equal(t, 1, *calls)
+
+			post(r, "/things", "", `{"a":1}`)
+			post(r, "/things", "", `{"a":1}`)
+			assert.Equal(t, 3, *calls)
+
+			failed := post(r, "/things?fail=1", "key-2", `{}`)
+			assert.Equal(t, http.StatusInternalServerError, failed.Code)
+			retried := post(r, "/things?fail=1", "key-2", `{}`)
+			assert.Equal(t, http.StatusInternalServerError, retried.Code)
+			assert.Equal(t, 5, *calls, "server errors are not replayed")
+
+			panicked := post(r, "/things?panic=1", "key-3", `{}`)
+			assert.Equal(t, http.StatusInternalServerError, panicked.Code)
+			retried = post(r, "/things?panic=1", "key-3", `{}`)
+			assert.Equal(t, http.StatusInternalServerError, retried.Code, "a panic frees the key")
+			assert.Equal(t, 7, *calls)
+
+			assert.Equal(t, http.StatusCreated, post(r, "/things?grade=10", "key-4", `{}`).Code)
+			other := post(r, "/things?grade=11", "key-4", `{}`)
+			assert.Equal(t, http.StatusUnprocessableEntity, other.Code, "the query is part of the request")
+			assert.Equal(t, 8, *calls)
+
+			purged, err := store.PurgeMentioning(`"calls":1}`)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), purged)
+			again := post(r, "/things", "key-1", `{"a":1}`)
+			assert.Empty(t, again.Header().Get(IdempotencyReplayedHeader), "a purged response is not replayed")
+			assert.Equal(t, 9, *calls)
+			assert.Equal(t, "true", post(r, "/things?grade=10", "key-4", `{}`).Header().Get(IdempotencyReplayedHeader))
+		})
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	_, reserved, err := store.Reserve("key", "fingerprint", time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	r, calls := newIdempotentRouter(store)
+	w := post(r, "/things", "key", "")
+	// The fingerprint differs from the reservation, which takes precedence.
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	store.records["key"].Fingerprint = requestFingerprint(httptest.NewRequest(http.MethodPost, "/things", nil), nil)
+	w = post(r, "/things", "key", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, 0, *calls)
+}
+
+func TestMemoryIdempotencyStoreExpiry(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	_, reserved, _ := store.Reserve("key", "a", time.Minute)
+	assert.True(t, reserved)
+	_, reserved, _ = store.Reserve("key", "b", time.Minute)
+	assert.False(t, reserved)
+
+	now = now.Add(2 * time.Minute)
+	_, reserved, _ = store.Reserve("key", "b", time.Minute)
+	assert.True(t, reserved)
+}
+
+func TestGormIdempotencyStoreEncryptsBodies(t *testing.T) {
+	keyring, err := pii.NewKeyring(pii.KeyFile{
+		Active:   "k1",
+		Keys:     map[string]string{"k1": base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("k"), 32))},
+		IndexKey: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("i"), 32)),
+	})
+	assert.NoError(t, err)
+	pii.Install(keyring)
+	t.Cleanup(func() { pii.Install(nil) })
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	store, err := NewGormIdempotencyStore(db)
+	assert.NoError(t, err)
+
+	body := []byte(`{"data":{"id":"s1","email":"john@doe.com"}}`)
+	_, reserved, err := store.Reserve("key", "fingerprint", time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+	assert.NoError(t, store.Complete("key", http.StatusCreated, "application/json", body))
+
+	var stored []byte
+	assert.NoError(t, db.Raw("SELECT body FROM idempotency_records WHERE key = ?", "key").Row().Scan(&stored))
+	assert.NotContains(t, string(stored), "john@doe.com")
+	assert.True(t, pii.IsEncrypted(string(stored)))
+
+	record, reserved, err := store.Reserve("key", "fingerprint", time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, body, record.Body)
+
+	purged, err := store.PurgeMentioning(`"id":"s1"`)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged, "encrypted bodies are searched decrypted")
+}
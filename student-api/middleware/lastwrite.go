@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/service"
+)
+
+// LastWriteHeader carries the time of the client's last write, in Unix
+// milliseconds. Responses to writes set it, and clients send it back so that
+// their reads see their own writes whichever server answers them.
+const LastWriteHeader = "X-Last-Write"
+
+// LastWrite reads the client's last write from the X-Last-Write header into
+// a service.WriteClock for the request, and answers requests that write
+// with the new value.
+func LastWrite() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clock := service.ParseWriteClock(c.GetHeader(LastWriteHeader))
+		c.Request = c.Request.WithContext(service.WithWriteClock(c.Request.Context(), clock))
+		c.Writer = &lastWriteWriter{ResponseWriter: c.Writer, clock: clock}
+		c.Next()
+	}
+}
+
+// lastWriteWriter sets X-Last-Write just before the headers go out, once
+// the handler has done its writes.
+type lastWriteWriter struct {
+	gin.ResponseWriter
+	clock   *service.WriteClock
+	stamped bool
+}
+
+func (w *lastWriteWriter) stamp() {
+	if w.stamped {
+		return
+	}
+	w.stamped = true
+	if w.clock.Wrote() && !w.ResponseWriter.Written() {
+		w.Header().Set(LastWriteHeader, w.clock.Header())
+	}
+}
+
+func (w *lastWriteWriter) WriteHeaderNow() {
+	w.stamp()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *lastWriteWriter) Write(b []byte) (int, error) {
+	w.stamp()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *lastWriteWriter) WriteString(s string) (int, error) {
+	w.stamp()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *lastWriteWriter) Flush() {
+	w.stamp()
+	w.ResponseWriter.Flush()
+}
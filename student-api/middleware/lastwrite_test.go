@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/service"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestLastWrite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	cfg := service.DefaultConfig()
+	cfg.Replicas = service.NewReplicas(nil, time.Minute)
+	students := service.NewStudentServiceWithConfig(db, cfg)
+
+	r := gin.New()
+	r.Use(LastWrite())
+	r.POST("/students", func(c *gin.Context) {
+		created, err := students.CreateStudent(c.Request.Context(), &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10"})
+		assert.NoError(t, err)
+		c.JSON(http.StatusCreated, created)
+	})
+	r.GET("/students", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	before := time.Now().UnixMilli()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/students", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	last, err := strconv.ParseInt(w.Header().Get(LastWriteHeader), 10, 64)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, last, before)
+
+	req := httptest.NewRequest(http.MethodGet, "/students", nil)
+	req.Header.Set(LastWriteHeader, strconv.FormatInt(last, 10))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get(LastWriteHeader), "reads leave the client's clock alone")
+}
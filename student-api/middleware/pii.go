@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/pii"
+)
+
+// RoleHeader names the caller's role. It is expected to be set by the
+// authenticating proxy in front of the API, and is only believed from the
+// proxies trusted by the pii.RoleConfig given to Role.
+const RoleHeader = "X-Role"
+
+const roleKey = "role"
+
+// Role resolves the caller's role from the X-Role header through cfg, and
+// stores its masking policy in the request context for
+// pii.PolicyFromContext. Unknown roles are rejected with 400, and roles the
+// caller may not claim with 403. The caller's address is the peer of the
+// connection: X-Forwarded-For is as easy to forge as X-Role.
+func Role(cfg pii.RoleConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		addr, _ := netip.ParseAddr(c.RemoteIP())
+		role, err := cfg.Resolve(addr, c.GetHeader(RoleHeader))
+		switch {
+		case errors.Is(err, pii.ErrUntrustedRole):
+			abort(c, http.StatusForbidden, "role "+c.GetHeader(RoleHeader)+" may not be claimed from this address")
+			return
+		case err != nil:
+			abort(c, http.StatusBadRequest, "unknown role "+c.GetHeader(RoleHeader))
+			return
+		}
+		policy, _ := pii.PolicyFor(role)
+		c.Set(roleKey, role)
+		c.Request = c.Request.WithContext(pii.WithPolicy(c.Request.Context(), policy))
+		c.Next()
+	}
+}
+
+// RequireRole only lets requests through whose role, as resolved by Role, is
+// one of roles. Others are rejected with 403.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString(roleKey)
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+		abort(c, http.StatusForbidden, "role "+role+" may not perform this operation")
+	}
+}
+
+// RoleOf returns the caller's role as resolved by Role, or the viewer role
+// when Role has not run.
+func RoleOf(c *gin.Context) string {
+	if role := c.GetString(roleKey); role != "" {
+		return role
+	}
+	return pii.RoleViewer
+}
+
+// MaskPII masks personal data in JSON responses according to the policy set
+// by Role: every "name", "email" or "phone" member covered by the policy is
+// masked, wherever it appears in the document. Other content types, such as
+// event streams, are passed through untouched.
+func MaskPII() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := pii.PolicyFromContext(c.Request.Context())
+		if policy.Empty() {
+			c.Next()
+			return
+		}
+
+		writer := &maskingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		if !writer.buffering {
+			return
+		}
+
+		body := writer.body.Bytes()
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var doc any
+		if err := decoder.Decode(&doc); err == nil {
+			if masked, err := json.Marshal(policy.JSON(doc)); err == nil {
+				body = masked
+			} else {
+				log.Printf("Failed to encode masked response: %v", err)
+			}
+		}
+		if _, err := writer.ResponseWriter.Write(body); err != nil {
+			log.Printf("Failed to write masked response: %v", err)
+		}
+	}
+}
+
+// maskingWriter holds back JSON bodies so they can be masked once the
+// handler is done, and passes everything else straight through.
+type maskingWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	decided   bool
+	buffering bool
+}
+
+func (w *maskingWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		w.buffering = strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+	}
+	if w.buffering {
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *maskingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/service"
+)
+
+const (
+	TenantHeader     = "X-Tenant-ID"
+	tenantContextKey = "tenant_id"
+)
+
+// Tenant resolves the tenant a request acts for and stores its ID for
+// TenantID. A bearer token in the Authorization header is treated as a tenant
+// API key and decides the tenant; X-Tenant-ID (an ID or slug) must then name
+// the same tenant. Requests without a key act for the default tenant, and
+// get 401 if X-Tenant-ID names another.
+func Tenant(tenants service.TenantResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant, err := service.ResolveRequestTenant(c.Request.Context(), tenants, BearerToken(c), c.GetHeader(TenantHeader))
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidAPIKey), errors.Is(err, service.ErrAPIKeyRequired):
+				abort(c, http.StatusUnauthorized, err.Error())
+			case errors.Is(err, service.ErrTenantMismatch):
+				abort(c, http.StatusForbidden, err.Error())
+			case errors.Is(err, service.ErrTenantNotFound):
+				abort(c, http.StatusNotFound, err.Error())
+			case ContextStatus(err) != 0:
+				abort(c, ContextStatus(err), err.Error())
+			default:
+				log.Printf("Failed to resolve tenant: %v", err)
+				abort(c, http.StatusInternalServerError, "failed to resolve tenant")
+			}
+			return
+		}
+		c.Set(tenantContextKey, tenant.ID)
+		c.Next()
+	}
+}
+
+// TenantID returns the tenant resolved by the Tenant middleware, or "" when
+// it did not run.
+func TenantID(c *gin.Context) string {
+	return c.GetString(tenantContextKey)
+}
+
+// BearerToken returns the token of an "Authorization: Bearer" header.
+func BearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
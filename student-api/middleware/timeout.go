@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatusClientClosedRequest is the non-standard status, borrowed from nginx,
+// recorded for requests the client gave up on before they were answered.
+const StatusClientClosedRequest = 499
+
+// ContextStatus returns the status for an error caused by the request's
+// context ending: 499 when the client went away and 504 when the request ran
+// out of time. It returns 0 for any other error.
+func ContextStatus(err error) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return 0
+	}
+}
+
+// TimeoutStats counts the requests to one route that did not finish.
+type TimeoutStats struct {
+	Route    string `json:"route"`
+	Canceled int64  `json:"canceled"`
+	TimedOut int64  `json:"timed_out"`
+}
+
+// Timeouts bounds how long a request may take. Every route gets the default
+// timeout unless it has an override, keyed by method and route pattern such
+// as "POST /v1/api/students/:id/files"; zero means no timeout, for routes
+// such as event streams that stay open on purpose.
+type Timeouts struct {
+	defaultTimeout time.Duration
+	routes         map[string]time.Duration
+
+	mu    sync.Mutex
+	stats map[string]*TimeoutStats
+}
+
+func NewTimeouts(defaultTimeout time.Duration, routes map[string]time.Duration) *Timeouts {
+	return &Timeouts{defaultTimeout: defaultTimeout, routes: routes, stats: make(map[string]*TimeoutStats)}
+}
+
+// Handler applies the route's timeout to the request context, which the
+// service passes on to the database, and counts the requests that come back
+// as 499 or 504.
+func (t *Timeouts) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		timeout, ok := t.routes[route]
+		if !ok {
+			timeout = t.defaultTimeout
+		}
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+			defer cancel()
+			c.Request = c.Request.WithContext(ctx)
+		}
+
+		c.Next()
+
+		switch c.Writer.Status() {
+		case StatusClientClosedRequest:
+			t.record(route, func(s *TimeoutStats) { s.Canceled++ })
+		case http.StatusGatewayTimeout:
+			t.record(route, func(s *TimeoutStats) { s.TimedOut++ })
+		}
+	}
+}
+
+func (t *Timeouts) record(route string, update func(*TimeoutStats)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats, ok := t.stats[route]
+	if !ok {
+		stats = &TimeoutStats{Route: route}
+		t.stats[route] = stats
+	}
+	update(stats)
+}
+
+// Stats returns the counts for every route that has had a canceled or
+// timed out request, sorted by route.
+func (t *Timeouts) Stats() []TimeoutStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := make([]TimeoutStats, 0, len(t.stats))
+	for _, s := range t.stats {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Route < stats[j].Route })
+	return stats
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextStatus(t *testing.T) {
+	assert.Equal(t, StatusClientClosedRequest, ContextStatus(fmt.Errorf("query: %w", context.Canceled)))
+	assert.Equal(t, http.StatusGatewayTimeout, ContextStatus(context.DeadlineExceeded))
+	assert.Zero(t, ContextStatus(errors.New("boom")))
+	assert.Zero(t, ContextStatus(nil))
+}
+
+func TestTimeouts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	timeouts := NewTimeouts(time.Millisecond, map[string]time.Duration{"GET /stream": 0})
+	r := gin.New()
+	r.Use(timeouts.Handler())
+	wait := func(c *gin.Context) {
+		if _, ok := c.Request.Context().Deadline(); !ok {
+			c.Status(http.StatusOK)
+			return
+		}
+		<-c.Request.Context().Done()
+		c.Status(ContextStatus(c.Request.Context().Err()))
+	}
+	r.GET("/slow", wait)
+	r.GET("/stream", wait)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil).WithContext(ctx))
+	assert.Equal(t, StatusClientClosedRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "a zero override disables the timeout")
+
+	assert.Equal(t, []TimeoutStats{{Route: "GET /slow", Canceled: 1, TimedOut: 1}}, timeouts.Stats())
+}
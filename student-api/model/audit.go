@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+// Audit actions.
+const (
+	AuditStudentCreated  = "student.created"
+	AuditStudentUpdated  = "student.updated"
+	AuditStudentDeleted  = "student.deleted"
+	AuditStudentPromoted = "student.promoted"
+	AuditStudentErased   = "student.erased"
+	AuditGuardianAdded   = "guardian.added"
+	AuditGuardianUpdated = "guardian.updated"
+	AuditGuardianRemoved = "guardian.removed"
+	AuditFileUploaded    = "file.uploaded"
+	AuditFileDeleted     = "file.deleted"
+)
+
+// AuditEntry records one change to a student. Detail never holds personal
+// data, only field names, grades and IDs, so entries can outlive the values
+// they describe.
+type AuditEntry struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TenantID  string    `json:"-" gorm:"not null;default:default;index"`
+	StudentID string    `json:"student_id" gorm:"not null;type:text;index"`
+	Action    string    `json:"action" gorm:"not null"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// StudentTombstone is all that is kept of an erased student.
+type StudentTombstone struct {
+	StudentID string    `json:"student_id" gorm:"primaryKey;type:text"`
+	TenantID  string    `json:"-" gorm:"not null;default:default;index"`
+	ErasedAt  time.Time `json:"erased_at" gorm:"autoCreateTime"`
+}
+
+// StudentPromotion is a promotion of one student, as it appears in an export.
+type StudentPromotion struct {
+	BatchID    string    `json:"batch_id"`
+	FromGrade  string    `json:"from_grade"`
+	ToGrade    string    `json:"to_grade,omitempty"`
+	Graduated  bool      `json:"graduated"`
+	PromotedAt time.Time `json:"promoted_at"`
+}
+
+// StudentExport bundles everything stored about one student, for data
+// subject access requests. Files are listed by their metadata; their
+// content is downloaded separately.
+type StudentExport struct {
+	ExportedAt time.Time          `json:"exported_at"`
+	Student    *Student           `json:"student"`
+	Deleted    bool               `json:"deleted"`
+	Files      []StudentFile      `json:"files"`
+	Promotions []StudentPromotion `json:"promotions"`
+	Audit      []AuditEntry       `json:"audit"`
+}
@@ -0,0 +1,32 @@
+package model
+
+// Batch operation kinds.
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+type BatchOperation struct {
+	Op      string   `json:"op" binding:"required,oneof=create update delete"`
+	ID      string   `json:"id,omitempty"`
+	Student *Student `json:"student,omitempty"`
+}
+
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations" binding:"required,min=1,dive"`
+	// Atomic runs every operation in one transaction, so one failure rolls
+	// back the rest. It defaults to true.
+	Atomic *bool `json:"atomic"`
+}
+
+// BatchResult reports the outcome of one operation, in request order.
+type BatchResult struct {
+	Index   int      `json:"index"`
+	Op      string   `json:"op"`
+	ID      string   `json:"id,omitempty"`
+	Status  int      `json:"status"`
+	Success bool     `json:"success"`
+	Message string   `json:"message,omitempty"`
+	Data    *Student `json:"data,omitempty"`
+}
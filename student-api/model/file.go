@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// File kinds. A student has at most one photo and any number of documents.
+const (
+	FileKindPhoto    = "photo"
+	FileKindDocument = "document"
+)
+
+// StudentFile describes an uploaded file. The content itself lives in a
+// storage.Store under StorageKey, and the thumbnail generated for images
+// under ThumbnailKey.
+type StudentFile struct {
+	ID           string    `json:"id" gorm:"primaryKey;type:text"`
+	TenantID     string    `json:"-" gorm:"not null;default:default;index"`
+	StudentID    string    `json:"student_id" gorm:"not null;type:text;index"`
+	Kind         string    `json:"kind" gorm:"not null"`
+	Filename     string    `json:"filename" gorm:"not null"`
+	ContentType  string    `json:"content_type" gorm:"not null"`
+	Size         int64     `json:"size" gorm:"not null"`
+	SHA256       string    `json:"sha256" gorm:"not null"`
+	StorageKey   string    `json:"-" gorm:"not null"`
+	HasThumbnail bool      `json:"has_thumbnail" gorm:"not null;default:false"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// ThumbnailKey is where the thumbnail of an image is stored.
+func (f *StudentFile) ThumbnailKey() string {
+	return f.StorageKey + ".thumb.jpg"
+}
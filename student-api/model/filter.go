@@ -0,0 +1,10 @@
+package model
+
+// StudentFilter narrows a student listing. Zero values are ignored.
+type StudentFilter struct {
+	Grade        string
+	Status       string
+	NameContains string
+	MinAge       int
+	MaxAge       int
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Relationship types accepted when linking a guardian to a student.
+const (
+	RelationshipMother        = "mother"
+	RelationshipFather        = "father"
+	RelationshipParent        = "parent"
+	RelationshipLegalGuardian = "legal_guardian"
+	RelationshipGrandparent   = "grandparent"
+	RelationshipSibling       = "sibling"
+	RelationshipOther         = "other"
+)
+
+type Guardian struct {
+	ID        string         `json:"id" gorm:"primaryKey;type:text"`
+	TenantID  string         `json:"-" gorm:"not null;default:default;index"`
+	Name      string         `json:"name" gorm:"not null" binding:"required"`
+	Email     string         `json:"email,omitempty" binding:"omitempty,email"`
+	Phone     string         `json:"phone" gorm:"not null" binding:"required"`
+	Address   string         `json:"address,omitempty"`
+	CreatedAt time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// StudentGuardian is the join row between a student and a guardian. A guardian
+// can be linked to several students (siblings), and a student can have several
+// guardians, at most one of which is the primary contact.
+type StudentGuardian struct {
+	StudentID    string    `json:"student_id" gorm:"primaryKey;type:text"`
+	GuardianID   string    `json:"guardian_id" gorm:"primaryKey;type:text;index"`
+	Relationship string    `json:"relationship" gorm:"not null" binding:"required,oneof=mother father parent legal_guardian grandparent sibling other"`
+	IsPrimary    bool      `json:"is_primary" gorm:"not null;default:false"`
+	Guardian     *Guardian `json:"guardian,omitempty" gorm:"foreignKey:GuardianID"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// IdempotencyRecord remembers a request made with an Idempotency-Key header so
+// a retry can be answered with the original response.
+type IdempotencyRecord struct {
+	Key         string `gorm:"primaryKey;type:text"`
+	Fingerprint string `gorm:"not null"`
+	Completed   bool   `gorm:"not null;default:false"`
+	StatusCode  int    `gorm:"not null;default:0"`
+	ContentType string
+	// Body is encrypted at rest like other personal data, as responses
+	// carry student records.
+	Body      []byte    `gorm:"type:bytes;serializer:pii"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
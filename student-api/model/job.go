@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// Job run statuses.
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	// JobAbandoned marks a run whose replica stopped renewing its lease
+	// before the run finished, usually because the replica died.
+	JobAbandoned = "abandoned"
+)
+
+// What started a job run.
+const (
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+)
+
+// JobLease is the row replicas compete for before running a job. Whoever
+// moves LockedUntil into the future runs the job; NextRun is when the job is
+// next due on its schedule.
+type JobLease struct {
+	Job         string    `gorm:"primaryKey;type:text"`
+	Holder      string    `gorm:"not null;default:''"`
+	LockedUntil time.Time `gorm:"not null"`
+	NextRun     time.Time `gorm:"not null"`
+}
+
+// JobRun is one run of a job, kept as history.
+type JobRun struct {
+	ID         string     `json:"id" gorm:"primaryKey;type:text"`
+	Job        string     `json:"job" gorm:"not null;index:idx_job_runs_job_started"`
+	Trigger    string     `json:"trigger" gorm:"not null"`
+	Holder     string     `json:"holder" gorm:"not null"`
+	Status     string     `json:"status" gorm:"not null"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at" gorm:"not null;index:idx_job_runs_job_started"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// JobStatus describes a registered job for the admin API.
+type JobStatus struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	NextRun  time.Time `json:"next_run"`
+	Running  bool      `json:"running"`
+	Holder   string    `json:"holder,omitempty"`
+	LastRun  *JobRun   `json:"last_run,omitempty"`
+}
@@ -0,0 +1,65 @@
+package model
+
+import "time"
+
+// Operation kinds.
+const (
+	OperationImport = "import"
+	OperationExport = "export"
+)
+
+// Operation statuses. Queued and running operations are in progress; the
+// rest are final.
+const (
+	OperationQueued    = "queued"
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+	OperationCanceled  = "canceled"
+)
+
+// Operation is a long-running piece of work, such as a roster import, that
+// runs in the background while the client polls for progress. Its input and
+// result are kept in a storage.Store under InputKey and ResultKey.
+type Operation struct {
+	ID        string `json:"id" gorm:"primaryKey;type:text"`
+	TenantID  string `json:"-" gorm:"not null;default:default;index"`
+	Kind      string `json:"kind" gorm:"not null"`
+	Status    string `json:"status" gorm:"not null;index"`
+	Total     int64  `json:"total" gorm:"not null;default:0"`
+	Processed int64  `json:"processed" gorm:"not null;default:0"`
+	Failed    int64  `json:"failed" gorm:"not null;default:0"`
+	// Percent is worked out from Processed and Total when read.
+	Percent int    `json:"percent" gorm:"-"`
+	Error   string `json:"error,omitempty"`
+	// HasResult is set once the result artifact is stored.
+	HasResult      bool   `json:"has_result" gorm:"not null;default:false"`
+	ResultFilename string `json:"-" gorm:"not null;default:''"`
+	ResultType     string `json:"-" gorm:"not null;default:''"`
+	// Role is the role of the caller that started the operation; its result
+	// is masked for that role. Rows from before roles were recorded are
+	// taken to be the admin's.
+	Role            string `json:"-" gorm:"not null;default:admin"`
+	CancelRequested bool   `json:"-" gorm:"not null;default:false"`
+	// DiscardResult is set on operations in progress whose result must not
+	// be kept, because a student it may hold was erased.
+	DiscardResult bool       `json:"-" gorm:"not null;default:false"`
+	Holder        string     `json:"-" gorm:"not null;default:''"`
+	HeartbeatAt   time.Time  `json:"-"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
+
+func (o *Operation) InputKey() string {
+	return "operations/" + o.ID + "/input"
+}
+
+func (o *Operation) ResultKey() string {
+	return "operations/" + o.ID + "/result"
+}
+
+// Done reports whether the operation has reached a final status.
+func (o *Operation) Done() bool {
+	return o.Status != OperationQueued && o.Status != OperationRunning
+}
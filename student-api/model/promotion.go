@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// Student statuses.
+const (
+	StatusEnrolled  = "enrolled"
+	StatusGraduated = "graduated"
+)
+
+// PromotionBatch records one bulk move of every enrolled student in a grade
+// to the next grade, or out of school when the grade is the last one.
+type PromotionBatch struct {
+	ID           string            `json:"id,omitempty" gorm:"primaryKey;type:text"`
+	TenantID     string            `json:"-" gorm:"not null;default:default;index"`
+	FromGrade    string            `json:"from_grade" gorm:"not null"`
+	ToGrade      string            `json:"to_grade,omitempty"`
+	Graduated    bool              `json:"graduated" gorm:"not null;default:false"`
+	StudentCount int               `json:"student_count" gorm:"not null"`
+	DryRun       bool              `json:"dry_run" gorm:"-"`
+	Records      []PromotionRecord `json:"records" gorm:"foreignKey:BatchID"`
+	CreatedAt    time.Time         `json:"created_at" gorm:"autoCreateTime"`
+}
+
+type PromotionRecord struct {
+	BatchID   string `json:"-" gorm:"primaryKey;type:text"`
+	StudentID string `json:"student_id" gorm:"primaryKey;type:text;index"`
+	FromGrade string `json:"from_grade" gorm:"not null"`
+	ToGrade   string `json:"to_grade,omitempty"`
+}
+
+type PromotionRequest struct {
+	Grade  string `json:"grade" binding:"required"`
+	DryRun bool   `json:"dry_run"`
+}
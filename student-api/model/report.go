@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// Report periods, the buckets enrollment and churn are counted in.
+const (
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// ReportRange limits a report to students created, or for withdrawals
+// deleted, at or after From and before To. A zero bound is open.
+type ReportRange struct {
+	From time.Time
+	To   time.Time
+}
+
+type GradeCount struct {
+	Grade    string `json:"grade"`
+	Students int64  `json:"students"`
+}
+
+type AgeCount struct {
+	Age      int   `json:"age"`
+	Students int64 `json:"students"`
+}
+
+// EnrollmentPeriod counts the students created in the week or month
+// starting on Period, a YYYY-MM-DD date. Weeks start on Monday.
+type EnrollmentPeriod struct {
+	Period   string `json:"period"`
+	Enrolled int64  `json:"enrolled"`
+}
+
+// ChurnPeriod sets the students who left in a period, by being deleted,
+// against those who joined.
+type ChurnPeriod struct {
+	Period    string `json:"period"`
+	Enrolled  int64  `json:"enrolled"`
+	Withdrawn int64  `json:"withdrawn"`
+	Net       int64  `json:"net"`
+}
@@ -0,0 +1,10 @@
+package model
+
+// StudentSearchResult is one ranked hit from a student search. Highlights maps
+// a field name to its value with matched terms wrapped in <mark> tags; the
+// value is HTML-escaped.
+type StudentSearchResult struct {
+	Student    *Student          `json:"student"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
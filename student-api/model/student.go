@@ -6,15 +6,22 @@ import (
 	"gorm.io/gorm"
 )
 
+// Student holds personal data: Name and Email are encrypted at rest when a
+// keyring is installed (see package pii). EmailIndex is the blind index of
+// Email, used for lookups and unique per tenant.
 type Student struct {
-	ID        string         `json:"id" gorm:"primaryKey;type:text"`
-	Name      string         `json:"name" gorm:"not null" binding:"required"`
-	Email     string         `json:"email" gorm:"not null;uniqueIndex" binding:"required,email"`
-	Age       int            `json:"age" gorm:"not null" binding:"required,min=6"`
-	Grade     string         `json:"grade" gorm:"not null" binding:"required"`
-	CreatedAt time.Time      `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID         string            `json:"id" gorm:"primaryKey;type:text"`
+	TenantID   string            `json:"-" gorm:"not null;default:default;index"`
+	Name       string            `json:"name" gorm:"not null;serializer:pii" binding:"required"`
+	Email      string            `json:"email" gorm:"not null;serializer:pii" binding:"required,email"`
+	EmailIndex string            `json:"-" gorm:"not null;default:''"`
+	Age        int               `json:"age" gorm:"not null" binding:"required,min=6"`
+	Grade      string            `json:"grade" gorm:"not null;index" binding:"required"`
+	Status     string            `json:"status" gorm:"not null;default:enrolled"`
+	Guardians  []StudentGuardian `json:"guardians,omitempty" gorm:"foreignKey:StudentID" binding:"omitempty,dive"`
+	CreatedAt  time.Time         `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time         `json:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt  gorm.DeletedAt    `json:"-" gorm:"index"`
 }
 
 type StudentResponse struct {
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTenantID owns every row written before tenants existed and serves
+// requests that do not name a tenant.
+const DefaultTenantID = "default"
+
+// Tenant is one school hosted by the API. Every student, guardian and
+// promotion belongs to exactly one tenant.
+type Tenant struct {
+	ID         string         `json:"id" gorm:"primaryKey;type:text"`
+	Name       string         `json:"name" gorm:"not null" binding:"required"`
+	Slug       string         `json:"slug" gorm:"not null;uniqueIndex" binding:"required"`
+	APIKeyHash string         `json:"-" gorm:"uniqueIndex"`
+	APIKey     string         `json:"api_key,omitempty" gorm:"-"`
+	CreatedAt  time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/one2n/student-api/middleware"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/operations"
+	"github.com/one2n/student-api/pii"
+	"github.com/one2n/student-api/service"
+)
+
+// exportPageSize is the number of students an export reads at a time, the
+// largest page ListStudents serves.
+const exportPageSize = 100
+
+// registerOperationRoutes serves the operations started by the import and
+// export actions: their progress, cancellation and result.
+func registerOperationRoutes(v1 *gin.RouterGroup, ops *operations.Manager) {
+	v1.GET("/operations/:id", func(c *gin.Context) {
+		op, err := ops.Get(c.Request.Context(), requestTenantID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Data:    op,
+		})
+	})
+
+	// POST /operations/:id:cancel; see the note on student actions.
+	v1.POST("/operations/:id", func(c *gin.Context) {
+		id, action, _ := strings.Cut(c.Param("id"), ":")
+		if action != "cancel" {
+			c.JSON(http.StatusNotFound, model.StudentResponse{
+				Success: false,
+				Message: "unknown action",
+			})
+			return
+		}
+
+		log.Printf("Canceling operation %s - Request from %s", id, c.ClientIP())
+		op, err := ops.Cancel(c.Request.Context(), requestTenantID(c), id)
+		if err != nil {
+			log.Printf("Failed to cancel operation %s: %v", id, err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+				Data:    op,
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Data:    op,
+		})
+	})
+
+	v1.GET("/operations/:id/result", func(c *gin.Context) {
+		op, content, err := ops.OpenResult(c.Request.Context(), requestTenantID(c), c.Param("id"), middleware.RoleOf(c))
+		if err != nil {
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+		defer content.Close()
+
+		c.Header("Content-Type", op.ResultType)
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": op.ResultFilename}))
+		modified := time.Time{}
+		if op.FinishedAt != nil {
+			modified = *op.FinishedAt
+		}
+		http.ServeContent(c.Writer, c.Request, op.ResultFilename, modified, content)
+	})
+}
+
+// submitOperation starts work as an operation and answers 202 with where
+// to follow it.
+func submitOperation(c *gin.Context, ops *operations.Manager, spec operations.Spec, input io.Reader, work operations.Work) {
+	spec.Role = middleware.RoleOf(c)
+	op, err := ops.Submit(c.Request.Context(), requestTenantID(c), spec, input, work)
+	if err != nil {
+		log.Printf("Failed to start %s operation: %v", spec.Kind, err)
+		c.JSON(errorStatus(err), model.StudentResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	log.Printf("Started %s operation %s - Request from %s", spec.Kind, op.ID, c.ClientIP())
+	c.Header("Location", "/v1/api/operations/"+op.ID)
+	c.JSON(http.StatusAccepted, model.StudentResponse{
+		Success: true,
+		Data:    op,
+	})
+}
+
+// importStudentsHandler serves POST /students:import. The body is a CSV
+// roster, either as the whole request body or as the "file" field of a
+// multipart form.
+func importStudentsHandler(studentService service.Students, ops *operations.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		input := io.Reader(c.Request.Body)
+		if c.ContentType() == binding.MIMEMultipartPOSTForm {
+			header, err := c.FormFile("file")
+			if err != nil {
+				c.JSON(http.StatusBadRequest, model.StudentResponse{
+					Success: false,
+					Message: "file is required",
+				})
+				return
+			}
+			file, err := header.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, model.StudentResponse{
+					Success: false,
+					Message: err.Error(),
+				})
+				return
+			}
+			defer file.Close()
+			input = file
+		}
+
+		spec := operations.Spec{Kind: model.OperationImport, ResultFilename: "import-results.csv", ResultType: mimeCSV}
+		submitOperation(c, ops, spec, input, importStudents(tenantStudents(c, studentService)))
+	}
+}
+
+// exportStudentsHandler serves POST /students:export. The export is masked
+// for the role of the request that started it.
+func exportStudentsHandler(studentService service.Students, ops *operations.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := pii.PolicyFromContext(c.Request.Context())
+		spec := operations.Spec{Kind: model.OperationExport, ResultFilename: "students.csv", ResultType: mimeCSV}
+		submitOperation(c, ops, spec, nil, exportStudents(tenantStudents(c, studentService), policy))
+	}
+}
+
+// importColumns are the roster columns. The guardian columns are optional
+// and add a primary guardian to the student.
+var importColumns = []string{"name", "email", "age", "grade", "guardian_name", "guardian_phone", "guardian_email", "guardian_relationship"}
+
+// importStudents creates a student for every row of a CSV roster with a
+// header row. Rows that fail are reported in the result, one line per row,
+// and the rest are still imported.
+func importStudents(students service.Students) operations.Work {
+	return func(ctx context.Context, task *operations.Task) error {
+		if task.Input == nil {
+			return errors.New("no roster to import")
+		}
+		total, err := countRecords(task.Input)
+		if err != nil {
+			return err
+		}
+		task.SetTotal(max(total-1, 0))
+		if _, err := task.Input.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		reader := csv.NewReader(task.Input)
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return errors.New("roster is empty")
+		}
+		if err != nil {
+			return err
+		}
+		columns := make(map[string]int, len(header))
+		for i, name := range header {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		for _, name := range importColumns[:4] {
+			if _, ok := columns[name]; !ok {
+				return fmt.Errorf("roster has no %s column", name)
+			}
+		}
+
+		results := newCSVWriter(task.Result)
+		results.Write([]string{"line", "status", "id", "error"})
+		for {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			line, _ := reader.FieldPos(0)
+			field := func(name string) string {
+				if i, ok := columns[name]; ok && i < len(record) {
+					return strings.TrimSpace(record[i])
+				}
+				return ""
+			}
+
+			created, err := importStudent(ctx, students, field)
+			row := []string{strconv.Itoa(line), "created", "", ""}
+			var failed int64
+			if err != nil {
+				row[1], row[3] = "failed", err.Error()
+				failed = 1
+			} else {
+				row[2] = created.ID
+			}
+			if err := results.Write(row); err != nil {
+				return err
+			}
+			if err := task.Add(1, failed); err != nil {
+				results.Flush()
+				return err
+			}
+		}
+		results.Flush()
+		return results.Error()
+	}
+}
+
+func importStudent(ctx context.Context, students service.Students, field func(string) string) (*model.Student, error) {
+	age, err := strconv.Atoi(field("age"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid age %q", field("age"))
+	}
+	student := &model.Student{
+		Name:  field("name"),
+		Email: field("email"),
+		Age:   age,
+		Grade: field("grade"),
+	}
+	if name := field("guardian_name"); name != "" {
+		relationship := field("guardian_relationship")
+		if relationship == "" {
+			relationship = model.RelationshipParent
+		}
+		student.Guardians = []model.StudentGuardian{{
+			Relationship: relationship,
+			IsPrimary:    true,
+			Guardian: &model.Guardian{
+				Name:  name,
+				Phone: field("guardian_phone"),
+				Email: field("guardian_email"),
+			},
+		}}
+	}
+	// The same rules as a student created through the API.
+	if err := binding.Validator.ValidateStruct(student); err != nil {
+		return nil, err
+	}
+	return students.CreateStudent(ctx, student)
+}
+
+// countRecords counts the CSV records in r, which may span several lines.
+func countRecords(r io.Reader) (int64, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	var count int64
+	for {
+		_, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		count++
+	}
+}
+
+// exportStudents writes every student of the tenant as CSV, masked by
+// policy.
+func exportStudents(students service.Students, policy pii.Policy) operations.Work {
+	return func(ctx context.Context, task *operations.Task) error {
+		w := newCSVWriter(task.Result)
+		w.Write(studentCSVHeader)
+		for offset := 0; ; offset += exportPageSize {
+			page, total, err := students.ListStudents(ctx, model.StudentFilter{}, exportPageSize, offset)
+			if err != nil {
+				return err
+			}
+			task.SetTotal(total)
+			for _, student := range page {
+				w.Write(studentCSVRow(policy.Student(student)))
+			}
+			if err := task.Add(int64(len(page)), 0); err != nil {
+				w.Flush()
+				return err
+			}
+			if len(page) < exportPageSize {
+				break
+			}
+		}
+		w.Flush()
+		return w.Error()
+	}
+}
@@ -0,0 +1,534 @@
+// Package operations runs long-running work, such as roster imports, on a
+// bounded pool of workers inside the server. Progress is kept in the
+// database, so a client polling any replica sees it, and inputs and results
+// are kept in a storage.Store.
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/pii"
+	"github.com/one2n/student-api/storage"
+	"github.com/one2n/student-api/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOperationNotFound = errors.New("operation not found")
+	ErrOperationDone     = errors.New("operation has already finished")
+	ErrNoResult          = errors.New("operation has no result")
+	ErrQueueFull         = errors.New("too many operations in progress, try again later")
+	ErrInputTooLarge     = errors.New("operation input is too large")
+	ErrResultForbidden   = errors.New("operation result was made for a more privileged role")
+	// errCanceled is returned by Task.Add once the operation is canceled.
+	errCanceled = errors.New("operation canceled")
+)
+
+type Config struct {
+	// Workers is the number of operations run at once.
+	Workers int
+	// QueueSize is the number of operations that may wait for a worker;
+	// more are refused with ErrQueueFull.
+	QueueSize int
+	// MaxInputSize caps the input of an operation, in bytes.
+	MaxInputSize int64
+	// Holder names this replica on the operations it runs. Defaults to the
+	// host name with a random suffix.
+	Holder string
+	// ProgressInterval is how often progress is written to the database.
+	ProgressInterval time.Duration
+	// HeartbeatInterval is how often the operations of a live replica are
+	// marked as still in progress; see FailStale.
+	HeartbeatInterval time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Workers:           4,
+		QueueSize:         100,
+		MaxInputSize:      50 << 20,
+		ProgressInterval:  time.Second,
+		HeartbeatInterval: 30 * time.Second,
+	}
+}
+
+// Spec describes the operation being submitted.
+type Spec struct {
+	Kind string
+	// ResultFilename and ResultType describe the result artifact to the
+	// client that downloads it.
+	ResultFilename string
+	ResultType     string
+	// Role is the role of the caller; callers of a less privileged role may
+	// not download the result. Empty means pii.RoleAdmin.
+	Role string
+}
+
+// Work does the operation. It reads its input from task.Input, writes its
+// result artifact to task.Result and reports progress through task.Add. ctx
+// is canceled when the operation is canceled or the server stops.
+type Work func(ctx context.Context, task *Task) error
+
+// Manager queues submitted operations and runs them on its workers.
+type Manager struct {
+	db    *gorm.DB
+	store storage.Store
+	cfg   Config
+	now   func() time.Time
+	queue chan *queued
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+}
+
+type queued struct {
+	op   *model.Operation
+	work Work
+	// link is the span of the request that submitted the operation.
+	link trace.SpanContext
+}
+
+func New(db *gorm.DB, store storage.Store, cfg Config) (*Manager, error) {
+	if err := db.AutoMigrate(&model.Operation{}); err != nil {
+		return nil, err
+	}
+	if cfg.Holder == "" {
+		host, _ := os.Hostname()
+		cfg.Holder = fmt.Sprintf("%s-%s", host, uuid.New().String()[:8])
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		db:      db,
+		store:   store,
+		cfg:     cfg,
+		now:     time.Now,
+		queue:   make(chan *queued, cfg.QueueSize),
+		cancels: make(map[string]context.CancelFunc),
+		ctx:     ctx,
+		cancel:  cancel,
+	}, nil
+}
+
+// Start runs the workers until stop is called. stop interrupts running
+// operations, waits for the workers to return and fails whatever is still
+// queued.
+func (m *Manager) Start() (stop func()) {
+	for i := 0; i < m.cfg.Workers; i++ {
+		m.workers.Add(1)
+		go func() {
+			defer m.workers.Done()
+			for {
+				select {
+				case q := <-m.queue:
+					m.run(q)
+				case <-m.ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(m.cfg.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.heartbeat()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		m.cancel()
+		m.workers.Wait()
+		for {
+			select {
+			case q := <-m.queue:
+				m.finish(q.op, model.OperationFailed, "interrupted: server stopped", false, nil)
+			default:
+				return
+			}
+		}
+	}
+}
+
+// Submit stores input, if any, and queues work to run as a new operation of
+// tenantID. It returns the queued operation.
+func (m *Manager) Submit(ctx context.Context, tenantID string, spec Spec, input io.Reader, work Work) (*model.Operation, error) {
+	if spec.Role == "" {
+		spec.Role = pii.RoleAdmin
+	}
+	now := m.now().UTC()
+	op := &model.Operation{
+		ID:             uuid.New().String(),
+		TenantID:       tenantID,
+		Kind:           spec.Kind,
+		Status:         model.OperationQueued,
+		ResultFilename: spec.ResultFilename,
+		ResultType:     spec.ResultType,
+		Role:           spec.Role,
+		Holder:         m.cfg.Holder,
+		HeartbeatAt:    now,
+		CreatedAt:      now,
+	}
+	if input != nil {
+		if err := m.store.Put(op.InputKey(), &limitedReader{r: input, left: m.cfg.MaxInputSize}); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.db.WithContext(ctx).Create(op).Error; err != nil {
+		m.deleteFiles(op)
+		return nil, err
+	}
+
+	select {
+	case m.queue <- &queued{op: op, work: work, link: trace.SpanContextFromContext(ctx)}:
+	default:
+		m.db.Delete(op)
+		m.deleteFiles(op)
+		return nil, ErrQueueFull
+	}
+	log.Printf("Queued %s operation %s", op.Kind, op.ID)
+	return withPercent(op), nil
+}
+
+// Get returns the operation id of tenantID.
+func (m *Manager) Get(ctx context.Context, tenantID, id string) (*model.Operation, error) {
+	var op model.Operation
+	err := m.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", id, tenantID).Take(&op).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOperationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return withPercent(&op), nil
+}
+
+// Cancel stops the operation id of tenantID. A queued operation is canceled
+// at once; a running one stops at its next progress report, on whichever
+// replica runs it, and keeps the result written so far.
+func (m *Manager) Cancel(ctx context.Context, tenantID, id string) (*model.Operation, error) {
+	op, err := m.Get(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if op.Done() {
+		return op, ErrOperationDone
+	}
+
+	now := m.now().UTC()
+	result := m.db.WithContext(ctx).Model(&model.Operation{}).
+		Where("id = ? AND status = ?", id, model.OperationQueued).
+		Updates(map[string]any{"status": model.OperationCanceled, "cancel_requested": true, "finished_at": now})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		m.deleteFiles(op)
+	} else {
+		err := m.db.WithContext(ctx).Model(&model.Operation{}).
+			Where("id = ? AND status = ?", id, model.OperationRunning).
+			Update("cancel_requested", true).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	m.mu.Lock()
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+	}
+	m.mu.Unlock()
+	log.Printf("Canceling operation %s", id)
+	return m.Get(ctx, tenantID, id)
+}
+
+// OpenResult returns the operation id of tenantID and its result artifact
+// for a caller of role. It fails with ErrResultForbidden when role is less
+// privileged than the role that started the operation.
+func (m *Manager) OpenResult(ctx context.Context, tenantID, id, role string) (*model.Operation, io.ReadSeekCloser, error) {
+	op, err := m.Get(ctx, tenantID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !pii.Covers(role, op.Role) {
+		return nil, nil, ErrResultForbidden
+	}
+	if !op.HasResult {
+		return nil, nil, ErrNoResult
+	}
+	content, err := m.store.Open(op.ResultKey())
+	if err != nil {
+		return nil, nil, err
+	}
+	return op, content, nil
+}
+
+// DiscardResults deletes the results of the kind operations of tenantID,
+// such as exports that may hold a student who has since been erased. Those
+// still in progress lose their result once they finish. Returns the number
+// of operations affected.
+func (m *Manager) DiscardResults(ctx context.Context, tenantID, kind string) (int64, error) {
+	result := m.db.WithContext(ctx).Model(&model.Operation{}).
+		Where("tenant_id = ? AND kind = ? AND status IN ?", tenantID, kind, []string{model.OperationQueued, model.OperationRunning}).
+		Update("discard_result", true)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	discarded := result.RowsAffected
+
+	var ops []model.Operation
+	err := m.db.WithContext(ctx).Where("tenant_id = ? AND kind = ? AND has_result = ?", tenantID, kind, true).Find(&ops).Error
+	if err != nil {
+		return discarded, err
+	}
+	for i := range ops {
+		if err := m.discardResult(ctx, &ops[i]); err != nil {
+			return discarded, err
+		}
+		discarded++
+	}
+	return discarded, nil
+}
+
+// discardResult deletes the result of op. The file goes first, so that a
+// failure leaves the operation to be found again.
+func (m *Manager) discardResult(ctx context.Context, op *model.Operation) error {
+	if err := m.store.Delete(op.ResultKey()); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	return m.db.WithContext(ctx).Model(&model.Operation{}).Where("id = ?", op.ID).Update("has_result", false).Error
+}
+
+// FailStale fails the operations whose replica has not sent a heartbeat for
+// olderThan, which happens when it dies. Returns the number failed.
+func (m *Manager) FailStale(ctx context.Context, olderThan time.Duration) (int64, error) {
+	now := m.now().UTC()
+	result := m.db.WithContext(ctx).Model(&model.Operation{}).
+		Where("status IN ? AND heartbeat_at < ?", []string{model.OperationQueued, model.OperationRunning}, now.Add(-olderThan)).
+		Updates(map[string]any{"status": model.OperationFailed, "error": "interrupted: replica stopped", "finished_at": now})
+	return result.RowsAffected, result.Error
+}
+
+// Purge deletes the operations that finished more than olderThan ago,
+// along with their inputs and results. Returns the number deleted.
+func (m *Manager) Purge(ctx context.Context, olderThan time.Duration) (int64, error) {
+	var purged int64
+	var ops []model.Operation
+	err := m.db.WithContext(ctx).
+		Where("status NOT IN ? AND finished_at < ?", []string{model.OperationQueued, model.OperationRunning}, m.now().UTC().Add(-olderThan)).
+		FindInBatches(&ops, 500, func(tx *gorm.DB, _ int) error {
+			for i := range ops {
+				m.deleteFiles(&ops[i])
+			}
+			result := m.db.WithContext(ctx).Delete(&ops)
+			purged += result.RowsAffected
+			return result.Error
+		}).Error
+	return purged, err
+}
+
+func (m *Manager) heartbeat() {
+	err := m.db.Model(&model.Operation{}).
+		Where("holder = ? AND status IN ?", m.cfg.Holder, []string{model.OperationQueued, model.OperationRunning}).
+		Update("heartbeat_at", m.now().UTC()).Error
+	if err != nil {
+		log.Printf("Failed to record operations heartbeat: %v", err)
+	}
+}
+
+// run claims a queued operation, unless it was canceled while waiting, and
+// runs it.
+func (m *Manager) run(q *queued) {
+	op := q.op
+	started := m.now().UTC()
+	result := m.db.Model(&model.Operation{}).Where("id = ? AND status = ?", op.ID, model.OperationQueued).
+		Updates(map[string]any{"status": model.OperationRunning, "started_at": started, "heartbeat_at": started})
+	if result.Error != nil {
+		log.Printf("Failed to start operation %s: %v", op.ID, result.Error)
+		m.finish(op, model.OperationFailed, result.Error.Error(), false, nil)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	defer cancel()
+	m.mu.Lock()
+	m.cancels[op.ID] = cancel
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.cancels, op.ID)
+		m.mu.Unlock()
+	}()
+
+	ctx, span := tracing.Tracer().Start(ctx, "operation "+op.Kind,
+		trace.WithLinks(trace.Link{SpanContext: q.link}),
+		trace.WithAttributes(attribute.String("operation.id", op.ID), tracing.TenantAttribute(op.TenantID)))
+	defer span.End()
+	log.Printf("Running %s operation %s", op.Kind, op.ID)
+
+	task := &Task{m: m, id: op.ID, ctx: ctx, cancel: cancel, flushed: started}
+	if input, err := m.store.Open(op.InputKey()); err == nil {
+		defer input.Close()
+		task.Input = input
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		m.finish(op, model.OperationFailed, err.Error(), false, task)
+		return
+	}
+
+	// The result streams into the store as it is written; the store only
+	// keeps it once the writer is closed without an error.
+	reader, writer := io.Pipe()
+	stored := make(chan error, 1)
+	go func() {
+		err := m.store.Put(op.ResultKey(), reader)
+		reader.CloseWithError(err)
+		stored <- err
+	}()
+	task.Result = writer
+
+	err := safeRun(ctx, q.work, task)
+	status, message := model.OperationSucceeded, ""
+	switch {
+	case err == nil:
+	case m.ctx.Err() != nil:
+		status, message = model.OperationFailed, "interrupted: server stopped"
+	case errors.Is(err, errCanceled), errors.Is(err, context.Canceled):
+		status = model.OperationCanceled
+	default:
+		status, message = model.OperationFailed, err.Error()
+	}
+	if status == model.OperationFailed {
+		writer.CloseWithError(err)
+	} else {
+		writer.Close()
+	}
+	if storeErr := <-stored; storeErr != nil && status != model.OperationFailed {
+		status, message = model.OperationFailed, "storing result: "+storeErr.Error()
+	}
+
+	if status == model.OperationFailed {
+		span.SetStatus(codes.Error, message)
+		log.Printf("Operation %s failed: %s", op.ID, message)
+	} else {
+		log.Printf("Operation %s %s: %d processed, %d failed", op.ID, status, task.processed, task.failed)
+	}
+	m.finish(op, status, message, status != model.OperationFailed, task)
+}
+
+// finish records the final status of op and, when it ran, the final
+// progress of task. Inputs are no longer needed, the result of a failed
+// operation is incomplete, and that of one marked by DiscardResults while
+// it ran must go.
+func (m *Manager) finish(op *model.Operation, status, message string, hasResult bool, task *Task) {
+	updates := map[string]any{
+		"status":      status,
+		"error":       message,
+		"has_result":  hasResult,
+		"finished_at": m.now().UTC(),
+	}
+	if task != nil {
+		updates["total"] = task.total
+		updates["processed"] = task.processed
+		updates["failed"] = task.failed
+	}
+	err := m.db.Model(&model.Operation{}).Where("id = ?", op.ID).Updates(updates).Error
+	if err != nil {
+		log.Printf("Failed to record the end of operation %s: %v", op.ID, err)
+	}
+	if err := m.store.Delete(op.InputKey()); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		log.Printf("Failed to delete the input of operation %s: %v", op.ID, err)
+	}
+	if !hasResult {
+		if err := m.store.Delete(op.ResultKey()); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Failed to delete the result of operation %s: %v", op.ID, err)
+		}
+		return
+	}
+	// Checked after has_result is set: a DiscardResults that runs later
+	// finds the result itself. When in doubt the result goes.
+	var current model.Operation
+	err = m.db.Select("discard_result").Where("id = ?", op.ID).Take(&current).Error
+	if err != nil {
+		log.Printf("Failed to check whether to keep the result of operation %s: %v", op.ID, err)
+	}
+	if err != nil || current.DiscardResult {
+		if err := m.discardResult(context.Background(), op); err != nil {
+			log.Printf("Failed to discard the result of operation %s: %v", op.ID, err)
+		}
+	}
+}
+
+func (m *Manager) deleteFiles(op *model.Operation) {
+	for _, key := range []string{op.InputKey(), op.ResultKey()} {
+		if err := m.store.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Failed to delete %s: %v", key, err)
+		}
+	}
+}
+
+// safeRun turns a panicking operation into a failed one.
+func safeRun(ctx context.Context, work Work, task *Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return work(ctx, task)
+}
+
+func withPercent(op *model.Operation) *model.Operation {
+	switch {
+	case op.Status == model.OperationSucceeded:
+		op.Percent = 100
+	case op.Total > 0:
+		op.Percent = int(min(op.Processed*100/op.Total, 100))
+	}
+	return op
+}
+
+// limitedReader fails with ErrInputTooLarge once more than left bytes are
+// read, so the store discards the input.
+type limitedReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.left < 0 {
+		return 0, ErrInputTooLarge
+	}
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return n, ErrInputTooLarge
+	}
+	return n, err
+}
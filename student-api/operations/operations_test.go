@@ -0,0 +1,265 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/pii"
+	"github.com/one2n/student-api/storage"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestManager(t *testing.T, cfg Config) (*Manager, *gorm.DB, *storage.Local) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	// Every connection to :memory: is a database of its own.
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	store, err := storage.NewLocal(t.TempDir())
+	assert.NoError(t, err)
+	cfg.ProgressInterval = 0
+	m, err := New(db, store, cfg)
+	assert.NoError(t, err)
+	return m, db, store
+}
+
+// await polls the operation until it is done.
+func await(t *testing.T, m *Manager, id string) *model.Operation {
+	var op *model.Operation
+	assert.Eventually(t, func() bool {
+		var err error
+		op, err = m.Get(context.Background(), model.DefaultTenantID, id)
+		return err == nil && op.Done()
+	}, 2*time.Second, 5*time.Millisecond)
+	return op
+}
+
+// upper reports every line of its input in upper case.
+func upper(ctx context.Context, task *Task) error {
+	input, err := io.ReadAll(task.Input)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSpace(string(input)), "\n")
+	task.SetTotal(int64(len(lines)))
+	for _, line := range lines {
+		failed := int64(0)
+		if line == "" {
+			failed = 1
+		}
+		if _, err := fmt.Fprintln(task.Result, strings.ToUpper(line)); err != nil {
+			return err
+		}
+		if err := task.Add(1, failed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestOperationSucceeds(t *testing.T) {
+	m, _, _ := newTestManager(t, DefaultConfig())
+	stop := m.Start()
+	defer stop()
+
+	spec := Spec{Kind: model.OperationImport, ResultFilename: "upper.txt", ResultType: "text/plain"}
+	op, err := m.Submit(context.Background(), model.DefaultTenantID, spec, strings.NewReader("a\n\nc\n"), upper)
+	assert.NoError(t, err)
+	assert.Equal(t, model.OperationQueued, op.Status)
+
+	op = await(t, m, op.ID)
+	assert.Equal(t, model.OperationSucceeded, op.Status)
+	assert.Equal(t, int64(3), op.Total)
+	assert.Equal(t, int64(3), op.Processed)
+	assert.Equal(t, int64(1), op.Failed)
+	assert.Equal(t, 100, op.Percent)
+	assert.True(t, op.HasResult)
+	assert.NotNil(t, op.StartedAt)
+	assert.NotNil(t, op.FinishedAt)
+
+	_, content, err := m.OpenResult(context.Background(), model.DefaultTenantID, op.ID, pii.RoleAdmin)
+	assert.NoError(t, err)
+	result, _ := io.ReadAll(content)
+	content.Close()
+	assert.Equal(t, "A\n\nC\n", string(result))
+	_, _, err = m.OpenResult(context.Background(), model.DefaultTenantID, op.ID, pii.RoleStaff)
+	assert.ErrorIs(t, err, ErrResultForbidden, "results are only for roles that see as much as the one that started it")
+
+	_, err = m.Get(context.Background(), "other", op.ID)
+	assert.ErrorIs(t, err, ErrOperationNotFound, "operations are scoped to their tenant")
+	_, err = m.Cancel(context.Background(), model.DefaultTenantID, op.ID)
+	assert.ErrorIs(t, err, ErrOperationDone)
+}
+
+func TestOperationFails(t *testing.T) {
+	m, _, store := newTestManager(t, DefaultConfig())
+	stop := m.Start()
+	defer stop()
+
+	op, err := m.Submit(context.Background(), model.DefaultTenantID, Spec{Kind: model.OperationExport}, nil, func(ctx context.Context, task *Task) error {
+		fmt.Fprintln(task.Result, "half")
+		return errors.New("disk full")
+	})
+	assert.NoError(t, err)
+	op = await(t, m, op.ID)
+	assert.Equal(t, model.OperationFailed, op.Status)
+	assert.Equal(t, "disk full", op.Error)
+	assert.False(t, op.HasResult)
+	_, _, err = m.OpenResult(context.Background(), model.DefaultTenantID, op.ID, pii.RoleAdmin)
+	assert.ErrorIs(t, err, ErrNoResult)
+	_, err = store.Open(op.ResultKey())
+	assert.ErrorIs(t, err, storage.ErrNotFound, "partial results are not kept")
+
+	op, err = m.Submit(context.Background(), model.DefaultTenantID, Spec{Kind: model.OperationExport}, nil, func(context.Context, *Task) error {
+		panic("boom")
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "panic: boom", await(t, m, op.ID).Error)
+}
+
+func TestOperationCancel(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Workers = 1
+	m, db, _ := newTestManager(t, cfg)
+	stop := m.Start()
+	defer stop()
+
+	started := make(chan struct{})
+	slow := func(ctx context.Context, task *Task) error {
+		task.SetTotal(1000)
+		close(started)
+		for i := 0; ; i++ {
+			fmt.Fprintln(task.Result, i)
+			if err := task.Add(1, 0); err != nil {
+				return err
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	running, err := m.Submit(context.Background(), model.DefaultTenantID, Spec{Kind: model.OperationImport}, nil, slow)
+	assert.NoError(t, err)
+	<-started
+	// The only worker is busy, so this one waits in the queue.
+	queued, err := m.Submit(context.Background(), model.DefaultTenantID, Spec{Kind: model.OperationImport}, nil, slow)
+	assert.NoError(t, err)
+
+	op, err := m.Cancel(context.Background(), model.DefaultTenantID, queued.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.OperationCanceled, op.Status)
+
+	// Cancel the running one the way another replica would, through the
+	// database only.
+	assert.NoError(t, db.Model(&model.Operation{}).Where("id = ?", running.ID).Update("cancel_requested", true).Error)
+	op = await(t, m, running.ID)
+	assert.Equal(t, model.OperationCanceled, op.Status)
+	assert.Greater(t, op.Processed, int64(0))
+	assert.Less(t, op.Percent, 100)
+	assert.True(t, op.HasResult, "a canceled operation keeps its partial result")
+}
+
+func TestOperationQueueLimits(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.QueueSize = 1
+	cfg.MaxInputSize = 4
+	m, _, _ := newTestManager(t, cfg)
+	noop := func(context.Context, *Task) error { return nil }
+
+	_, err := m.Submit(context.Background(), model.DefaultTenantID, Spec{Kind: model.OperationImport}, strings.NewReader("12345"), noop)
+	assert.ErrorIs(t, err, ErrInputTooLarge)
+
+	// Without workers the queue fills up.
+	_, err = m.Submit(context.Background(), model.DefaultTenantID, Spec{Kind: model.OperationImport}, strings.NewReader("1234"), noop)
+	assert.NoError(t, err)
+	_, err = m.Submit(context.Background(), model.DefaultTenantID, Spec{Kind: model.OperationImport}, nil, noop)
+	assert.ErrorIs(t, err, ErrQueueFull)
+}
+
+func TestOperationHousekeeping(t *testing.T) {
+	m, db, store := newTestManager(t, DefaultConfig())
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	noop := func(context.Context, *Task) error { return nil }
+
+	// Queued on a replica that then died.
+	lost, err := m.Submit(context.Background(), model.DefaultTenantID, Spec{Kind: model.OperationImport}, strings.NewReader("x"), noop)
+	assert.NoError(t, err)
+	now = now.Add(time.Minute)
+	failed, err := m.FailStale(context.Background(), 5*time.Minute)
+	assert.NoError(t, err)
+	assert.Zero(t, failed)
+	now = now.Add(5 * time.Minute)
+	failed, err = m.FailStale(context.Background(), 5*time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), failed)
+	op, err := m.Get(context.Background(), model.DefaultTenantID, lost.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.OperationFailed, op.Status)
+
+	purged, err := m.Purge(context.Background(), 24*time.Hour)
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+	now = now.Add(25 * time.Hour)
+	purged, err = m.Purge(context.Background(), 24*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	var count int64
+	db.Model(&model.Operation{}).Count(&count)
+	assert.Zero(t, count)
+	_, err = store.Open(lost.InputKey())
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestDiscardResults(t *testing.T) {
+	m, _, store := newTestManager(t, DefaultConfig())
+	stop := m.Start()
+	defer stop()
+	ctx := context.Background()
+	write := func(ctx context.Context, task *Task) error {
+		_, err := fmt.Fprintln(task.Result, "ada@example.com")
+		return err
+	}
+
+	done, err := m.Submit(ctx, model.DefaultTenantID, Spec{Kind: model.OperationExport}, nil, write)
+	assert.NoError(t, err)
+	imported, err := m.Submit(ctx, model.DefaultTenantID, Spec{Kind: model.OperationImport}, strings.NewReader("a\n"), upper)
+	assert.NoError(t, err)
+	await(t, m, done.ID)
+	await(t, m, imported.ID)
+
+	release := make(chan struct{})
+	running, err := m.Submit(ctx, model.DefaultTenantID, Spec{Kind: model.OperationExport}, nil, func(ctx context.Context, task *Task) error {
+		<-release
+		return write(ctx, task)
+	})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		op, err := m.Get(ctx, model.DefaultTenantID, running.ID)
+		return err == nil && op.Status == model.OperationRunning
+	}, 2*time.Second, 5*time.Millisecond)
+
+	discarded, err := m.DiscardResults(ctx, model.DefaultTenantID, model.OperationExport)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), discarded)
+	close(release)
+
+	for _, id := range []string{done.ID, running.ID} {
+		op := await(t, m, id)
+		assert.Equal(t, model.OperationSucceeded, op.Status)
+		assert.False(t, op.HasResult)
+		_, err = store.Open(op.ResultKey())
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	}
+	_, content, err := m.OpenResult(ctx, model.DefaultTenantID, imported.ID, pii.RoleAdmin)
+	if assert.NoError(t, err, "other kinds keep their results") {
+		content.Close()
+	}
+}
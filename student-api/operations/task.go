@@ -0,0 +1,65 @@
+package operations
+
+import (
+	"context"
+	"io"
+	"log"
+	"time"
+
+	"github.com/one2n/student-api/model"
+)
+
+// Task is the running operation as its Work sees it.
+type Task struct {
+	// Input is what the operation was submitted with, or nil.
+	Input io.ReadSeeker
+	// Result receives the result artifact.
+	Result io.Writer
+
+	m      *Manager
+	id     string
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	total, processed, failed int64
+	flushed                  time.Time
+}
+
+// SetTotal sets the number of items the operation will process, which
+// progress is reported against.
+func (t *Task) SetTotal(total int64) {
+	t.total = total
+}
+
+// Add counts processed items, failed of which failed. Progress is written to
+// the database every ProgressInterval. Add returns an error once the
+// operation is canceled, which Work should return.
+func (t *Task) Add(processed, failed int64) error {
+	t.processed += processed
+	t.failed += failed
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	if t.m.now().Sub(t.flushed) < t.m.cfg.ProgressInterval {
+		return nil
+	}
+	return t.flush()
+}
+
+// flush writes progress unless the operation has been canceled, possibly
+// from another replica, in which case it cancels the task.
+func (t *Task) flush() error {
+	now := t.m.now().UTC()
+	t.flushed = now
+	result := t.m.db.Model(&model.Operation{}).Where("id = ? AND cancel_requested = ?", t.id, false).
+		Updates(map[string]any{"total": t.total, "processed": t.processed, "failed": t.failed, "heartbeat_at": now})
+	if result.Error != nil {
+		log.Printf("Failed to record the progress of operation %s: %v", t.id, result.Error)
+		return nil
+	}
+	if result.RowsAffected == 0 {
+		t.cancel()
+		return errCanceled
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+// Package pii protects personal data: envelope encryption of database
+// columns, blind indexes for looking encrypted values up, masking of API
+// responses by role, and redaction of log output.
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// encryptedPrefix starts every encrypted value, followed by the ID of the key
+// that wraps its data key, the wrapped data key and the ciphertext:
+//
+//	enc:v1:<key id>:<base64 wrapped data key>:<base64 ciphertext>
+const encryptedPrefix = "enc:v1:"
+
+var ErrNoKeyring = errors.New("value is encrypted but no keyring is installed")
+
+// KeyFile is the on-disk form of a keyring. Keys are base64-encoded 32-byte
+// AES keys. New values are encrypted under Active; the other keys are kept
+// so older values can still be decrypted until they are rewrapped. IndexKey
+// keys the blind index and must never change, or lookups stop matching.
+type KeyFile struct {
+	Active   string            `json:"active"`
+	Keys     map[string]string `json:"keys"`
+	IndexKey string            `json:"index_key"`
+}
+
+// Keyring holds the key-encryption keys and the blind index key. Each value is
+// encrypted with its own random data key, which is in turn encrypted (wrapped)
+// with the active key-encryption key. Rotating keys therefore only rewraps the
+// small data keys; the data itself is not re-encrypted.
+type Keyring struct {
+	active   string
+	keys     map[string]cipher.AEAD
+	indexKey []byte
+}
+
+// LoadKeyring reads a KeyFile from path.
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key file: %v", err)
+	}
+	var file KeyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing key file: %v", err)
+	}
+	return NewKeyring(file)
+}
+
+func NewKeyring(file KeyFile) (*Keyring, error) {
+	k := &Keyring{active: file.Active, keys: make(map[string]cipher.AEAD, len(file.Keys))}
+	for id, encoded := range file.Keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key id %q: must be non-empty and contain no colon", id)
+		}
+		aead, err := newAEAD(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %v", id, err)
+		}
+		k.keys[id] = aead
+	}
+	if _, ok := k.keys[file.Active]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the key file", file.Active)
+	}
+	indexKey, err := base64.StdEncoding.DecodeString(file.IndexKey)
+	if err != nil || len(indexKey) < 32 {
+		return nil, errors.New("index_key must be at least 32 base64-encoded bytes")
+	}
+	k.indexKey = indexKey
+	return k, nil
+}
+
+// GenerateKey returns a new random key, base64-encoded for a KeyFile.
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func newAEAD(encoded string) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %v", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// Encrypt encrypts plaintext for the given column. The column is bound to the
+// ciphertext, so a value copied into another column fails to decrypt.
+func (k *Keyring) Encrypt(column, plaintext string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return "", err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(aead, []byte(plaintext), []byte(column))
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.active], dataKey, []byte(k.active))
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + k.active + ":" + wrapped + ":" + ciphertext, nil
+}
+
+// Decrypt reverses Encrypt. Values without the encrypted prefix are returned
+// unchanged, so rows written before encryption was enabled stay readable.
+func (k *Keyring) Decrypt(column, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	id, wrapped, ciphertext, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := k.unwrap(id, wrapped)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return "", err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, ciphertext, []byte(column))
+	if err != nil {
+		return "", fmt.Errorf("error decrypting %s: %v", column, err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRewrap reports whether value is not yet encrypted under the active key.
+func (k *Keyring) NeedsRewrap(value string) bool {
+	return !strings.HasPrefix(value, encryptedPrefix+k.active+":")
+}
+
+// Rewrap re-encrypts the data key of value under the active key. The
+// ciphertext itself is kept as is.
+func (k *Keyring) Rewrap(value string) (string, error) {
+	id, wrapped, ciphertext, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	if id == k.active {
+		return value, nil
+	}
+	dataKey, err := k.unwrap(id, wrapped)
+	if err != nil {
+		return "", err
+	}
+	rewrapped, err := seal(k.keys[k.active], dataKey, []byte(k.active))
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + k.active + ":" + rewrapped + ":" + ciphertext, nil
+}
+
+// BlindIndex returns a keyed hash of value that can be stored next to its
+// ciphertext and matched with an equality lookup.
+func (k *Keyring) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (k *Keyring) unwrap(id, wrapped string) ([]byte, error) {
+	kek, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", id)
+	}
+	dataKey, err := open(kek, wrapped, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping data key: %v", err)
+	}
+	return dataKey, nil
+}
+
+// IsEncrypted reports whether value was produced by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+func parse(value string) (id, wrapped, ciphertext string, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if !IsEncrypted(value) || len(parts) != 3 {
+		return "", "", "", errors.New("malformed encrypted value")
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, additionalData)
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func open(aead cipher.AEAD, encoded string, additionalData []byte) ([]byte, error) {
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+var installed atomic.Pointer[Keyring]
+
+// Install makes k the keyring used by the "pii" GORM serializer and by
+// BlindIndex. Passing nil turns encryption off for new writes. The keyring is
+// process-wide because GORM serializers are.
+func Install(k *Keyring) {
+	installed.Store(k)
+}
+
+// Installed returns the keyring set with Install, or nil.
+func Installed() *Keyring {
+	return installed.Load()
+}
+
+// BlindIndex returns the lookup key for value: its keyed hash when a keyring
+// is installed, the value itself otherwise.
+func BlindIndex(value string) string {
+	if k := Installed(); k != nil {
+		return k.BlindIndex(value)
+	}
+	return value
+}
@@ -0,0 +1,119 @@
+package pii
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestKeyring(t *testing.T, active string, ids ...string) *Keyring {
+	file := KeyFile{Active: active, Keys: map[string]string{}, IndexKey: testKey(t, "index")}
+	for _, id := range ids {
+		file.Keys[id] = testKey(t, id)
+	}
+	k, err := NewKeyring(file)
+	assert.NoError(t, err)
+	return k
+}
+
+// testKey derives a fixed key from name so that keyrings built in one test
+// share keys.
+func testKey(t *testing.T, name string) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(name, 32)[:32]))
+}
+
+func TestKeyringEncryptDecrypt(t *testing.T) {
+	k := newTestKeyring(t, "k1", "k1")
+
+	first, err := k.Encrypt("email", "john@doe.com")
+	assert.NoError(t, err)
+	second, err := k.Encrypt("email", "john@doe.com")
+	assert.NoError(t, err)
+	assert.True(t, IsEncrypted(first))
+	assert.NotContains(t, first, "john")
+	assert.NotEqual(t, first, second, "every value gets its own data key and nonce")
+
+	plaintext, err := k.Decrypt("email", first)
+	assert.NoError(t, err)
+	assert.Equal(t, "john@doe.com", plaintext)
+
+	_, err = k.Decrypt("name", first)
+	assert.Error(t, err, "a value copied into another column does not decrypt")
+
+	legacy, err := k.Decrypt("email", "plain@doe.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "plain@doe.com", legacy)
+
+	_, err = k.Decrypt("email", "enc:v1:k1:garbage")
+	assert.Error(t, err)
+}
+
+func TestKeyringRotation(t *testing.T) {
+	old := newTestKeyring(t, "k1", "k1")
+	encrypted, err := old.Encrypt("name", "John Doe")
+	assert.NoError(t, err)
+
+	rotated := newTestKeyring(t, "k2", "k1", "k2")
+	assert.True(t, rotated.NeedsRewrap(encrypted))
+	plaintext, err := rotated.Decrypt("name", encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "John Doe", plaintext, "values under a retired key stay readable")
+
+	rewrapped, err := rotated.Rewrap(encrypted)
+	assert.NoError(t, err)
+	assert.False(t, rotated.NeedsRewrap(rewrapped))
+	assert.Equal(t, encrypted[strings.LastIndex(encrypted, ":"):], rewrapped[strings.LastIndex(rewrapped, ":"):],
+		"only the data key is rewrapped")
+
+	newOnly := newTestKeyring(t, "k2", "k2")
+	plaintext, err = newOnly.Decrypt("name", rewrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, "John Doe", plaintext)
+	_, err = newOnly.Decrypt("name", encrypted)
+	assert.Error(t, err)
+}
+
+func TestKeyringBlindIndex(t *testing.T) {
+	k := newTestKeyring(t, "k1", "k1")
+	rotated := newTestKeyring(t, "k2", "k1", "k2")
+
+	assert.Equal(t, k.BlindIndex("john@doe.com"), rotated.BlindIndex("john@doe.com"),
+		"the blind index does not change with key rotation")
+	assert.NotEqual(t, k.BlindIndex("john@doe.com"), k.BlindIndex("jane@doe.com"))
+	assert.NotContains(t, k.BlindIndex("john@doe.com"), "john")
+}
+
+func TestLoadKeyring(t *testing.T) {
+	key, err := GenerateKey()
+	assert.NoError(t, err)
+	indexKey, err := GenerateKey()
+	assert.NoError(t, err)
+	data, _ := json.Marshal(KeyFile{Active: "2026-10", Keys: map[string]string{"2026-10": key}, IndexKey: indexKey})
+	path := filepath.Join(t.TempDir(), "keys.json")
+	assert.NoError(t, os.WriteFile(path, data, 0o600))
+
+	k, err := LoadKeyring(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "2026-10", k.ActiveKeyID())
+
+	tests := []struct {
+		name string
+		file KeyFile
+	}{
+		{name: "missing active key", file: KeyFile{Active: "other", Keys: map[string]string{"2026-10": key}, IndexKey: indexKey}},
+		{name: "short key", file: KeyFile{Active: "a", Keys: map[string]string{"a": "c2hvcnQ="}, IndexKey: indexKey}},
+		{name: "colon in id", file: KeyFile{Active: "a:b", Keys: map[string]string{"a:b": key}, IndexKey: indexKey}},
+		{name: "missing index key", file: KeyFile{Active: "a", Keys: map[string]string{"a": key}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyring(tt.file)
+			assert.Error(t, err)
+		})
+	}
+}
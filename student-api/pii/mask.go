@@ -0,0 +1,164 @@
+package pii
+
+import (
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/one2n/student-api/model"
+)
+
+// Masked field names. They match the JSON keys of the models.
+const (
+	FieldName  = "name"
+	FieldEmail = "email"
+	FieldPhone = "phone"
+)
+
+// Roles, from most to least privileged.
+const (
+	RoleAdmin  = "admin"
+	RoleStaff  = "staff"
+	RoleViewer = "viewer"
+)
+
+// Policy is the set of fields a role sees masked.
+type Policy map[string]bool
+
+var policies = map[string]Policy{
+	RoleAdmin:  {},
+	RoleStaff:  {FieldEmail: true, FieldPhone: true},
+	RoleViewer: {FieldName: true, FieldEmail: true, FieldPhone: true},
+}
+
+// PolicyFor returns the masking policy of role.
+func PolicyFor(role string) (Policy, bool) {
+	policy, ok := policies[role]
+	return policy, ok
+}
+
+type policyKey struct{}
+
+// WithPolicy returns a context carrying policy for the masking helpers of
+// the API layers.
+func WithPolicy(ctx context.Context, policy Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, policy)
+}
+
+// PolicyFromContext returns the policy stored by WithPolicy. Without one it
+// returns the viewer's policy, so a layer that forgets to set a role masks
+// everything rather than nothing.
+func PolicyFromContext(ctx context.Context) Policy {
+	if policy, ok := ctx.Value(policyKey{}).(Policy); ok {
+		return policy
+	}
+	return policies[RoleViewer]
+}
+
+// Empty reports whether the policy masks nothing.
+func (p Policy) Empty() bool {
+	return len(p) == 0
+}
+
+// Value masks value if the policy covers field.
+func (p Policy) Value(field, value string) string {
+	if !p[field] {
+		return value
+	}
+	return Mask(field, value)
+}
+
+// Student returns a copy of student with the covered fields masked, including
+// those of its guardians. The original is left untouched, since it may be
+// shared with a cache.
+func (p Policy) Student(student *model.Student) *model.Student {
+	if student == nil || p.Empty() {
+		return student
+	}
+	masked := *student
+	masked.Name = p.Value(FieldName, student.Name)
+	masked.Email = p.Value(FieldEmail, student.Email)
+	if student.Guardians != nil {
+		masked.Guardians = make([]model.StudentGuardian, len(student.Guardians))
+		for i, link := range student.Guardians {
+			link.Guardian = p.Guardian(link.Guardian)
+			masked.Guardians[i] = link
+		}
+	}
+	return &masked
+}
+
+// Guardian returns a copy of guardian with the covered fields masked.
+func (p Policy) Guardian(guardian *model.Guardian) *model.Guardian {
+	if guardian == nil || p.Empty() {
+		return guardian
+	}
+	masked := *guardian
+	masked.Name = p.Value(FieldName, guardian.Name)
+	masked.Email = p.Value(FieldEmail, guardian.Email)
+	masked.Phone = p.Value(FieldPhone, guardian.Phone)
+	return &masked
+}
+
+// JSON masks a decoded JSON document in place: every object member whose key
+// is a covered field and whose value is a string gets masked, at any depth.
+func (p Policy) JSON(doc any) any {
+	switch v := doc.(type) {
+	case map[string]any:
+		for key, member := range v {
+			if s, ok := member.(string); ok && p[key] {
+				v[key] = Mask(key, s)
+			} else {
+				v[key] = p.JSON(member)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = p.JSON(item)
+		}
+	}
+	return doc
+}
+
+var highlightTags = strings.NewReplacer("<mark>", "", "</mark>", "")
+
+// Mask hides most of value while keeping enough to tell records apart:
+// "John Doe" becomes "J*** D***", "john@doe.com" becomes "j***@doe.com" and
+// phone numbers keep their last two digits.
+func Mask(field, value string) string {
+	value = highlightTags.Replace(value)
+	if value == "" {
+		return value
+	}
+	switch field {
+	case FieldEmail:
+		local, domain, ok := strings.Cut(value, "@")
+		if !ok {
+			return maskWord(value)
+		}
+		return maskWord(local) + "@" + domain
+	case FieldPhone:
+		var digits []rune
+		for _, r := range value {
+			if unicode.IsDigit(r) {
+				digits = append(digits, r)
+			}
+		}
+		if len(digits) <= 2 {
+			return "***"
+		}
+		return "***" + string(digits[len(digits)-2:])
+	default:
+		words := strings.Fields(value)
+		for i, word := range words {
+			words[i] = maskWord(word)
+		}
+		return strings.Join(words, " ")
+	}
+}
+
+func maskWord(word string) string {
+	first, _ := utf8.DecodeRuneInString(word)
+	return string(first) + "***"
+}
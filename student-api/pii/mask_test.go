@@ -0,0 +1,81 @@
+package pii
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/one2n/student-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		field, value, want string
+	}{
+		{FieldName, "John Doe", "J*** D***"},
+		{FieldName, "<mark>John</mark> Doe", "J*** D***"},
+		{FieldEmail, "john@doe.com", "j***@doe.com"},
+		{FieldEmail, "not-an-email", "n***"},
+		{FieldPhone, "+1-555-0100", "***00"},
+		{FieldPhone, "7", "***"},
+		{FieldName, "", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Mask(tt.field, tt.value), "%s %q", tt.field, tt.value)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	student := &model.Student{
+		Name:  "John Doe",
+		Email: "john@doe.com",
+		Guardians: []model.StudentGuardian{{
+			Guardian: &model.Guardian{Name: "Jane Doe", Email: "jane@doe.com", Phone: "+1-555-0100"},
+		}},
+	}
+
+	admin, ok := PolicyFor(RoleAdmin)
+	assert.True(t, ok)
+	assert.Same(t, student, admin.Student(student))
+
+	staff, _ := PolicyFor(RoleStaff)
+	masked := staff.Student(student)
+	assert.Equal(t, "John Doe", masked.Name)
+	assert.Equal(t, "j***@doe.com", masked.Email)
+	assert.Equal(t, "***00", masked.Guardians[0].Guardian.Phone)
+	assert.Equal(t, "john@doe.com", student.Email, "the original is not modified")
+	assert.Equal(t, "+1-555-0100", student.Guardians[0].Guardian.Phone)
+
+	viewer, _ := PolicyFor(RoleViewer)
+	doc := map[string]any{
+		"data": []any{
+			map[string]any{"name": "John Doe", "age": 20, "guardians": []any{map[string]any{"name": "Jane Doe"}}},
+		},
+		"message": "ok",
+	}
+	viewer.JSON(doc)
+	item := doc["data"].([]any)[0].(map[string]any)
+	assert.Equal(t, "J*** D***", item["name"])
+	assert.Equal(t, 20, item["age"])
+	assert.Equal(t, "J*** D***", item["guardians"].([]any)[0].(map[string]any)["name"])
+	assert.Equal(t, "ok", doc["message"])
+
+	_, ok = PolicyFor("owner")
+	assert.False(t, ok)
+
+	assert.Equal(t, viewer, PolicyFromContext(context.Background()), "a missing policy masks everything")
+	assert.True(t, PolicyFromContext(WithPolicy(context.Background(), admin)).Empty())
+}
+
+func TestRedactor(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewRedactor(&buf)
+	line := []byte("error creating student john@doe.com (guardian +1 555 010 0199)\n")
+	n, err := w.Write(line)
+	assert.NoError(t, err)
+	assert.Equal(t, len(line), n, "callers see their own byte count")
+	assert.Equal(t, "error creating student j***@doe.com (guardian ***99)\n", buf.String())
+
+	assert.Equal(t, "student 42 age 20", Redact("student 42 age 20"))
+}
@@ -0,0 +1,41 @@
+package pii
+
+import (
+	"io"
+	"regexp"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	phonePattern = regexp.MustCompile(`\+\d[\d\- ]{6,}\d`)
+)
+
+// Redactor is an io.Writer that masks email addresses and international
+// phone numbers before passing output on. It is meant to sit between a
+// logger and its destination; every Write is redacted on its own, which
+// matches loggers that write one line per call.
+type Redactor struct {
+	w io.Writer
+}
+
+func NewRedactor(w io.Writer) *Redactor {
+	return &Redactor{w: w}
+}
+
+func (r *Redactor) Write(p []byte) (int, error) {
+	redacted := Redact(string(p))
+	if _, err := io.WriteString(r.w, redacted); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Redact masks the email addresses and phone numbers found in s.
+func Redact(s string) string {
+	s = emailPattern.ReplaceAllStringFunc(s, func(email string) string {
+		return Mask(FieldEmail, email)
+	})
+	return phonePattern.ReplaceAllStringFunc(s, func(phone string) string {
+		return Mask(FieldPhone, phone)
+	})
+}
@@ -0,0 +1,102 @@
+package pii
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+var (
+	ErrUnknownRole   = errors.New("unknown role")
+	ErrUntrustedRole = errors.New("role claim not trusted")
+)
+
+// rank orders the roles by privilege; higher sees more.
+var rank = map[string]int{
+	RoleViewer: 0,
+	RoleStaff:  1,
+	RoleAdmin:  2,
+}
+
+// Covers reports whether role sees at least as much as other, so that data
+// masked for other may be shown to role. Unknown roles cover nothing.
+func Covers(role, other string) bool {
+	have, ok := rank[role]
+	if !ok {
+		return false
+	}
+	want, ok := rank[other]
+	return ok && have >= want
+}
+
+// RoleConfig decides the role of a caller, and so how much personal data it
+// sees. Callers claim a role themselves, in the X-Role header or "x-role"
+// metadata; a claim is only believed when it comes from one of the trusted
+// proxies, which authenticate callers before passing requests on.
+type RoleConfig struct {
+	// Default is the role of callers that claim none.
+	Default string
+	// TrustedProxies lists the addresses whose role claims are believed.
+	// Others may only claim roles no more privileged than Default.
+	TrustedProxies []netip.Prefix
+}
+
+// DefaultRoleConfig trusts no one and masks everything.
+func DefaultRoleConfig() RoleConfig {
+	return RoleConfig{Default: RoleViewer}
+}
+
+// Resolve returns the role of a caller connecting from addr that claims the
+// role claimed, which is empty when it claims none. It fails with
+// ErrUnknownRole for roles without a policy, and with ErrUntrustedRole when
+// an untrusted caller claims more than the default role.
+func (cfg RoleConfig) Resolve(addr netip.Addr, claimed string) (string, error) {
+	if claimed == "" {
+		return cfg.Default, nil
+	}
+	if _, ok := rank[claimed]; !ok {
+		return "", fmt.Errorf("%w %s", ErrUnknownRole, claimed)
+	}
+	if rank[claimed] <= rank[cfg.Default] || cfg.trusts(addr) {
+		return claimed, nil
+	}
+	return "", fmt.Errorf("%w: %s from %s", ErrUntrustedRole, claimed, addr)
+}
+
+func (cfg RoleConfig) trusts(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range cfg.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePrefixes parses addresses and CIDR ranges, such as "10.0.0.0/8" or
+// "192.0.2.7", for RoleConfig.TrustedProxies.
+func ParsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, err
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
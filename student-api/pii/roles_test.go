@@ -0,0 +1,62 @@
+package pii
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleConfig(t *testing.T) {
+	proxies, err := ParsePrefixes([]string{"10.0.0.0/8", " 192.0.2.7 ", "2001:db8::/32"})
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.7/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}, proxies)
+	_, err = ParsePrefixes([]string{"proxy.internal"})
+	assert.Error(t, err)
+
+	cfg := RoleConfig{Default: RoleStaff, TrustedProxies: proxies}
+	proxy := netip.MustParseAddr("10.1.2.3")
+	stranger := netip.MustParseAddr("198.51.100.1")
+	tests := []struct {
+		name    string
+		addr    netip.Addr
+		claimed string
+		want    string
+		wantErr error
+	}{
+		{name: "no claim", addr: stranger, want: RoleStaff},
+		{name: "less privileged claim", addr: stranger, claimed: RoleViewer, want: RoleViewer},
+		{name: "default claim", addr: stranger, claimed: RoleStaff, want: RoleStaff},
+		{name: "untrusted admin", addr: stranger, claimed: RoleAdmin, wantErr: ErrUntrustedRole},
+		{name: "no address", claimed: RoleAdmin, wantErr: ErrUntrustedRole},
+		{name: "trusted admin", addr: proxy, claimed: RoleAdmin, want: RoleAdmin},
+		{name: "trusted mapped address", addr: netip.MustParseAddr("::ffff:192.0.2.7"), claimed: RoleAdmin, want: RoleAdmin},
+		{name: "trusted IPv6", addr: netip.MustParseAddr("2001:db8::1"), claimed: RoleAdmin, want: RoleAdmin},
+		{name: "unknown role", addr: proxy, claimed: "owner", wantErr: ErrUnknownRole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role, err := cfg.Resolve(tt.addr, tt.claimed)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, role)
+		})
+	}
+
+	role, err := DefaultRoleConfig().Resolve(proxy, "")
+	assert.NoError(t, err)
+	assert.Equal(t, RoleViewer, role, "callers see the least by default")
+}
+
+func TestCovers(t *testing.T) {
+	assert.True(t, Covers(RoleAdmin, RoleAdmin))
+	assert.True(t, Covers(RoleAdmin, RoleViewer))
+	assert.True(t, Covers(RoleStaff, RoleViewer))
+	assert.False(t, Covers(RoleViewer, RoleStaff))
+	assert.False(t, Covers(RoleStaff, RoleAdmin))
+	assert.False(t, Covers("owner", RoleViewer))
+	assert.False(t, Covers(RoleAdmin, ""))
+}
@@ -0,0 +1,73 @@
+package pii
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("pii", serializer{})
+}
+
+// serializer encrypts string and []byte fields tagged
+// `gorm:"serializer:pii"` on the way into the database and decrypts them on
+// the way out, using the installed keyring. Without a keyring values are
+// stored as plaintext.
+type serializer struct{}
+
+func (serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue any) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("unsupported type %T for encrypted column %s", dbValue, field.DBName)
+	}
+
+	plaintext := stored
+	if IsEncrypted(stored) {
+		k := Installed()
+		if k == nil {
+			return ErrNoKeyring
+		}
+		var err error
+		if plaintext, err = k.Decrypt(field.DBName, stored); err != nil {
+			return err
+		}
+	}
+	if field.FieldType.Kind() == reflect.Slice {
+		var value []byte
+		if dbValue != nil {
+			value = []byte(plaintext)
+		}
+		field.ReflectValueOf(ctx, dst).SetBytes(value)
+		return nil
+	}
+	field.ReflectValueOf(ctx, dst).SetString(plaintext)
+	return nil
+}
+
+func (serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue any) (any, error) {
+	k := Installed()
+	switch v := fieldValue.(type) {
+	case string:
+		if k == nil {
+			return v, nil
+		}
+		return k.Encrypt(field.DBName, v)
+	case []byte:
+		if k == nil || v == nil {
+			return v, nil
+		}
+		encrypted, err := k.Encrypt(field.DBName, string(v))
+		return []byte(encrypted), err
+	default:
+		return nil, fmt.Errorf("encrypted column %s must be a string or []byte, got %T", field.DBName, fieldValue)
+	}
+}
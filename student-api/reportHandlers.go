@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/service"
+)
+
+const mimeCSV = "text/csv"
+
+// registerReportRoutes adds the aggregate reports. Every report takes an
+// optional from/to date range and answers in JSON, or in CSV when asked for
+// with format=csv or an Accept: text/csv header.
+func registerReportRoutes(v1 *gin.RouterGroup, studentService service.Students) {
+	reports := v1.Group("/reports")
+
+	reports.GET("/grades", reportHandler("grades", func(c *gin.Context, r model.ReportRange) (any, [][]string, error) {
+		counts, err := tenantStudents(c, studentService).GradeReport(c.Request.Context(), r)
+		rows := [][]string{{"grade", "students"}}
+		for _, count := range counts {
+			rows = append(rows, []string{count.Grade, strconv.FormatInt(count.Students, 10)})
+		}
+		return counts, rows, err
+	}))
+
+	reports.GET("/ages", reportHandler("ages", func(c *gin.Context, r model.ReportRange) (any, [][]string, error) {
+		counts, err := tenantStudents(c, studentService).AgeReport(c.Request.Context(), r)
+		rows := [][]string{{"age", "students"}}
+		for _, count := range counts {
+			rows = append(rows, []string{strconv.Itoa(count.Age), strconv.FormatInt(count.Students, 10)})
+		}
+		return counts, rows, err
+	}))
+
+	reports.GET("/enrollments", reportHandler("enrollments", func(c *gin.Context, r model.ReportRange) (any, [][]string, error) {
+		periods, err := tenantStudents(c, studentService).EnrollmentReport(c.Request.Context(), r, c.DefaultQuery("period", model.PeriodMonth))
+		rows := [][]string{{"period", "enrolled"}}
+		for _, period := range periods {
+			rows = append(rows, []string{period.Period, strconv.FormatInt(period.Enrolled, 10)})
+		}
+		return periods, rows, err
+	}))
+
+	reports.GET("/churn", reportHandler("churn", func(c *gin.Context, r model.ReportRange) (any, [][]string, error) {
+		periods, err := tenantStudents(c, studentService).ChurnReport(c.Request.Context(), r, c.DefaultQuery("period", model.PeriodMonth))
+		rows := [][]string{{"period", "enrolled", "withdrawn", "net"}}
+		for _, period := range periods {
+			rows = append(rows, []string{
+				period.Period,
+				strconv.FormatInt(period.Enrolled, 10),
+				strconv.FormatInt(period.Withdrawn, 10),
+				strconv.FormatInt(period.Net, 10),
+			})
+		}
+		return periods, rows, err
+	}))
+}
+
+// reportHandler parses the date range, runs the report and writes it in the
+// requested format. run returns the report for JSON and the same data as CSV
+// rows, header first.
+func reportHandler(name string, run func(c *gin.Context, r model.ReportRange) (any, [][]string, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log.Printf("Running %s report - Request from %s", name, c.ClientIP())
+		r, err := reportRange(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		data, rows, err := run(c, r)
+		if err != nil {
+			log.Printf("Failed to run %s report: %v", name, err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		if !wantsCSV(c) {
+			c.JSON(http.StatusOK, model.StudentResponse{
+				Success: true,
+				Data:    data,
+			})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, name))
+		c.Header("Content-Type", mimeCSV+"; charset=utf-8")
+		c.Status(http.StatusOK)
+		w := newCSVWriter(c.Writer)
+		if err := w.WriteAll(rows); err != nil {
+			log.Printf("Failed to write %s report: %v", name, err)
+		}
+	}
+}
+
+// reportRange reads the from and to query parameters, each either a date,
+// which covers that whole day, or an RFC 3339 time.
+func reportRange(c *gin.Context) (model.ReportRange, error) {
+	var r model.ReportRange
+	var err error
+	if from := c.Query("from"); from != "" {
+		if r.From, err = parseReportTime(from, false); err != nil {
+			return r, fmt.Errorf("invalid from: %q is not a date", from)
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if r.To, err = parseReportTime(to, true); err != nil {
+			return r, fmt.Errorf("invalid to: %q is not a date", to)
+		}
+	}
+	return r, nil
+}
+
+// parseReportTime parses a range bound. A date as the end of a range means
+// the end of that day, so to=2026-03-31 includes all of March 31st.
+func parseReportTime(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func wantsCSV(c *gin.Context) bool {
+	if format := c.Query("format"); format != "" {
+		return format == "csv"
+	}
+	return c.NegotiateFormat(binding.MIMEJSON, mimeCSV) == mimeCSV
+}
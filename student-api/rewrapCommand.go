@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+
+	"github.com/one2n/student-api/service"
+)
+
+// runRewrap runs the rewrap subcommand, which brings stored personal data in
+// line with the key file and the email rules:
+//
+//	student-api rewrap
+//
+// It encrypts rows written before a key file was configured, rewraps values
+// under retired keys, and normalizes emails and their blind indexes.
+func runRewrap(args []string) error {
+	flags := flag.NewFlagSet("rewrap", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	db, err := setupDatabase()
+	if err != nil {
+		return err
+	}
+	serviceConfig := loadServiceConfig()
+	service.NewStudentServiceWithConfig(db, serviceConfig)
+
+	changed, err := service.ProtectStudents(db, serviceConfig)
+	log.Printf("Rewrote %d students", changed)
+	return err
+}
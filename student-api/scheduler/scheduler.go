@@ -0,0 +1,414 @@
+// Package scheduler runs recurring background jobs. Every replica runs a
+// scheduler, and a lease row per job in the database makes sure each due run
+// happens on exactly one of them.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/pii"
+	"github.com/one2n/student-api/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxRunErrorLength bounds the error message kept with a failed run.
+const maxRunErrorLength = 200
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is already running")
+	// errLeaseLost cancels a run whose lease could not be renewed.
+	errLeaseLost = errors.New("lease lost")
+)
+
+// Func is the work a job does. ctx is canceled when the scheduler stops.
+type Func func(ctx context.Context) error
+
+type Config struct {
+	// Holder names this replica in leases and run history. Defaults to the
+	// host name with a random suffix.
+	Holder string
+	// LeaseTTL is how long a replica holds a job's lease without renewing
+	// it. Running jobs renew their lease, so this only bounds how long a
+	// job stays locked after its replica dies.
+	LeaseTTL time.Duration
+	// PollInterval is how often the scheduler looks for due jobs.
+	PollInterval time.Duration
+	// HistoryLimit is the number of runs kept per job.
+	HistoryLimit int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		LeaseTTL:     time.Minute,
+		PollInterval: 10 * time.Second,
+		HistoryLimit: 100,
+	}
+}
+
+// Scheduler runs registered jobs when they are due and keeps a history of
+// their runs. Before a run, a replica takes the job's lease by moving its
+// expiry into the future with a conditional update; only the replica whose
+// update matched runs the job.
+type Scheduler struct {
+	db  *gorm.DB
+	cfg Config
+	now func() time.Time
+
+	mu   sync.Mutex
+	jobs map[string]*job
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+type job struct {
+	name     string
+	spec     string
+	schedule Schedule
+	run      Func
+}
+
+func New(db *gorm.DB, cfg Config) (*Scheduler, error) {
+	if err := db.AutoMigrate(&model.JobLease{}, &model.JobRun{}); err != nil {
+		return nil, err
+	}
+	if cfg.Holder == "" {
+		host, _ := os.Hostname()
+		cfg.Holder = fmt.Sprintf("%s-%s", host, uuid.New().String()[:8])
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		db:     db,
+		cfg:    cfg,
+		now:    time.Now,
+		jobs:   make(map[string]*job),
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// Register adds a job that runs run on the schedule spec; see Parse.
+func (s *Scheduler) Register(name, spec string, run Func) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+	next := schedule.Next(s.now().UTC())
+	lease := model.JobLease{Job: name, LockedUntil: time.Unix(0, 0).UTC(), NextRun: next}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&lease).Error; err != nil {
+		return err
+	}
+	// A schedule shortened since the last deploy should not wait out the
+	// old one.
+	if err := s.db.Model(&model.JobLease{}).Where("job = ? AND next_run > ?", name, next).Update("next_run", next).Error; err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.jobs[name] = &job{name: name, spec: spec, schedule: schedule, run: run}
+	s.mu.Unlock()
+	return nil
+}
+
+// Start runs due jobs every poll interval until stop is called. stop
+// cancels the context of running jobs and waits for them to return.
+func (s *Scheduler) Start() (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(s.cfg.PollInterval)
+		defer ticker.Stop()
+		for {
+			s.RunDue()
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		s.cancel()
+		s.running.Wait()
+	}
+}
+
+// RunDue starts every job that is due and whose lease this replica gets.
+// The jobs run in the background.
+func (s *Scheduler) RunDue() {
+	for _, j := range s.registered() {
+		now := s.now().UTC()
+		acquired, err := s.acquire(j, now, true)
+		if err != nil {
+			log.Printf("Failed to take the lease of job %s: %v", j.name, err)
+			continue
+		}
+		if !acquired {
+			continue
+		}
+		if _, err := s.start(j, model.JobTriggerSchedule, now); err != nil {
+			log.Printf("Failed to start job %s: %v", j.name, err)
+		}
+	}
+}
+
+// Trigger starts a run of the job called name now, outside its schedule,
+// and returns the run. It does not move the next scheduled run.
+func (s *Scheduler) Trigger(ctx context.Context, name string) (*model.JobRun, error) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	now := s.now().UTC()
+	acquired, err := s.acquire(j, now, false)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ErrJobRunning
+	}
+	return s.start(j, model.JobTriggerManual, now)
+}
+
+// Jobs describes every registered job, ordered by name.
+func (s *Scheduler) Jobs(ctx context.Context) ([]model.JobStatus, error) {
+	jobs := s.registered()
+	names := make([]string, len(jobs))
+	for i, j := range jobs {
+		names[i] = j.name
+	}
+	var leases []model.JobLease
+	if err := s.db.WithContext(ctx).Where("job IN ?", names).Find(&leases).Error; err != nil {
+		return nil, err
+	}
+	byName := make(map[string]model.JobLease, len(leases))
+	for _, lease := range leases {
+		byName[lease.Job] = lease
+	}
+
+	now := s.now().UTC()
+	statuses := make([]model.JobStatus, 0, len(jobs))
+	for _, j := range jobs {
+		lease := byName[j.name]
+		status := model.JobStatus{
+			Name:     j.name,
+			Schedule: j.spec,
+			NextRun:  lease.NextRun,
+			Running:  lease.LockedUntil.After(now),
+		}
+		if status.Running {
+			status.Holder = lease.Holder
+		}
+		runs, err := s.Runs(ctx, j.name, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(runs) > 0 {
+			status.LastRun = &runs[0]
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Runs returns the most recent runs of the job called name, newest first.
+func (s *Scheduler) Runs(ctx context.Context, name string, limit int) ([]model.JobRun, error) {
+	s.mu.Lock()
+	_, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	runs := []model.JobRun{}
+	err := s.db.WithContext(ctx).Where("job = ?", name).Order("started_at DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+func (s *Scheduler) registered() []*job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].name < jobs[b].name })
+	return jobs
+}
+
+// acquire takes the lease of j if nobody holds it. A scheduled run also
+// needs the job to be due, and moves its next run on.
+func (s *Scheduler) acquire(j *job, now time.Time, scheduled bool) (bool, error) {
+	updates := map[string]any{"holder": s.cfg.Holder, "locked_until": now.Add(s.cfg.LeaseTTL)}
+	query := s.db.Model(&model.JobLease{}).Where("job = ? AND locked_until <= ?", j.name, now)
+	if scheduled {
+		query = query.Where("next_run <= ?", now)
+		updates["next_run"] = j.schedule.Next(now)
+	}
+	result := query.Updates(updates)
+	return result.RowsAffected == 1, result.Error
+}
+
+// start records a run of j and runs it in the background. The caller holds
+// the lease, so any run of j still marked running belongs to a replica that
+// lost its lease.
+func (s *Scheduler) start(j *job, trigger string, now time.Time) (*model.JobRun, error) {
+	err := s.db.Model(&model.JobRun{}).Where("job = ? AND status = ?", j.name, model.JobRunning).
+		Updates(map[string]any{"status": model.JobAbandoned, "finished_at": now}).Error
+	if err != nil {
+		s.release(j)
+		return nil, err
+	}
+	run := model.JobRun{
+		ID:        uuid.New().String(),
+		Job:       j.name,
+		Trigger:   trigger,
+		Holder:    s.cfg.Holder,
+		Status:    model.JobRunning,
+		StartedAt: now,
+	}
+	if err := s.db.Create(&run).Error; err != nil {
+		s.release(j)
+		return nil, err
+	}
+
+	log.Printf("Job %s started (%s run %s)", j.name, trigger, run.ID)
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		s.execute(j, run)
+	}()
+	return &run, nil
+}
+
+func (s *Scheduler) execute(j *job, run model.JobRun) {
+	ctx, span := tracing.Tracer().Start(s.ctx, "job "+j.name,
+		trace.WithAttributes(attribute.String("job.trigger", run.Trigger), attribute.String("job.run_id", run.ID)))
+	defer span.End()
+	ctx, lost := context.WithCancelCause(ctx)
+	defer lost(nil)
+	stopRenewing := s.renew(j, lost)
+
+	err := safeRun(ctx, j.run)
+	stopRenewing()
+
+	finished := s.now().UTC()
+	updates := map[string]any{"status": model.JobSucceeded, "finished_at": finished}
+	if cause := context.Cause(ctx); err != nil && errors.Is(cause, errLeaseLost) {
+		log.Printf("Job %s stopped after %s: %v", j.name, finished.Sub(run.StartedAt), cause)
+		updates["status"] = model.JobAbandoned
+		updates["error"] = runError(cause)
+		tracing.RecordErrorClass(span, cause)
+	} else if err != nil {
+		log.Printf("Job %s failed after %s: %v", j.name, finished.Sub(run.StartedAt), err)
+		updates["status"] = model.JobFailed
+		updates["error"] = runError(err)
+		tracing.RecordErrorClass(span, err)
+	} else {
+		log.Printf("Job %s succeeded after %s", j.name, finished.Sub(run.StartedAt))
+	}
+	// A replica that took the lease over has already marked the run
+	// abandoned.
+	err = s.db.Model(&model.JobRun{}).Where("id = ? AND status = ?", run.ID, model.JobRunning).Updates(updates).Error
+	if err != nil {
+		log.Printf("Failed to record run %s of job %s: %v", run.ID, j.name, err)
+	}
+	s.release(j)
+	s.prune(j)
+}
+
+// safeRun turns a panicking job into a failed run.
+func safeRun(ctx context.Context, run Func) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run(ctx)
+}
+
+// renew extends the lease of j every third of its TTL until the returned
+// function is called. Once a renewal fails, or finds the lease held by
+// another replica, the run can no longer count on being the only one: lost
+// is called with errLeaseLost, which cancels it, and renewing stops.
+func (s *Scheduler) renew(j *job, lost context.CancelCauseFunc) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(s.cfg.LeaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				result := s.db.Model(&model.JobLease{}).Where("job = ? AND holder = ?", j.name, s.cfg.Holder).
+					Update("locked_until", s.now().UTC().Add(s.cfg.LeaseTTL))
+				switch {
+				case result.Error != nil:
+					log.Printf("Failed to renew the lease of job %s: %v", j.name, result.Error)
+					lost(fmt.Errorf("%w: %v", errLeaseLost, result.Error))
+					return
+				case result.RowsAffected == 0:
+					log.Printf("Job %s lost its lease to another replica", j.name)
+					lost(fmt.Errorf("%w to another replica", errLeaseLost))
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (s *Scheduler) release(j *job) {
+	err := s.db.Model(&model.JobLease{}).Where("job = ? AND holder = ?", j.name, s.cfg.Holder).
+		Update("locked_until", s.now().UTC()).Error
+	if err != nil {
+		log.Printf("Failed to release the lease of job %s: %v", j.name, err)
+	}
+}
+
+// prune drops the runs of j beyond the history limit.
+func (s *Scheduler) prune(j *job) {
+	var oldest model.JobRun
+	err := s.db.Where("job = ?", j.name).Order("started_at DESC").Offset(s.cfg.HistoryLimit - 1).Limit(1).Take(&oldest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return
+	}
+	if err == nil {
+		err = s.db.Where("job = ? AND started_at < ?", j.name, oldest.StartedAt).Delete(&model.JobRun{}).Error
+	}
+	if err != nil {
+		log.Printf("Failed to prune the history of job %s: %v", j.name, err)
+	}
+}
+
+// runError is the message kept with a failed run. Errors can carry database
+// messages that quote row values, so emails and phone numbers are masked
+// and the message is cut short; the full error is in the server log.
+func runError(err error) string {
+	message := pii.Redact(err.Error())
+	if len(message) <= maxRunErrorLength {
+		return message
+	}
+	cut := maxRunErrorLength
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+	return message[:cut] + "…"
+}
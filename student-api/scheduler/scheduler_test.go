@@ -0,0 +1,238 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/one2n/student-api/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	// Every connection to :memory: is a database of its own.
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
+
+// newTestScheduler returns a scheduler named holder whose clock reads *now.
+func newTestScheduler(t *testing.T, db *gorm.DB, holder string, now *time.Time) *Scheduler {
+	cfg := DefaultConfig()
+	cfg.Holder = holder
+	cfg.HistoryLimit = 3
+	s, err := New(db, cfg)
+	assert.NoError(t, err)
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func TestSchedulerRunsDueJobsOnce(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Date(2026, 3, 4, 10, 0, 30, 0, time.UTC)
+	var runs atomic.Int32
+	count := func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}
+
+	// Two replicas share the database.
+	a := newTestScheduler(t, db, "a", &now)
+	b := newTestScheduler(t, db, "b", &now)
+	assert.NoError(t, a.Register("count", "* * * * *", count))
+	assert.NoError(t, b.Register("count", "* * * * *", count))
+
+	a.RunDue()
+	b.RunDue()
+	a.running.Wait()
+	assert.Zero(t, runs.Load(), "not due before 10:01")
+
+	now = now.Add(time.Minute)
+	a.RunDue()
+	b.RunDue()
+	a.running.Wait()
+	b.running.Wait()
+	assert.Equal(t, int32(1), runs.Load(), "only one replica runs a due job")
+
+	a.RunDue()
+	b.RunDue()
+	a.running.Wait()
+	b.running.Wait()
+	assert.Equal(t, int32(1), runs.Load(), "the next run is at 10:02")
+
+	jobs, err := b.Jobs(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, "count", jobs[0].Name)
+	assert.Equal(t, "* * * * *", jobs[0].Schedule)
+	assert.Equal(t, time.Date(2026, 3, 4, 10, 2, 0, 0, time.UTC), jobs[0].NextRun.UTC())
+	assert.False(t, jobs[0].Running)
+	if assert.NotNil(t, jobs[0].LastRun) {
+		assert.Equal(t, model.JobSucceeded, jobs[0].LastRun.Status)
+		assert.Equal(t, model.JobTriggerSchedule, jobs[0].LastRun.Trigger)
+		assert.NotNil(t, jobs[0].LastRun.FinishedAt)
+	}
+}
+
+func TestSchedulerTrigger(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	s := newTestScheduler(t, db, "a", &now)
+
+	release := make(chan struct{})
+	assert.NoError(t, s.Register("slow", "@daily", func(context.Context) error {
+		<-release
+		return nil
+	}))
+	attempt := 0
+	assert.NoError(t, s.Register("flaky", "@daily", func(context.Context) error {
+		attempt++
+		switch attempt {
+		case 1:
+			return errors.New("disk full")
+		case 2:
+			panic("boom")
+		}
+		return nil
+	}))
+
+	_, err := s.Trigger(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+	_, err = s.Runs(context.Background(), "missing", 10)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	run, err := s.Trigger(context.Background(), "slow")
+	assert.NoError(t, err)
+	assert.Equal(t, model.JobRunning, run.Status)
+	assert.Equal(t, model.JobTriggerManual, run.Trigger)
+	_, err = s.Trigger(context.Background(), "slow")
+	assert.ErrorIs(t, err, ErrJobRunning)
+	jobs, err := s.Jobs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "slow", jobs[1].Name)
+	assert.True(t, jobs[1].Running)
+	assert.Equal(t, "a", jobs[1].Holder)
+	close(release)
+	s.running.Wait()
+
+	for i := 0; i < 4; i++ {
+		now = now.Add(time.Second)
+		_, err := s.Trigger(context.Background(), "flaky")
+		assert.NoError(t, err)
+		s.running.Wait()
+	}
+	runs, err := s.Runs(context.Background(), "flaky", 10)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 3, "history is pruned to the limit")
+	assert.Equal(t, model.JobSucceeded, runs[0].Status)
+	assert.Equal(t, model.JobFailed, runs[2].Status)
+	assert.Equal(t, "panic: boom", runs[2].Error)
+
+	jobs, err = s.Jobs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC), jobs[0].NextRun.UTC(), "manual runs leave the schedule alone")
+}
+
+func TestSchedulerTakesOverExpiredLeases(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	a := newTestScheduler(t, db, "a", &now)
+	b := newTestScheduler(t, db, "b", &now)
+	noop := func(context.Context) error { return nil }
+	assert.NoError(t, a.Register("purge", "@hourly", noop))
+	assert.NoError(t, b.Register("purge", "@hourly", noop))
+
+	// a takes the lease and dies before recording the end of its run.
+	j := a.jobs["purge"]
+	acquired, err := a.acquire(j, now, false)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+	assert.NoError(t, db.Create(&model.JobRun{ID: "lost", Job: "purge", Trigger: model.JobTriggerManual, Holder: "a", Status: model.JobRunning, StartedAt: now}).Error)
+
+	_, err = b.Trigger(context.Background(), "purge")
+	assert.ErrorIs(t, err, ErrJobRunning)
+
+	now = now.Add(a.cfg.LeaseTTL)
+	_, err = b.Trigger(context.Background(), "purge")
+	assert.NoError(t, err)
+	b.running.Wait()
+
+	runs, err := b.Runs(context.Background(), "purge", 10)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 2)
+	assert.Equal(t, "b", runs[0].Holder)
+	assert.Equal(t, model.JobSucceeded, runs[0].Status)
+	assert.Equal(t, model.JobAbandoned, runs[1].Status)
+}
+
+func TestSchedulerCancelsRunsThatLoseTheirLease(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	s := newTestScheduler(t, db, "a", &now)
+	s.cfg.LeaseTTL = 30 * time.Millisecond
+	started := make(chan struct{})
+	assert.NoError(t, s.Register("wait", "@daily", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	_, err := s.Trigger(context.Background(), "wait")
+	assert.NoError(t, err)
+	<-started
+	// Another replica takes the lease over while the run goes on.
+	assert.NoError(t, db.Model(&model.JobLease{}).Where("job = ?", "wait").Update("holder", "b").Error)
+	s.running.Wait()
+
+	runs, err := s.Runs(context.Background(), "wait", 1)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, model.JobAbandoned, runs[0].Status)
+	assert.Equal(t, "lease lost to another replica", runs[0].Error)
+}
+
+func TestSchedulerStop(t *testing.T) {
+	db := setupTestDB(t)
+	cfg := DefaultConfig()
+	cfg.PollInterval = 10 * time.Millisecond
+	s, err := New(db, cfg)
+	assert.NoError(t, err)
+
+	started := make(chan struct{})
+	assert.NoError(t, s.Register("wait", "@every 1s", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	// Make it due now rather than in a second.
+	assert.NoError(t, db.Model(&model.JobLease{}).Where("job = ?", "wait").Update("next_run", time.Now().UTC().Add(-time.Second)).Error)
+
+	stop := s.Start()
+	<-started
+	stop()
+
+	runs, err := s.Runs(context.Background(), "wait", 1)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, model.JobFailed, runs[0].Status)
+	assert.Equal(t, context.Canceled.Error(), runs[0].Error)
+}
+
+func TestRunError(t *testing.T) {
+	assert.Equal(t, "disk full", runError(errors.New("disk full")))
+	assert.Equal(t, "notifying j***@doe.com: refused", runError(fmt.Errorf("notifying %s: %w", "john@doe.com", errors.New("refused"))))
+
+	long := runError(errors.New(strings.Repeat("é", maxRunErrorLength)))
+	assert.True(t, utf8.ValidString(long))
+	assert.LessOrEqual(t, len(long), maxRunErrorLength+len("…"))
+}
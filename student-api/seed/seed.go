@@ -0,0 +1,197 @@
+// Package seed fills a database with synthetic students for local
+// environments, demos and load tests. The data is generated from a seed, so
+// the same seed always produces the same roster.
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/service"
+	"github.com/one2n/student-api/storage"
+	"gorm.io/gorm"
+)
+
+type Config struct {
+	// Students is the number of students to create.
+	Students int
+	// Seed picks the roster; the same seed creates the same students.
+	Seed uint64
+	// Guardians gives adult students guardians too, and some students a
+	// second one.
+	Guardians bool
+	// GuardianRequiredBelowAge matches service.Config: students younger than
+	// this always get a guardian.
+	GuardianRequiredBelowAge int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Students:                 100,
+		Seed:                     1,
+		GuardianRequiredBelowAge: service.DefaultConfig().GuardianRequiredBelowAge,
+	}
+}
+
+// Summary counts what Run created.
+type Summary struct {
+	Students  int `json:"students"`
+	Guardians int `json:"guardians"`
+}
+
+// siblingRate is the share of students who join the family, and share the
+// guardians, of a student created before them.
+const siblingRate = 0.2
+
+var firstNames = []string{
+	"Aarav", "Abigail", "Aisha", "Amelia", "Ana", "Arjun", "Ava", "Benjamin",
+	"Chloe", "Daniel", "Diego", "Elena", "Emma", "Ethan", "Fatima", "Gabriel",
+	"Grace", "Hana", "Isabella", "Jack", "James", "Kai", "Leila", "Liam",
+	"Lucas", "Maya", "Mei", "Mia", "Noah", "Olivia", "Omar", "Priya",
+	"Rohan", "Sofia", "Tariq", "Theo", "Yara", "Yusuf", "Zara", "Zoe",
+}
+
+var lastNames = []string{
+	"Adeyemi", "Anderson", "Bauer", "Chen", "Costa", "Dubois", "Fernandes",
+	"Garcia", "Gupta", "Haddad", "Ivanova", "Jensen", "Kim", "Kowalski",
+	"Lopez", "Martin", "Mehta", "Moreau", "Nakamura", "Nguyen", "Novak",
+	"Okafor", "Olsen", "Patel", "Rossi", "Santos", "Schmidt", "Silva",
+	"Singh", "Tanaka", "Taylor", "Walker", "Wang", "Williams", "Yilmaz",
+}
+
+var relationships = []string{
+	model.RelationshipMother, model.RelationshipMother, model.RelationshipFather,
+	model.RelationshipFather, model.RelationshipParent, model.RelationshipLegalGuardian,
+	model.RelationshipGrandparent,
+}
+
+// family is a last name and the guardians its students share.
+type family struct {
+	lastName  string
+	guardians []string
+}
+
+// Run creates cfg.Students students through students, spread evenly over
+// its grades with ages to match. Emails are unique within a run, so seeding
+// twice with the same seed needs a Reset in between.
+func Run(ctx context.Context, students service.Students, cfg Config) (Summary, error) {
+	rng := rand.New(rand.NewPCG(cfg.Seed, cfg.Seed))
+	grades := students.Grades()
+	var families []*family
+	var summary Summary
+
+	for i := range cfg.Students {
+		level := rng.IntN(len(grades))
+		student := &model.Student{
+			Age:   6 + level*12/len(grades) + rng.IntN(2),
+			Grade: grades[level],
+		}
+
+		var fam *family
+		if len(families) > 0 && rng.Float64() < siblingRate {
+			fam = families[rng.IntN(len(families))]
+		} else {
+			fam = &family{lastName: pick(rng, lastNames)}
+		}
+		first := pick(rng, firstNames)
+		student.Name = first + " " + fam.lastName
+		student.Email = fmt.Sprintf("%s.%s.%d@example.com", strings.ToLower(first), strings.ToLower(fam.lastName), i+1)
+
+		minor := student.Age < cfg.GuardianRequiredBelowAge
+		switch {
+		case len(fam.guardians) > 0:
+			for j, id := range fam.guardians {
+				student.Guardians = append(student.Guardians, model.StudentGuardian{
+					GuardianID:   id,
+					Relationship: pick(rng, relationships),
+					IsPrimary:    j == 0,
+				})
+			}
+		case minor || cfg.Guardians:
+			count := 1
+			if cfg.Guardians && rng.IntN(2) == 0 {
+				count = 2
+			}
+			for j := range count {
+				student.Guardians = append(student.Guardians, newGuardian(rng, fam.lastName, j == 0))
+			}
+		}
+
+		created, err := students.CreateStudent(ctx, student)
+		if err != nil {
+			return summary, fmt.Errorf("student %d: %w", i+1, err)
+		}
+		summary.Students++
+		if len(fam.guardians) == 0 && len(created.Guardians) > 0 {
+			for _, link := range created.Guardians {
+				fam.guardians = append(fam.guardians, link.GuardianID)
+			}
+			summary.Guardians += len(created.Guardians)
+			families = append(families, fam)
+		}
+	}
+	return summary, nil
+}
+
+func newGuardian(rng *rand.Rand, lastName string, primary bool) model.StudentGuardian {
+	first := pick(rng, firstNames)
+	return model.StudentGuardian{
+		Relationship: pick(rng, relationships),
+		IsPrimary:    primary,
+		Guardian: &model.Guardian{
+			Name:  first + " " + lastName,
+			Email: fmt.Sprintf("%s.%s.%d@example.net", strings.ToLower(first), strings.ToLower(lastName), rng.IntN(1000)),
+			// 555-01xx numbers are reserved for fiction.
+			Phone: fmt.Sprintf("+1-555-01%02d-%04d", rng.IntN(100), rng.IntN(10000)),
+		},
+	}
+}
+
+func pick(rng *rand.Rand, values []string) string {
+	return values[rng.IntN(len(values))]
+}
+
+// Reset deletes every student of every tenant, with their guardians,
+// promotions, audit trail, tombstones and files, including the files'
+// content in files. Tenants are kept.
+func Reset(ctx context.Context, db *gorm.DB, files storage.Store) error {
+	db = db.WithContext(ctx)
+	var stored []model.StudentFile
+	if err := db.Find(&stored).Error; err != nil {
+		return err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Session(&gorm.Session{AllowGlobalUpdate: true})
+		for _, table := range []any{
+			&model.StudentFile{}, &model.StudentTombstone{}, &model.AuditEntry{},
+			&model.PromotionRecord{}, &model.PromotionBatch{}, &model.StudentGuardian{},
+			&model.Guardian{}, &model.Student{},
+		} {
+			if err := tx.Unscoped().Delete(table).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || files == nil {
+		return err
+	}
+
+	for _, file := range stored {
+		keys := []string{file.StorageKey}
+		if file.HasThumbnail {
+			keys = append(keys, file.ThumbnailKey())
+		}
+		for _, key := range keys {
+			if err := files.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				return err
+			}
+		}
+	}
+	return nil
+}
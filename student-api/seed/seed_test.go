@@ -0,0 +1,131 @@
+package seed
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/service"
+	"github.com/one2n/student-api/storage"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestService(t *testing.T) (*service.StudentService, *gorm.DB, *storage.Local) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	// Every connection to :memory: is a database of its own.
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	files, err := storage.NewLocal(t.TempDir())
+	assert.NoError(t, err)
+	cfg := service.DefaultConfig()
+	cfg.Files = files
+	return service.NewStudentServiceWithConfig(db, cfg), db, files
+}
+
+// roster lists the students in creation order.
+func roster(t *testing.T, students service.Students) []*model.Student {
+	list, total, err := students.ListStudents(context.Background(), model.StudentFilter{}, 100, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(list)), total)
+	return list
+}
+
+func TestRunIsDeterministic(t *testing.T) {
+	first, _, _ := newTestService(t)
+	second, _, _ := newTestService(t)
+	cfg := Config{Students: 40, Seed: 7, GuardianRequiredBelowAge: 18}
+
+	summary, err := Run(context.Background(), first, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, 40, summary.Students)
+	_, err = Run(context.Background(), second, cfg)
+	assert.NoError(t, err)
+
+	a, b := roster(t, first), roster(t, second)
+	if assert.Len(t, a, 40) && assert.Len(t, b, 40) {
+		for i := range a {
+			assert.Equal(t, a[i].Name, b[i].Name)
+			assert.Equal(t, a[i].Email, b[i].Email)
+			assert.Equal(t, a[i].Age, b[i].Age)
+			assert.Equal(t, a[i].Grade, b[i].Grade)
+		}
+	}
+
+	grades := make(map[string]bool)
+	for _, student := range a {
+		grades[student.Grade] = true
+	}
+	assert.Greater(t, len(grades), 6, "students are spread over the grades")
+
+	other, _, _ := newTestService(t)
+	_, err = Run(context.Background(), other, Config{Students: 40, Seed: 8, GuardianRequiredBelowAge: 18})
+	assert.NoError(t, err)
+	assert.NotEqual(t, a[0].Email, roster(t, other)[0].Email, "another seed creates other students")
+
+	_, err = Run(context.Background(), first, cfg)
+	assert.ErrorIs(t, err, service.ErrEmailExists, "seeding twice needs a reset")
+}
+
+func TestRunGuardians(t *testing.T) {
+	students, db, _ := newTestService(t)
+	summary, err := Run(context.Background(), students, Config{Students: 60, Seed: 3, Guardians: true, GuardianRequiredBelowAge: 18})
+	assert.NoError(t, err)
+
+	var guardians int64
+	db.Model(&model.Guardian{}).Count(&guardians)
+	assert.Equal(t, int64(summary.Guardians), guardians)
+
+	links, err := students.ListGuardiansForStudents(context.Background(), ids(roster(t, students)))
+	assert.NoError(t, err)
+	assert.Len(t, links, 60, "every student has a guardian")
+	var shared, second bool
+	count := make(map[string]int)
+	for _, list := range links {
+		second = second || len(list) > 1
+		for _, link := range list {
+			count[link.GuardianID]++
+			shared = shared || count[link.GuardianID] > 1
+		}
+	}
+	assert.True(t, second, "some students have two guardians")
+	assert.True(t, shared, "siblings share their guardians")
+}
+
+func TestReset(t *testing.T) {
+	students, db, files := newTestService(t)
+	_, err := Run(context.Background(), students, Config{Students: 10, Seed: 1, GuardianRequiredBelowAge: 18})
+	assert.NoError(t, err)
+	list := roster(t, students)
+	var photo bytes.Buffer
+	assert.NoError(t, png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 8, 8))))
+	file, err := students.UploadFile(context.Background(), list[0].ID, model.FileKindPhoto, "photo.png", &photo)
+	assert.NoError(t, err)
+	assert.NoError(t, students.DeleteStudent(context.Background(), list[1].ID))
+
+	assert.NoError(t, Reset(context.Background(), db, files))
+	for _, table := range []any{&model.Student{}, &model.Guardian{}, &model.StudentGuardian{}, &model.AuditEntry{}, &model.StudentFile{}} {
+		var count int64
+		assert.NoError(t, db.Unscoped().Model(table).Count(&count).Error)
+		assert.Zero(t, count, "%T", table)
+	}
+	_, err = files.Open(file.StorageKey)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	_, err = Run(context.Background(), students, Config{Students: 10, Seed: 1, GuardianRequiredBelowAge: 18})
+	assert.NoError(t, err, "the same seed can be used again after a reset")
+}
+
+func ids(students []*model.Student) []string {
+	ids := make([]string, len(students))
+	for i, student := range students {
+		ids[i] = student.ID
+	}
+	return ids
+}
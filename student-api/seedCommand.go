@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/seed"
+	"github.com/one2n/student-api/service"
+	"github.com/one2n/student-api/storage"
+)
+
+// runSeed runs the seed subcommand, which fills the database with synthetic
+// students:
+//
+//	student-api seed [-reset] [-students 100] [-seed 1] [-guardians] [-tenant default]
+func runSeed(args []string) error {
+	cfg := seed.DefaultConfig()
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	reset := flags.Bool("reset", false, "delete every student, of every tenant, first")
+	tenant := flags.String("tenant", model.DefaultTenantID, "id or slug of the tenant to seed")
+	flags.IntVar(&cfg.Students, "students", cfg.Students, "number of students to create")
+	flags.Uint64Var(&cfg.Seed, "seed", cfg.Seed, "seed of the generated data; the same seed creates the same students")
+	flags.BoolVar(&cfg.Guardians, "guardians", cfg.Guardians, "give adults guardians too, and some students a second one")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if cfg.Students < 0 {
+		return fmt.Errorf("invalid -students %d", cfg.Students)
+	}
+
+	serviceConfig := loadServiceConfig()
+	cfg.GuardianRequiredBelowAge = serviceConfig.GuardianRequiredBelowAge
+
+	db, err := setupDatabase()
+	if err != nil {
+		return err
+	}
+	files, err := storage.NewLocal(getEnv("FILES_DIR", "data/files"))
+	if err != nil {
+		return err
+	}
+	serviceConfig.Files = files
+	studentService := service.NewStudentServiceWithConfig(db, serviceConfig)
+
+	ctx := context.Background()
+	target, err := service.NewTenantService(db).ResolveTenant(ctx, *tenant)
+	if err != nil {
+		return fmt.Errorf("tenant %q: %w", *tenant, err)
+	}
+
+	if *reset {
+		if err := seed.Reset(ctx, db, files); err != nil {
+			return fmt.Errorf("failed to reset: %w", err)
+		}
+		log.Println("Deleted every student")
+	}
+
+	summary, err := seed.Run(ctx, studentService.ForTenant(target.ID), cfg)
+	log.Printf("Created %d students and %d guardians in tenant %s", summary.Students, summary.Guardians, target.Slug)
+	return err
+}
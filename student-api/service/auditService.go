@@ -0,0 +1,41 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/one2n/student-api/model"
+	"gorm.io/gorm"
+)
+
+// audit records a change to a student on db, which should be the transaction
+// making the change so that the entry commits or rolls back with it.
+func audit(db *gorm.DB, tenantID, studentID, action, detail string) error {
+	return db.Create(&model.AuditEntry{
+		TenantID:  tenantID,
+		StudentID: studentID,
+		Action:    action,
+		Detail:    detail,
+	}).Error
+}
+
+// changedFields lists the fields an update changes, by JSON name. Values are
+// left out on purpose: they may be personal data.
+func changedFields(before, after *model.Student) string {
+	var changed []string
+	if before.Name != after.Name {
+		changed = append(changed, "name")
+	}
+	if before.Email != after.Email {
+		changed = append(changed, "email")
+	}
+	if before.Age != after.Age {
+		changed = append(changed, "age")
+	}
+	if before.Grade != after.Grade {
+		changed = append(changed, "grade")
+	}
+	if len(changed) == 0 {
+		return "no changes"
+	}
+	return "changed " + strings.Join(changed, ", ")
+}
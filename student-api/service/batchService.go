@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/one2n/student-api/events"
+	"github.com/one2n/student-api/model"
+	"gorm.io/gorm"
+)
+
+// ErrBatchAborted marks operations of an atomic batch that were rolled back
+// or skipped because another operation failed.
+var ErrBatchAborted = errors.New("batch aborted")
+
+// BatchOutcome is the result of one batch operation. Err is nil on success.
+type BatchOutcome struct {
+	Op      string
+	ID      string
+	Student *model.Student
+	Err     error
+}
+
+// withDB returns a copy of the service that runs its queries on db, which is
+// typically an open transaction.
+func (s *StudentService) withDB(db *gorm.DB) *StudentService {
+	clone := *s
+	clone.db = db
+	clone.nested = true
+	return &clone
+}
+
+// RunBatch applies create, update and delete operations through the regular
+// service methods. When atomic is set they share one transaction and the first
+// failure rolls back the whole batch; otherwise each one stands on its own.
+func (s *StudentService) RunBatch(ctx context.Context, ops []model.BatchOperation, atomic bool) ([]BatchOutcome, error) {
+	if len(ops) == 0 {
+		return nil, invalid("batch must contain at least one operation")
+	}
+	if s.cfg.MaxBatchOperations > 0 && len(ops) > s.cfg.MaxBatchOperations {
+		return nil, invalid("batch has %d operations, the limit is %d", len(ops), s.cfg.MaxBatchOperations)
+	}
+
+	outcomes := make([]BatchOutcome, len(ops))
+	if !atomic {
+		for i, op := range ops {
+			outcomes[i] = s.runOperation(ctx, op)
+		}
+		return outcomes, nil
+	}
+
+	failed := -1
+	var pending []events.Event
+	err := s.transaction(ctx, func(tx *gorm.DB) error {
+		failed, pending = -1, nil
+		txService := s.withDB(tx)
+		txService.pending = &pending
+		for i, op := range ops {
+			if op.Student != nil {
+				// CreateStudent fills in the student it is given; a retry
+				// must start from the student as requested.
+				student := *op.Student
+				op.Student = &student
+			}
+			outcomes[i] = txService.runOperation(ctx, op)
+			if outcomes[i].Err != nil {
+				failed = i
+				return outcomes[i].Err
+			}
+		}
+		return nil
+	})
+	if err != nil && failed < 0 {
+		return nil, err
+	}
+	if failed < 0 {
+		for _, event := range pending {
+			s.cfg.Publisher.Publish(event)
+		}
+	} else {
+		for i := range ops {
+			if i == failed {
+				continue
+			}
+			outcomes[i] = BatchOutcome{
+				Op:  ops[i].Op,
+				ID:  ops[i].ID,
+				Err: fmt.Errorf("%w: operation %d failed", ErrBatchAborted, failed),
+			}
+		}
+	}
+	return outcomes, nil
+}
+
+func (s *StudentService) runOperation(ctx context.Context, op model.BatchOperation) BatchOutcome {
+	outcome := BatchOutcome{Op: op.Op, ID: op.ID}
+	switch op.Op {
+	case model.BatchCreate:
+		if op.Student == nil {
+			outcome.Err = invalid("create requires a student")
+			return outcome
+		}
+		outcome.Student, outcome.Err = s.CreateStudent(ctx, op.Student)
+		if outcome.Err == nil {
+			outcome.ID = outcome.Student.ID
+		}
+	case model.BatchUpdate:
+		if op.ID == "" || op.Student == nil {
+			outcome.Err = invalid("update requires an id and a student")
+			return outcome
+		}
+		outcome.Student, outcome.Err = s.UpdateStudent(ctx, op.ID, op.Student)
+	case model.BatchDelete:
+		if op.ID == "" {
+			outcome.Err = invalid("delete requires an id")
+			return outcome
+		}
+		outcome.Err = s.DeleteStudent(ctx, op.ID)
+	default:
+		outcome.Err = invalid("unknown operation %q", op.Op)
+	}
+	return outcome
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/one2n/student-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunBatch(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewStudentService(db)
+
+	existing, err := service.CreateStudent(context.Background(), &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+
+	ops := []model.BatchOperation{
+		{Op: model.BatchCreate, Student: &model.Student{Name: "Ben", Email: "ben@example.com", Age: 20, Grade: "10"}},
+		{Op: model.BatchUpdate, ID: existing.ID, Student: &model.Student{Name: "Ann B", Email: "ann@example.com", Age: 21, Grade: "11"}},
+		{Op: model.BatchDelete, ID: "missing"},
+	}
+
+	t.Run("atomic batch rolls back on failure", func(t *testing.T) {
+		outcomes, err := service.RunBatch(context.Background(), ops, true)
+		assert.NoError(t, err)
+		assert.Len(t, outcomes, 3)
+		assert.ErrorIs(t, outcomes[0].Err, ErrBatchAborted)
+		assert.ErrorIs(t, outcomes[1].Err, ErrBatchAborted)
+		assert.ErrorIs(t, outcomes[2].Err, ErrStudentNotFound)
+
+		students, err := service.GetAllStudents(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, students, 1)
+		assert.Equal(t, "Ann", students[0].Name)
+	})
+
+	t.Run("independent operations", func(t *testing.T) {
+		outcomes, err := service.RunBatch(context.Background(), ops, false)
+		assert.NoError(t, err)
+		assert.NoError(t, outcomes[0].Err)
+		assert.NotEmpty(t, outcomes[0].ID)
+		assert.NoError(t, outcomes[1].Err)
+		assert.ErrorIs(t, outcomes[2].Err, ErrStudentNotFound)
+
+		updated, err := service.GetStudentByID(context.Background(), existing.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Ann B", updated.Name)
+	})
+
+	t.Run("atomic batch commits", func(t *testing.T) {
+		outcomes, err := service.RunBatch(context.Background(), []model.BatchOperation{
+			{Op: model.BatchCreate, Student: &model.Student{Name: "Cat", Email: "cat@example.com", Age: 20, Grade: "10"}},
+			{Op: model.BatchDelete, ID: existing.ID},
+		}, true)
+		assert.NoError(t, err)
+		for _, outcome := range outcomes {
+			assert.NoError(t, outcome.Err)
+		}
+		_, err = service.GetStudentByID(context.Background(), existing.ID)
+		assert.ErrorIs(t, err, ErrStudentNotFound)
+	})
+}
+
+func TestRunBatchLimit(t *testing.T) {
+	db := setupTestDB(t)
+	cfg := DefaultConfig()
+	cfg.MaxBatchOperations = 1
+	service := NewStudentServiceWithConfig(db, cfg)
+
+	_, err := service.RunBatch(context.Background(), []model.BatchOperation{
+		{Op: model.BatchDelete, ID: "a"},
+		{Op: model.BatchDelete, ID: "b"},
+	}, false)
+	assert.EqualError(t, err, "batch has 2 operations, the limit is 1")
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/one2n/student-api/cache"
+	"github.com/one2n/student-api/model"
+)
+
+// cacheSchemaVersion is part of every cache key. Bump it when the cached
+// shape of model.Student changes so old entries are never decoded.
+const cacheSchemaVersion = "v1"
+
+const generationKey = "students:" + cacheSchemaVersion + ":generation"
+
+// CacheStats counts cache lookups and invalidations.
+type CacheStats struct {
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Invalidations int64 `json:"invalidations"`
+	Errors        int64 `json:"errors"`
+}
+
+// CachedStudentService is a read-through cache in front of another Students
+// implementation. GetStudentByID and GetAllStudents are served from the store
+// when possible; every write invalidates the entries it affects. Bulk writes
+// (promotion, batches) bump a generation counter that is part of every key,
+// which invalidates everything at once.
+//
+// Methods not overridden here are forwarded to the wrapped service as is, so
+// any new write method must be added here to keep the cache coherent.
+type CachedStudentService struct {
+	Students
+	store    cache.Store
+	ttl      time.Duration
+	tenantID string
+	// counters is shared by the per-tenant copies made by ForTenant.
+	counters *cacheCounters
+}
+
+type cacheCounters struct {
+	hits, misses, invalidations, errors atomic.Int64
+}
+
+func NewCachedStudentService(inner Students, store cache.Store, ttl time.Duration) *CachedStudentService {
+	return &CachedStudentService{
+		Students: inner,
+		store:    store,
+		ttl:      ttl,
+		tenantID: model.DefaultTenantID,
+		counters: &cacheCounters{},
+	}
+}
+
+func (c *CachedStudentService) Stats() CacheStats {
+	return CacheStats{
+		Hits:          c.counters.hits.Load(),
+		Misses:        c.counters.misses.Load(),
+		Invalidations: c.counters.invalidations.Load(),
+		Errors:        c.counters.errors.Load(),
+	}
+}
+
+// ForTenant wraps the tenant's service in a cache whose keys are kept apart
+// from every other tenant's.
+func (c *CachedStudentService) ForTenant(tenantID string) Students {
+	clone := *c
+	clone.Students = c.Students.ForTenant(tenantID)
+	clone.tenantID = tenantID
+	return &clone
+}
+
+func (c *CachedStudentService) GetStudentByID(ctx context.Context, id string) (*model.Student, error) {
+	key := c.key("id:" + id)
+	var student model.Student
+	if c.lookup(key, &student) {
+		return &student, nil
+	}
+
+	found, err := c.Students.GetStudentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	c.fill(key, found)
+	return found, nil
+}
+
+func (c *CachedStudentService) GetAllStudents(ctx context.Context) ([]*model.Student, error) {
+	key := c.key("list")
+	var students []*model.Student
+	if c.lookup(key, &students) {
+		return students, nil
+	}
+
+	found, err := c.Students.GetAllStudents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.fill(key, found)
+	return found, nil
+}
+
+func (c *CachedStudentService) CreateStudent(ctx context.Context, student *model.Student) (*model.Student, error) {
+	created, err := c.Students.CreateStudent(ctx, student)
+	if err == nil {
+		c.invalidate("list")
+	}
+	return created, err
+}
+
+func (c *CachedStudentService) UpdateStudent(ctx context.Context, id string, updatedStudent *model.Student) (*model.Student, error) {
+	updated, err := c.Students.UpdateStudent(ctx, id, updatedStudent)
+	if err == nil {
+		c.invalidate("list", "id:"+id)
+	}
+	return updated, err
+}
+
+func (c *CachedStudentService) DeleteStudent(ctx context.Context, id string) error {
+	err := c.Students.DeleteStudent(ctx, id)
+	if err == nil {
+		c.invalidate("list", "id:"+id)
+	}
+	return err
+}
+
+func (c *CachedStudentService) EraseStudent(ctx context.Context, id string) (*model.StudentTombstone, error) {
+	tombstone, err := c.Students.EraseStudent(ctx, id)
+	if err == nil {
+		c.invalidate("list", "id:"+id)
+	}
+	return tombstone, err
+}
+
+// Guardians are embedded in the cached GetStudentByID response.
+
+func (c *CachedStudentService) AddGuardian(ctx context.Context, studentID string, link *model.StudentGuardian) (*model.StudentGuardian, error) {
+	created, err := c.Students.AddGuardian(ctx, studentID, link)
+	if err == nil {
+		c.invalidate("id:" + studentID)
+	}
+	return created, err
+}
+
+func (c *CachedStudentService) UpdateGuardian(ctx context.Context, studentID, guardianID string, update *model.StudentGuardian) (*model.StudentGuardian, error) {
+	updated, err := c.Students.UpdateGuardian(ctx, studentID, guardianID, update)
+	if err == nil {
+		// A guardian shared between siblings shows up under every one of
+		// them, so drop everything rather than just this student.
+		c.invalidateAll()
+	}
+	return updated, err
+}
+
+func (c *CachedStudentService) RemoveGuardian(ctx context.Context, studentID, guardianID string) error {
+	err := c.Students.RemoveGuardian(ctx, studentID, guardianID)
+	if err == nil {
+		c.invalidate("id:" + studentID)
+	}
+	return err
+}
+
+func (c *CachedStudentService) PromoteGrade(ctx context.Context, grade string, dryRun bool) (*model.PromotionBatch, error) {
+	batch, err := c.Students.PromoteGrade(ctx, grade, dryRun)
+	if err == nil && !dryRun && batch.StudentCount > 0 {
+		c.invalidateAll()
+	}
+	return batch, err
+}
+
+func (c *CachedStudentService) RunBatch(ctx context.Context, ops []model.BatchOperation, atomic bool) ([]BatchOutcome, error) {
+	outcomes, err := c.Students.RunBatch(ctx, ops, atomic)
+	if err == nil {
+		c.invalidateAll()
+	}
+	return outcomes, err
+}
+
+// key builds a versioned cache key: schema version, then the current
+// generation, then the tenant and the entry name.
+func (c *CachedStudentService) key(name string) string {
+	generation := "0"
+	if value, ok, err := c.store.Get(generationKey); err != nil {
+		c.storeError("read generation", err)
+	} else if ok {
+		generation = string(value)
+	}
+	return fmt.Sprintf("students:%s:g%s:t%s:%s", cacheSchemaVersion, generation, c.tenantID, name)
+}
+
+func (c *CachedStudentService) lookup(key string, dest any) bool {
+	value, ok, err := c.store.Get(key)
+	if err != nil {
+		c.storeError("get "+key, err)
+	}
+	if ok && err == nil {
+		if err := json.Unmarshal(value, dest); err == nil {
+			c.counters.hits.Add(1)
+			return true
+		}
+	}
+	c.counters.misses.Add(1)
+	return false
+}
+
+func (c *CachedStudentService) fill(key string, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		c.storeError("encode "+key, err)
+		return
+	}
+	if err := c.store.Set(key, data, c.ttl); err != nil {
+		c.storeError("set "+key, err)
+	}
+}
+
+func (c *CachedStudentService) invalidate(names ...string) {
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = c.key(name)
+	}
+	if err := c.store.Delete(keys...); err != nil {
+		c.storeError("delete", err)
+		// Fall back to a generation bump so stale entries are not served.
+		c.invalidateAll()
+		return
+	}
+	c.counters.invalidations.Add(int64(len(keys)))
+}
+
+func (c *CachedStudentService) invalidateAll() {
+	generation, err := c.store.Incr(generationKey)
+	if err != nil {
+		c.storeError("bump generation", err)
+		return
+	}
+	c.counters.invalidations.Add(1)
+	log.Printf("Student cache moved to generation %d", generation)
+}
+
+func (c *CachedStudentService) storeError(op string, err error) {
+	c.counters.errors.Add(1)
+	log.Printf("Student cache %s failed: %v", op, err)
+}
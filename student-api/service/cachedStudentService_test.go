@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/one2n/student-api/cache"
+	"github.com/one2n/student-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedStudentService(t *testing.T) {
+	db := setupTestDB(t)
+	store := cache.NewFake()
+	service := NewCachedStudentService(NewStudentService(db), store, time.Minute)
+
+	created, err := service.CreateStudent(context.Background(), &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+
+	_, err = service.GetStudentByID(context.Background(), created.ID)
+	assert.NoError(t, err)
+	_, err = service.GetStudentByID(context.Background(), created.ID)
+	assert.NoError(t, err)
+	_, err = service.GetAllStudents(context.Background())
+	assert.NoError(t, err)
+	students, err := service.GetAllStudents(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, students, 1)
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 2, Invalidations: 1}, service.Stats())
+
+	// Writes bypassing the decorator are invisible until the entry is invalidated.
+	assert.NoError(t, db.Model(&model.Student{}).Where("id = ?", created.ID).Update("name", "Sneaky").Error)
+	cached, err := service.GetStudentByID(context.Background(), created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Ann", cached.Name)
+
+	_, err = service.UpdateStudent(context.Background(), created.ID, &model.Student{Name: "Ann B", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	fresh, err := service.GetStudentByID(context.Background(), created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Ann B", fresh.Name)
+
+	_, err = service.PromoteGrade(context.Background(), "10", false)
+	assert.NoError(t, err)
+	promoted, err := service.GetStudentByID(context.Background(), created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "11", promoted.Grade)
+
+	assert.NoError(t, service.DeleteStudent(context.Background(), created.ID))
+	_, err = service.GetStudentByID(context.Background(), created.ID)
+	assert.ErrorIs(t, err, ErrStudentNotFound)
+	students, err = service.GetAllStudents(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, students)
+}
+
+func TestCachedStudentServiceInvalidatesOnGuardianChanges(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewCachedStudentService(NewStudentService(db), cache.NewLRU(100), time.Minute)
+
+	created, err := service.CreateStudent(context.Background(), &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	cached, err := service.GetStudentByID(context.Background(), created.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, cached.Guardians)
+
+	link := newGuardianLink("Mary", model.RelationshipMother)
+	_, err = service.AddGuardian(context.Background(), created.ID, &link)
+	assert.NoError(t, err)
+
+	fresh, err := service.GetStudentByID(context.Background(), created.ID)
+	assert.NoError(t, err)
+	assert.Len(t, fresh.Guardians, 1)
+}
+
+func TestCachedStudentServiceInvalidatesOnErase(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewCachedStudentService(NewStudentService(db), cache.NewLRU(100), time.Minute)
+
+	created, err := service.CreateStudent(context.Background(), &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	_, err = service.GetStudentByID(context.Background(), created.ID)
+	assert.NoError(t, err)
+	_, err = service.GetAllStudents(context.Background())
+	assert.NoError(t, err)
+
+	_, err = service.EraseStudent(context.Background(), created.ID)
+	assert.NoError(t, err)
+	_, err = service.GetStudentByID(context.Background(), created.ID)
+	assert.ErrorIs(t, err, ErrStudentNotFound, "erased data is not served from the cache")
+	students, err := service.GetAllStudents(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, students)
+}
+
+func TestCachedStudentServiceSurvivesStoreErrors(t *testing.T) {
+	db := setupTestDB(t)
+	store := cache.NewFake()
+	store.Err = errors.New("connection refused")
+	service := NewCachedStudentService(NewStudentService(db), store, time.Minute)
+
+	created, err := service.CreateStudent(context.Background(), &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	found, err := service.GetStudentByID(context.Background(), created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, found.ID)
+	assert.Positive(t, service.Stats().Errors)
+}
+
+func TestCachedStudentServiceKeepsTenantsApart(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewCachedStudentService(NewStudentService(db), cache.NewLRU(100), time.Minute)
+	north := service.ForTenant("north")
+	south := service.ForTenant("south")
+
+	created, err := north.CreateStudent(context.Background(), &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	_, err = north.GetStudentByID(context.Background(), created.ID)
+	assert.NoError(t, err)
+	students, err := north.GetAllStudents(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, students, 1)
+
+	_, err = south.GetStudentByID(context.Background(), created.ID)
+	assert.ErrorIs(t, err, ErrStudentNotFound, "a cached entry of another tenant is never served")
+	students, err = south.GetAllStudents(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, students)
+	assert.Equal(t, int64(4), service.Stats().Misses, "per-tenant copies share the counters")
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/one2n/student-api/pii"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// normalizeEmail is the form emails are stored in: without surrounding
+// whitespace and in lower case. Mail servers treat the local part as case
+// sensitive in theory only.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// emailKey is the form of a normalized email that uniqueness is decided on.
+// With StripPlusAddressing, "ann+school@example.com" and "ann@example.com"
+// are the same address.
+func emailKey(cfg Config, email string) string {
+	if !cfg.StripPlusAddressing {
+		return email
+	}
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return email
+	}
+	if tag := strings.IndexByte(local, '+'); tag > 0 {
+		local = local[:tag]
+	}
+	return local + "@" + domain
+}
+
+// emailIndex returns the blind index an email is looked up and kept unique
+// by.
+func (s *StudentService) emailIndex(email string) string {
+	return pii.BlindIndex(emailKey(s.cfg, normalizeEmail(email)))
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation
+// from Postgres or SQLite. The SQLite driver's error types only exist when
+// built with cgo, so its errors are decoded by gorm's SQLite dialector.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505" // unique_violation
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if errors.Is(sqlite.Dialector{}.Translate(e), gorm.ErrDuplicatedKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// emailConflict turns the unique violation from writing a student row into
+// ErrEmailExists. The email index is the only unique key a student write can
+// break; IDs are random.
+func emailConflict(err error) error {
+	if isUniqueViolation(err) {
+		return ErrEmailExists
+	}
+	return err
+}
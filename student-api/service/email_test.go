@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/pii"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestEmailNormalization(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewStudentService(db)
+
+	created, err := service.CreateStudent(context.Background(), &model.Student{Name: "John Doe", Email: "  John.Doe@Example.COM ", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	assert.Equal(t, "john.doe@example.com", created.Email)
+
+	for _, email := range []string{"john.doe@example.com", "JOHN.DOE@EXAMPLE.COM", "\tjohn.doe@example.com\n"} {
+		_, err := service.CreateStudent(context.Background(), &model.Student{Name: "Copy", Email: email, Age: 20, Grade: "10"})
+		assert.ErrorIs(t, err, ErrEmailExists, email)
+	}
+
+	// Plus addresses are distinct unless stripping is turned on.
+	_, err = service.CreateStudent(context.Background(), &model.Student{Name: "Tagged", Email: "john.doe+school@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+
+	other, err := service.CreateStudent(context.Background(), &model.Student{Name: "Jane Doe", Email: "jane@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	_, err = service.UpdateStudent(context.Background(), other.ID, &model.Student{Name: "Jane Doe", Email: "John.Doe@example.com", Age: 20, Grade: "10"})
+	assert.ErrorIs(t, err, ErrEmailExists)
+	updated, err := service.UpdateStudent(context.Background(), other.ID, &model.Student{Name: "Jane Doe", Email: "Jane@Example.com", Age: 21, Grade: "10"})
+	assert.NoError(t, err, "a student keeps their own email")
+	assert.Equal(t, "jane@example.com", updated.Email)
+
+	cfg := DefaultConfig()
+	cfg.StripPlusAddressing = true
+	stripping := NewStudentServiceWithConfig(db, cfg)
+	_, err = stripping.CreateStudent(context.Background(), &model.Student{Name: "Tagged", Email: "john.doe+home@example.com", Age: 20, Grade: "10"})
+	assert.ErrorIs(t, err, ErrEmailExists)
+	_, err = stripping.CreateStudent(context.Background(), &model.Student{Name: "Tagged", Email: "jane+1@example.com", Age: 20, Grade: "10"})
+	assert.ErrorIs(t, err, ErrEmailExists)
+}
+
+func TestEmailKey(t *testing.T) {
+	strip := Config{StripPlusAddressing: true}
+	assert.Equal(t, "ann+tag@example.com", emailKey(Config{}, "ann+tag@example.com"))
+	assert.Equal(t, "ann@example.com", emailKey(strip, "ann+tag@example.com"))
+	assert.Equal(t, "ann@example.com", emailKey(strip, "ann+a+b@example.com"))
+	assert.Equal(t, "+ann@example.com", emailKey(strip, "+ann@example.com"), "an address that is all tag is kept")
+	assert.Equal(t, "not-an-email", emailKey(strip, "not-an-email"))
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	db := setupTestDB(t)
+	assert.NoError(t, db.Exec("CREATE TABLE things (name TEXT UNIQUE)").Error)
+	assert.NoError(t, db.Exec("INSERT INTO things (name) VALUES ('a')").Error)
+	err := db.Exec("INSERT INTO things (name) VALUES ('a')").Error
+	assert.True(t, isUniqueViolation(err), "sqlite: %v", err)
+
+	assert.True(t, isUniqueViolation(fmt.Errorf("saving: %w", &pgconn.PgError{Code: "23505"})))
+	assert.False(t, isUniqueViolation(&pgconn.PgError{Code: "23503"}))
+	assert.True(t, isUniqueViolation(gorm.ErrDuplicatedKey))
+	assert.False(t, isUniqueViolation(gorm.ErrRecordNotFound))
+	assert.Equal(t, ErrEmailExists, emailConflict(gorm.ErrDuplicatedKey))
+	assert.Equal(t, gorm.ErrInvalidData, emailConflict(gorm.ErrInvalidData))
+}
+
+func TestProtectStudentsNormalizesEmails(t *testing.T) {
+	db := setupTestDB(t)
+	NewStudentService(db)
+	insert := func(id, email string) {
+		assert.NoError(t, db.Exec("INSERT INTO students (id, tenant_id, name, email, email_index, age, grade, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			id, model.DefaultTenantID, "Student "+id, email, pii.BlindIndex(email), 20, "10", model.StatusEnrolled).Error)
+	}
+	insert("a", " Ann@Example.com")
+	insert("b", "BOB@example.com")
+	insert("c", "bob@example.com")
+
+	changed, err := ProtectStudents(db, DefaultConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, changed, "the second bob conflicts and is left alone")
+	assert.Equal(t, "ann@example.com", storedRow(t, db, "a").Email)
+	assert.Equal(t, pii.BlindIndex("ann@example.com"), storedRow(t, db, "a").EmailIndex)
+	assert.Equal(t, "BOB@example.com", storedRow(t, db, "b").Email)
+
+	found, err := NewStudentService(db).CreateStudent(context.Background(), &model.Student{Name: "Ann", Email: "ANN@example.com", Age: 20, Grade: "10"})
+	assert.Nil(t, found)
+	assert.ErrorIs(t, err, ErrEmailExists)
+}
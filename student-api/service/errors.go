@@ -0,0 +1,36 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrStudentNotFound     = errors.New("student not found")
+	ErrEmailExists         = errors.New("email already exists")
+	ErrGuardianNotFound    = errors.New("guardian not found")
+	ErrGuardianLinked      = errors.New("guardian is already linked to this student")
+	ErrTenantNotFound      = errors.New("tenant not found")
+	ErrTenantExists        = errors.New("tenant slug already exists")
+	ErrInvalidAPIKey       = errors.New("invalid API key")
+	ErrTenantMismatch      = errors.New("API key does not belong to the requested tenant")
+	ErrAPIKeyRequired      = errors.New("an API key is required to act for a tenant other than the default")
+	ErrFileNotFound        = errors.New("file not found")
+	ErrFileTooLarge        = errors.New("file is too large")
+	ErrUnsupportedFileType = errors.New("unsupported file type")
+	ErrFilesDisabled       = errors.New("file storage is not configured")
+)
+
+// ValidationError reports input that breaks a business rule, as opposed to a
+// missing record or a database failure.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func invalid(format string, args ...any) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/one2n/student-api/events"
+	"github.com/one2n/student-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingPublisher struct {
+	events []events.Event
+}
+
+func (p *recordingPublisher) Publish(event events.Event) {
+	p.events = append(p.events, event)
+}
+
+func (p *recordingPublisher) types() []string {
+	types := make([]string, len(p.events))
+	for i, event := range p.events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func TestStudentServicePublishesChanges(t *testing.T) {
+	db := setupTestDB(t)
+	publisher := &recordingPublisher{}
+	cfg := DefaultConfig()
+	cfg.Publisher = publisher
+	service := NewStudentServiceWithConfig(db, cfg)
+
+	created, err := service.CreateStudent(context.Background(), &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	_, err = service.UpdateStudent(context.Background(), created.ID, &model.Student{Name: "Ann B", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	_, err = service.PromoteGrade(context.Background(), "10", false)
+	assert.NoError(t, err)
+	assert.NoError(t, service.DeleteStudent(context.Background(), created.ID))
+
+	assert.Equal(t, []string{events.StudentCreated, events.StudentUpdated, events.StudentUpdated, events.StudentDeleted}, publisher.types())
+	assert.Equal(t, "11", publisher.events[2].Student.Grade)
+	assert.Equal(t, created.ID, publisher.events[3].StudentID)
+
+	// Failed writes and rolled back batches publish nothing.
+	publisher.events = nil
+	_, err = service.CreateStudent(context.Background(), &model.Student{Name: "Ann", Email: "ann@example.com", Age: 0, Grade: "10"})
+	assert.Error(t, err)
+	_, err = service.RunBatch(context.Background(), []model.BatchOperation{
+		{Op: model.BatchCreate, Student: &model.Student{Name: "Ben", Email: "ben@example.com", Age: 20, Grade: "10"}},
+		{Op: model.BatchDelete, ID: "missing"},
+	}, true)
+	assert.NoError(t, err)
+	assert.Empty(t, publisher.events)
+
+	_, err = service.RunBatch(context.Background(), []model.BatchOperation{
+		{Op: model.BatchCreate, Student: &model.Student{Name: "Ben", Email: "ben@example.com", Age: 20, Grade: "10"}},
+	}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{events.StudentCreated}, publisher.types())
+}
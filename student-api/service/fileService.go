@@ -0,0 +1,257 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/storage"
+	"gorm.io/gorm"
+)
+
+// fileTypes lists the content types accepted for each kind of file, as
+// sniffed from the content rather than taken from the client.
+var fileTypes = map[string][]string{
+	model.FileKindPhoto:    {"image/jpeg", "image/png", "image/gif"},
+	model.FileKindDocument: {"application/pdf", "image/jpeg", "image/png", "image/gif"},
+}
+
+// UploadFile stores a file for a student. The content type is sniffed from
+// the first bytes and must suit kind; uploads over the configured size are
+// rejected. Images get a thumbnail. A new photo replaces the previous one.
+func (s *StudentService) UploadFile(ctx context.Context, studentID, kind, filename string, content io.Reader) (*model.StudentFile, error) {
+	if s.cfg.Files == nil {
+		return nil, ErrFilesDisabled
+	}
+	allowed, ok := fileTypes[kind]
+	if !ok {
+		return nil, invalid("invalid file kind: %q", kind)
+	}
+	if _, err := findStudent(s.db.WithContext(ctx), studentID); err != nil {
+		return nil, err
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, invalid("file is empty")
+	}
+	contentType := http.DetectContentType(head[:n])
+	if !contains(allowed, contentType) {
+		return nil, fmt.Errorf("%w: %s is not accepted for a %s", ErrUnsupportedFileType, contentType, kind)
+	}
+
+	id := uuid.New().String()
+	file := &model.StudentFile{
+		ID:          id,
+		TenantID:    s.tenantID,
+		StudentID:   studentID,
+		Kind:        kind,
+		Filename:    cleanFilename(filename),
+		ContentType: contentType,
+		StorageKey:  path.Join(s.tenantID, studentID, id),
+	}
+
+	hash := sha256.New()
+	size := &byteCounter{}
+	body := io.LimitReader(io.MultiReader(bytes.NewReader(head[:n]), content), s.cfg.MaxFileSize+1)
+	if err := s.cfg.Files.Put(file.StorageKey, io.TeeReader(body, io.MultiWriter(hash, size))); err != nil {
+		s.removeBlobs(file)
+		return nil, err
+	}
+	if s.cfg.MaxFileSize > 0 && size.n > s.cfg.MaxFileSize {
+		s.removeBlobs(file)
+		return nil, fmt.Errorf("%w: the limit is %d bytes", ErrFileTooLarge, s.cfg.MaxFileSize)
+	}
+	file.Size = size.n
+	file.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	if strings.HasPrefix(contentType, "image/") {
+		if err := s.storeThumbnail(file); err != nil {
+			s.removeBlobs(file)
+			return nil, err
+		}
+	}
+
+	var replaced []model.StudentFile
+	err = s.transaction(ctx, func(tx *gorm.DB) error {
+		if kind == model.FileKindPhoto {
+			if err := tx.Where("student_id = ? AND kind = ?", studentID, kind).Find(&replaced).Error; err != nil {
+				return err
+			}
+			for _, old := range replaced {
+				if err := tx.Delete(&old).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if err := tx.Create(file).Error; err != nil {
+			return err
+		}
+		return audit(tx, s.tenantID, studentID, model.AuditFileUploaded, kind+" "+id)
+	})
+	if err != nil {
+		s.removeBlobs(file)
+		return nil, err
+	}
+	for i := range replaced {
+		s.removeBlobs(&replaced[i])
+	}
+	return file, nil
+}
+
+func (s *StudentService) storeThumbnail(file *model.StudentFile) error {
+	original, err := s.cfg.Files.Open(file.StorageKey)
+	if err != nil {
+		return err
+	}
+	defer original.Close()
+
+	thumbnail, err := makeThumbnail(original)
+	if errors.Is(err, errImageTooLarge) {
+		// Keep the upload; it just goes without a thumbnail.
+		return nil
+	} else if err != nil {
+		return invalid("image cannot be decoded: %v", err)
+	}
+	if err := s.cfg.Files.Put(file.ThumbnailKey(), bytes.NewReader(thumbnail)); err != nil {
+		return err
+	}
+	file.HasThumbnail = true
+	return nil
+}
+
+func (s *StudentService) ListFiles(ctx context.Context, studentID string) ([]model.StudentFile, error) {
+	if _, err := findStudent(s.db.WithContext(ctx), studentID); err != nil {
+		return nil, err
+	}
+	files := []model.StudentFile{}
+	if err := s.db.WithContext(ctx).Where("student_id = ?", studentID).Order("created_at, id").Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// OpenFile returns a file's metadata and its content, or the content of its
+// thumbnail. The caller must close the content.
+func (s *StudentService) OpenFile(ctx context.Context, studentID, fileID string, thumbnail bool) (*model.StudentFile, io.ReadSeekCloser, error) {
+	if s.cfg.Files == nil {
+		return nil, nil, ErrFilesDisabled
+	}
+	file, err := findFile(s.db.WithContext(ctx), studentID, fileID)
+	if err != nil {
+		return nil, nil, err
+	}
+	key := file.StorageKey
+	if thumbnail {
+		if !file.HasThumbnail {
+			return nil, nil, ErrFileNotFound
+		}
+		key = file.ThumbnailKey()
+	}
+	content, err := s.cfg.Files.Open(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, ErrFileNotFound
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return file, content, nil
+}
+
+func (s *StudentService) DeleteFile(ctx context.Context, studentID, fileID string) error {
+	var file *model.StudentFile
+	err := s.transaction(ctx, func(tx *gorm.DB) error {
+		var err error
+		if file, err = findFile(tx, studentID, fileID); err != nil {
+			return err
+		}
+		if err := tx.Delete(file).Error; err != nil {
+			return err
+		}
+		return audit(tx, s.tenantID, studentID, model.AuditFileDeleted, file.Kind+" "+file.ID)
+	})
+	if err != nil {
+		return err
+	}
+	s.removeBlobs(file)
+	return nil
+}
+
+// removeBlobs deletes the stored content of a file whose row is gone or was
+// never written. Failures only leave garbage behind, so they are logged.
+func (s *StudentService) removeBlobs(file *model.StudentFile) {
+	if s.cfg.Files == nil {
+		return
+	}
+	keys := []string{file.StorageKey}
+	if file.HasThumbnail {
+		keys = append(keys, file.ThumbnailKey())
+	}
+	for _, key := range keys {
+		if err := s.cfg.Files.Delete(key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Failed to delete stored file %s: %v", key, err)
+		}
+	}
+}
+
+func findFile(db *gorm.DB, studentID, fileID string) (*model.StudentFile, error) {
+	var file model.StudentFile
+	err := db.Where("id = ? AND student_id = ?", fileID, studentID).First(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFileNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// cleanFilename keeps the base name of a client-supplied filename, without
+// control characters, so it is safe to echo in a Content-Disposition header.
+func cleanFilename(filename string) string {
+	filename = path.Base(strings.ReplaceAll(filename, `\`, "/"))
+	filename = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, filename)
+	if filename == "" || filename == "." || filename == "/" {
+		return "upload"
+	}
+	if len(filename) > 255 {
+		filename = filename[:255]
+	}
+	return filename
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type byteCounter struct {
+	n int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
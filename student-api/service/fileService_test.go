@@ -0,0 +1,133 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupFileService(t *testing.T, maxFileSize int64) (*StudentService, storage.Store) {
+	files, err := storage.NewLocal(t.TempDir())
+	assert.NoError(t, err)
+	cfg := DefaultConfig()
+	cfg.Files = files
+	cfg.MaxFileSize = maxFileSize
+	return NewStudentServiceWithConfig(setupTestDB(t), cfg), files
+}
+
+func testPNG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+const testPDF = "%PDF-1.4\n1 0 obj << /Type /Catalog >> endobj\ntrailer << /Root 1 0 R >>\n%%EOF\n"
+
+func TestUploadFile(t *testing.T) {
+	service, files := setupFileService(t, 1<<20)
+	student, err := service.CreateStudent(context.Background(), &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+
+	photo, err := service.UploadFile(context.Background(), student.ID, model.FileKindPhoto, `C:\photos\ann.png`, bytes.NewReader(testPNG(t, 600, 300)))
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", photo.ContentType)
+	assert.Equal(t, "ann.png", photo.Filename)
+	assert.True(t, photo.HasThumbnail)
+	assert.Len(t, photo.SHA256, 64)
+
+	_, thumb, err := service.OpenFile(context.Background(), student.ID, photo.ID, true)
+	assert.NoError(t, err)
+	decoded, format, err := image.Decode(thumb)
+	assert.NoError(t, err)
+	thumb.Close()
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, image.Pt(256, 128), decoded.Bounds().Size())
+
+	doc, err := service.UploadFile(context.Background(), student.ID, model.FileKindDocument, "report.pdf", strings.NewReader(testPDF))
+	assert.NoError(t, err)
+	assert.Equal(t, "application/pdf", doc.ContentType)
+	assert.Equal(t, int64(len(testPDF)), doc.Size)
+	assert.False(t, doc.HasThumbnail)
+	_, _, err = service.OpenFile(context.Background(), student.ID, doc.ID, true)
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	file, content, err := service.OpenFile(context.Background(), student.ID, doc.ID, false)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(content)
+	content.Close()
+	assert.Equal(t, testPDF, string(data))
+	assert.Equal(t, doc.ID, file.ID)
+
+	second, err := service.UploadFile(context.Background(), student.ID, model.FileKindPhoto, "new.png", bytes.NewReader(testPNG(t, 10, 10)))
+	assert.NoError(t, err)
+	list, err := service.ListFiles(context.Background(), student.ID)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2, "a new photo replaces the old one")
+	_, err = files.Open(photo.StorageKey)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = files.Open(photo.ThumbnailKey())
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	assert.NoError(t, service.DeleteFile(context.Background(), student.ID, second.ID))
+	_, _, err = service.OpenFile(context.Background(), student.ID, second.ID, false)
+	assert.ErrorIs(t, err, ErrFileNotFound)
+	assert.ErrorIs(t, service.DeleteFile(context.Background(), student.ID, second.ID), ErrFileNotFound)
+
+	tests := []struct {
+		name    string
+		kind    string
+		content []byte
+		wantErr error
+	}{
+		{name: "text is not accepted", kind: model.FileKindDocument, content: []byte("just text"), wantErr: ErrUnsupportedFileType},
+		{name: "a pdf is not a photo", kind: model.FileKindPhoto, content: []byte(testPDF), wantErr: ErrUnsupportedFileType},
+		{name: "too large", kind: model.FileKindDocument, content: append([]byte(testPDF), make([]byte, 1<<20)...), wantErr: ErrFileTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.UploadFile(context.Background(), student.ID, tt.kind, "upload", bytes.NewReader(tt.content))
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+
+	var validationErr *ValidationError
+	_, err = service.UploadFile(context.Background(), student.ID, "selfie", "a.png", bytes.NewReader(testPNG(t, 1, 1)))
+	assert.ErrorAs(t, err, &validationErr)
+	broken := testPNG(t, 20, 20)
+	_, err = service.UploadFile(context.Background(), student.ID, model.FileKindPhoto, "broken.png", bytes.NewReader(broken[:len(broken)/2]))
+	assert.ErrorAs(t, err, &validationErr, "truncated images are rejected")
+	_, err = service.UploadFile(context.Background(), "missing", model.FileKindDocument, "a.pdf", strings.NewReader(testPDF))
+	assert.ErrorIs(t, err, ErrStudentNotFound)
+	_, _, err = service.ForTenant("other").OpenFile(context.Background(), student.ID, doc.ID, false)
+	assert.ErrorIs(t, err, ErrFileNotFound)
+
+	list, err = service.ListFiles(context.Background(), student.ID)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1, "failed uploads leave no rows behind")
+
+	_, err = service.EraseStudent(context.Background(), student.ID)
+	assert.NoError(t, err)
+	_, err = files.Open(doc.StorageKey)
+	assert.ErrorIs(t, err, storage.ErrNotFound, "erasing a student removes its files")
+}
+
+func TestUploadFileWithoutStorage(t *testing.T) {
+	service := NewStudentService(setupTestDB(t))
+	_, err := service.UploadFile(context.Background(), "any", model.FileKindDocument, "a.pdf", strings.NewReader(testPDF))
+	assert.ErrorIs(t, err, ErrFilesDisabled)
+}
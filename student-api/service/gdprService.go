@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/one2n/student-api/events"
+	"github.com/one2n/student-api/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExportStudent gathers everything stored about a student: the record with
+// its guardians, its files, its promotion history and its audit trail. Soft-deleted
+// students are included, since their data is still held.
+func (s *StudentService) ExportStudent(ctx context.Context, id string) (*model.StudentExport, error) {
+	var student model.Student
+	err := s.db.WithContext(ctx).Unscoped().Preload("Guardians.Guardian").First(&student, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrStudentNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	export := &model.StudentExport{
+		ExportedAt: time.Now(),
+		Student:    &student,
+		Deleted:    student.DeletedAt.Valid,
+		Files:      []model.StudentFile{},
+		Promotions: []model.StudentPromotion{},
+		Audit:      []model.AuditEntry{},
+	}
+	if err := s.db.WithContext(ctx).Where("student_id = ?", id).Order("created_at, id").Find(&export.Files).Error; err != nil {
+		return nil, err
+	}
+	err = s.db.WithContext(ctx).Model(&model.PromotionRecord{}).
+		Select("promotion_records.batch_id, promotion_records.from_grade, promotion_records.to_grade, "+
+			"promotion_batches.graduated, promotion_batches.created_at AS promoted_at").
+		Joins("JOIN promotion_batches ON promotion_batches.id = promotion_records.batch_id").
+		Where("promotion_records.student_id = ?", id).
+		Order("promotion_batches.created_at").
+		Scan(&export.Promotions).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Where("student_id = ?", id).Order("id").Find(&export.Audit).Error; err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// EraseStudent permanently removes a student and everything tied to it:
+// guardian links, guardians no other student is linked to, uploaded files,
+// promotion records and the audit trail. A tombstone and a single audit entry recording the
+// erasure are all that is kept. Erasing an already erased student returns its
+// tombstone.
+func (s *StudentService) EraseStudent(ctx context.Context, id string) (*model.StudentTombstone, error) {
+	tombstone := &model.StudentTombstone{StudentID: id, TenantID: s.tenantID}
+	erased := false
+	var files []model.StudentFile
+	err := s.transaction(ctx, func(tx *gorm.DB) error {
+		var student model.Student
+		err := tx.Unscoped().Select("id").First(&student, "id = ?", id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := tx.First(tombstone, "student_id = ?", id).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrStudentNotFound
+				}
+				return err
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var guardianIDs []string
+		if err := tx.Model(&model.StudentGuardian{}).Where("student_id = ?", id).Pluck("guardian_id", &guardianIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&model.StudentGuardian{}, "student_id = ?", id).Error; err != nil {
+			return err
+		}
+		if len(guardianIDs) > 0 {
+			// Guardians shared with a sibling stay; the rest only existed for
+			// this student.
+			orphans := tx.Model(&model.StudentGuardian{}).Select("guardian_id").Where("guardian_id IN ?", guardianIDs)
+			err := tx.Unscoped().
+				Where("id IN ? AND id NOT IN (?)", guardianIDs, orphans).
+				Delete(&model.Guardian{}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("student_id = ?", id).Find(&files).Error; err != nil {
+			return err
+		}
+		for _, table := range []any{&model.StudentFile{}, &model.PromotionRecord{}, &model.AuditEntry{}} {
+			if err := tx.Where("student_id = ?", id).Delete(table).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Delete(&model.Student{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(tombstone).Error; err != nil {
+			return err
+		}
+		erased = true
+		return audit(tx, s.tenantID, id, model.AuditStudentErased, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range files {
+		s.removeBlobs(&files[i])
+	}
+	s.search.forget(s.tenantID, id)
+	if erased {
+		s.emit(ctx, events.StudentErased, id, nil)
+	}
+	return tombstone, nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/one2n/student-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportStudent(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewStudentService(db)
+
+	student, err := service.CreateStudent(context.Background(), &model.Student{
+		Name: "Tim Minor", Email: "tim@example.com", Age: 12, Grade: "10",
+		Guardians: []model.StudentGuardian{newGuardianLink("Mary Minor", model.RelationshipMother)},
+	})
+	assert.NoError(t, err)
+	_, err = service.UpdateStudent(context.Background(), student.ID, &model.Student{Name: "Tim Minor", Email: "tim@minor.com", Age: 13, Grade: "10"})
+	assert.NoError(t, err)
+	_, err = service.PromoteGrade(context.Background(), "10", false)
+	assert.NoError(t, err)
+
+	export, err := service.ExportStudent(context.Background(), student.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "tim@minor.com", export.Student.Email)
+	assert.Equal(t, "11", export.Student.Grade)
+	assert.False(t, export.Deleted)
+	if assert.Len(t, export.Student.Guardians, 1) {
+		assert.Equal(t, "Mary Minor", export.Student.Guardians[0].Guardian.Name)
+	}
+	if assert.Len(t, export.Promotions, 1) {
+		assert.Equal(t, "10", export.Promotions[0].FromGrade)
+		assert.Equal(t, "11", export.Promotions[0].ToGrade)
+		assert.False(t, export.Promotions[0].PromotedAt.IsZero())
+	}
+	var actions []string
+	for _, entry := range export.Audit {
+		actions = append(actions, entry.Action)
+	}
+	assert.Equal(t, []string{model.AuditStudentCreated, model.AuditStudentUpdated, model.AuditStudentPromoted}, actions)
+	assert.Equal(t, "changed email, age", export.Audit[1].Detail, "audit details never carry the values")
+
+	assert.NoError(t, service.DeleteStudent(context.Background(), student.ID))
+	export, err = service.ExportStudent(context.Background(), student.ID)
+	assert.NoError(t, err, "deleted students are still exported")
+	assert.True(t, export.Deleted)
+
+	_, err = service.ExportStudent(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrStudentNotFound)
+	_, err = service.ForTenant("other").ExportStudent(context.Background(), student.ID)
+	assert.ErrorIs(t, err, ErrStudentNotFound)
+}
+
+func TestEraseStudent(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewStudentService(db)
+
+	shared := newGuardianLink("Mary Minor", model.RelationshipMother)
+	tim, err := service.CreateStudent(context.Background(), &model.Student{
+		Name: "Tim Minor", Email: "tim@example.com", Age: 12, Grade: "10",
+		Guardians: []model.StudentGuardian{shared, newGuardianLink("Uncle Bob", model.RelationshipOther)},
+	})
+	assert.NoError(t, err)
+	var mary, bob string
+	for _, link := range tim.Guardians {
+		if link.Guardian.Name == "Mary Minor" {
+			mary = link.GuardianID
+		} else {
+			bob = link.GuardianID
+		}
+	}
+	sibling, err := service.CreateStudent(context.Background(), &model.Student{
+		Name: "Tina Minor", Email: "tina@example.com", Age: 14, Grade: "10",
+		Guardians: []model.StudentGuardian{{GuardianID: mary, Relationship: model.RelationshipMother}},
+	})
+	assert.NoError(t, err)
+	_, err = service.PromoteGrade(context.Background(), "10", false)
+	assert.NoError(t, err)
+
+	tombstone, err := service.EraseStudent(context.Background(), tim.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, tim.ID, tombstone.StudentID)
+
+	count := func(table, where string, args ...any) int64 {
+		var n int64
+		assert.NoError(t, db.Table(table).Where(where, args...).Count(&n).Error)
+		return n
+	}
+	assert.Zero(t, count("students", "id = ?", tim.ID), "the row is removed, not soft-deleted")
+	assert.Zero(t, count("student_guardians", "student_id = ?", tim.ID))
+	assert.Zero(t, count("guardians", "id = ?", bob), "guardians of no other student go")
+	assert.Equal(t, int64(1), count("guardians", "id = ?", mary), "guardians shared with a sibling stay")
+	assert.Zero(t, count("promotion_records", "student_id = ?", tim.ID))
+	assert.Equal(t, int64(1), count("student_tombstones", "student_id = ?", tim.ID))
+	var entries []model.AuditEntry
+	assert.NoError(t, db.Where("student_id = ?", tim.ID).Find(&entries).Error)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, model.AuditStudentErased, entries[0].Action)
+	}
+
+	again, err := service.EraseStudent(context.Background(), tim.ID)
+	assert.NoError(t, err, "erasure is idempotent")
+	assert.Equal(t, tombstone.ErasedAt.Unix(), again.ErasedAt.Unix())
+	_, err = service.GetStudentByID(context.Background(), tim.ID)
+	assert.ErrorIs(t, err, ErrStudentNotFound)
+
+	links, err := service.ListGuardians(context.Background(), sibling.ID)
+	assert.NoError(t, err)
+	assert.Len(t, links, 1)
+
+	_, err = service.EraseStudent(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrStudentNotFound)
+	_, err = service.ForTenant("other").EraseStudent(context.Background(), sibling.ID)
+	assert.ErrorIs(t, err, ErrStudentNotFound)
+	assert.Equal(t, int64(1), count("students", "id = ?", sibling.ID))
+}
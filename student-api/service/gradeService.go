@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/one2n/student-api/events"
+	"github.com/one2n/student-api/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultGradeLevels is the ordered list of grades used when none is configured.
+var DefaultGradeLevels = []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"}
+
+// Grades returns the configured grade levels, lowest first.
+func (s *StudentService) Grades() []string {
+	return append([]string(nil), s.cfg.GradeLevels...)
+}
+
+// NormalizeGrade maps the spellings clients use ("10", "10th", "Grade 10")
+// onto the configured grade level, or fails if it matches none.
+func (s *StudentService) NormalizeGrade(grade string) (string, error) {
+	i, ok := s.gradeIndex(grade)
+	if !ok {
+		return "", invalid("invalid grade %q: must be one of %s", grade, strings.Join(s.cfg.GradeLevels, ", "))
+	}
+	return s.cfg.GradeLevels[i], nil
+}
+
+func (s *StudentService) gradeIndex(grade string) (int, bool) {
+	want := canonicalGrade(grade)
+	for i, level := range s.cfg.GradeLevels {
+		if canonicalGrade(level) == want {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func canonicalGrade(grade string) string {
+	g := strings.ToLower(strings.TrimSpace(grade))
+	for _, prefix := range []string{"grade", "class", "year"} {
+		g = strings.TrimSpace(strings.TrimPrefix(g, prefix))
+	}
+	for _, suffix := range []string{"st", "nd", "rd", "th"} {
+		trimmed := strings.TrimSuffix(g, suffix)
+		if trimmed != g && trimmed != "" && trimmed[len(trimmed)-1] >= '0' && trimmed[len(trimmed)-1] <= '9' {
+			return trimmed
+		}
+	}
+	return g
+}
+
+// PromoteGrade moves every enrolled student in grade up to the next grade, or
+// graduates them when grade is the last level. The move happens in a single
+// transaction and is recorded as one batch. With dryRun the affected students
+// are returned without changing anything.
+func (s *StudentService) PromoteGrade(ctx context.Context, grade string, dryRun bool) (*model.PromotionBatch, error) {
+	from, err := s.NormalizeGrade(grade)
+	if err != nil {
+		return nil, err
+	}
+	i, _ := s.gradeIndex(from)
+
+	batch := &model.PromotionBatch{
+		TenantID:  s.tenantID,
+		FromGrade: from,
+		Graduated: i == len(s.cfg.GradeLevels)-1,
+		DryRun:    dryRun,
+		Records:   []model.PromotionRecord{},
+	}
+	if !batch.Graduated {
+		batch.ToGrade = s.cfg.GradeLevels[i+1]
+	}
+	if !dryRun {
+		batch.ID = uuid.New().String()
+	}
+
+	err = s.transaction(ctx, func(tx *gorm.DB) error {
+		batch.Records = batch.Records[:0]
+		query := tx.Model(&model.Student{}).Where("grade = ? AND status = ?", from, model.StatusEnrolled)
+		if !dryRun && tx.Dialector.Name() == "postgres" {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		var ids []string
+		if err := query.Order("id").Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			batch.Records = append(batch.Records, model.PromotionRecord{
+				BatchID:   batch.ID,
+				StudentID: id,
+				FromGrade: from,
+				ToGrade:   batch.ToGrade,
+			})
+		}
+		batch.StudentCount = len(ids)
+		if dryRun {
+			return nil
+		}
+		if len(ids) == 0 {
+			return tx.Create(batch).Error
+		}
+
+		updates := map[string]any{"updated_at": time.Now()}
+		if batch.Graduated {
+			updates["status"] = model.StatusGraduated
+		} else {
+			updates["grade"] = batch.ToGrade
+		}
+		if err := tx.Model(&model.Student{}).Where("id IN ?", ids).Updates(updates).Error; err != nil {
+			return err
+		}
+		detail := "grade " + from + " to " + batch.ToGrade
+		if batch.Graduated {
+			detail = "graduated from grade " + from
+		}
+		entries := make([]model.AuditEntry, len(ids))
+		for i, id := range ids {
+			entries[i] = model.AuditEntry{TenantID: s.tenantID, StudentID: id, Action: model.AuditStudentPromoted, Detail: detail}
+		}
+		if err := tx.CreateInBatches(entries, 500).Error; err != nil {
+			return err
+		}
+		return tx.Create(batch).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !dryRun && batch.StudentCount > 0 && s.cfg.Publisher != nil {
+		var promoted []*model.Student
+		ids := make([]string, len(batch.Records))
+		for i, record := range batch.Records {
+			ids[i] = record.StudentID
+		}
+		if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&promoted).Error; err != nil {
+			// The promotion is committed; only the notifications are lost.
+			log.Printf("Failed to load promoted students for events: %v", err)
+		}
+		for _, student := range promoted {
+			s.emit(ctx, events.StudentUpdated, student.ID, student)
+		}
+	}
+	return batch, nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/one2n/student-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeGrade(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewStudentServiceWithConfig(db, Config{GradeLevels: []string{"K", "1", "2", "10"}})
+
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "10", want: "10"},
+		{input: "10th", want: "10"},
+		{input: "Grade 10", want: "10"},
+		{input: " grade 1st ", want: "1"},
+		{input: "k", want: "K"},
+		{input: "A", wantErr: true},
+		{input: "11", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := service.NormalizeGrade(tt.input)
+			if tt.wantErr {
+				var validationErr *ValidationError
+				assert.ErrorAs(t, err, &validationErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCreateStudentNormalizesGrade(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewStudentService(db)
+
+	student, err := service.CreateStudent(context.Background(), &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "Grade 10"})
+	assert.NoError(t, err)
+	assert.Equal(t, "10", student.Grade)
+	assert.Equal(t, model.StatusEnrolled, student.Status)
+
+	_, err = service.UpdateStudent(context.Background(), student.ID, &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10th grade"})
+	assert.EqualError(t, err, `invalid grade "10th grade": must be one of 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12`)
+}
+
+func TestPromoteGrade(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewStudentService(db)
+
+	students := []*model.Student{
+		{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "11"},
+		{Name: "Ben", Email: "ben@example.com", Age: 20, Grade: "11"},
+		{Name: "Cat", Email: "cat@example.com", Age: 20, Grade: "12"},
+	}
+	for _, student := range students {
+		_, err := service.CreateStudent(context.Background(), student)
+		assert.NoError(t, err)
+	}
+
+	preview, err := service.PromoteGrade(context.Background(), "11th", true)
+	assert.NoError(t, err)
+	assert.True(t, preview.DryRun)
+	assert.Empty(t, preview.ID)
+	assert.Equal(t, 2, preview.StudentCount)
+	assert.Equal(t, "12", preview.ToGrade)
+	unchanged, err := service.GetStudentByID(context.Background(), students[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "11", unchanged.Grade)
+
+	graduation, err := service.PromoteGrade(context.Background(), "12", false)
+	assert.NoError(t, err)
+	assert.True(t, graduation.Graduated)
+	assert.Equal(t, 1, graduation.StudentCount)
+	graduate, err := service.GetStudentByID(context.Background(), students[2].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.StatusGraduated, graduate.Status)
+	assert.Equal(t, "12", graduate.Grade)
+
+	batch, err := service.PromoteGrade(context.Background(), "11", false)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, batch.ID)
+	assert.Equal(t, 2, batch.StudentCount)
+	for _, student := range students[:2] {
+		promoted, err := service.GetStudentByID(context.Background(), student.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "12", promoted.Grade)
+		assert.Equal(t, model.StatusEnrolled, promoted.Status)
+	}
+
+	var records []model.PromotionRecord
+	assert.NoError(t, db.Where("batch_id = ?", batch.ID).Find(&records).Error)
+	assert.Len(t, records, 2)
+
+	// Graduates are not swept up by a later promotion of their old grade.
+	again, err := service.PromoteGrade(context.Background(), "12", true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, again.StudentCount)
+
+	_, err = service.PromoteGrade(context.Background(), "13", false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/one2n/student-api/model"
+	"gorm.io/gorm"
+)
+
+func (s *StudentService) ListGuardians(ctx context.Context, studentID string) ([]model.StudentGuardian, error) {
+	if _, err := findStudent(s.db.WithContext(ctx), studentID); err != nil {
+		return nil, err
+	}
+	return listGuardians(s.db.WithContext(ctx), studentID)
+}
+
+// AddGuardian links a guardian to a student. The link either references an
+// existing guardian through GuardianID or carries the details of a new one.
+func (s *StudentService) AddGuardian(ctx context.Context, studentID string, link *model.StudentGuardian) (*model.StudentGuardian, error) {
+	var created *model.StudentGuardian
+	err := s.transaction(ctx, func(tx *gorm.DB) error {
+		if _, err := findStudent(tx, studentID); err != nil {
+			return err
+		}
+		var err error
+		if created, err = addGuardian(tx, s.tenantID, studentID, link); err != nil {
+			return err
+		}
+		return audit(tx, s.tenantID, studentID, model.AuditGuardianAdded, "guardian "+created.GuardianID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateGuardian changes the relationship details of a link and, when the
+// request carries guardian details, the guardian's contact information.
+// Taking the primary flag off a guardian hands it to the longest-standing
+// other guardian; a student's only guardian stays the primary contact.
+func (s *StudentService) UpdateGuardian(ctx context.Context, studentID, guardianID string, update *model.StudentGuardian) (*model.StudentGuardian, error) {
+	var link model.StudentGuardian
+	err := s.transaction(ctx, func(tx *gorm.DB) error {
+		if _, err := findStudent(tx, studentID); err != nil {
+			return err
+		}
+		if err := findLink(tx, studentID, guardianID, &link); err != nil {
+			return err
+		}
+
+		if err := validateRelationship(update.Relationship); err != nil {
+			return err
+		}
+		link.Relationship = update.Relationship
+		if update.IsPrimary && !link.IsPrimary {
+			if err := clearPrimary(tx, studentID); err != nil {
+				return err
+			}
+		}
+		wasPrimary := link.IsPrimary
+		link.IsPrimary = update.IsPrimary
+		if err := tx.Omit("Guardian").Save(&link).Error; err != nil {
+			return err
+		}
+		if wasPrimary && !link.IsPrimary {
+			promoted, err := promoteNextPrimary(tx, studentID, guardianID)
+			if err != nil {
+				return err
+			}
+			if !promoted {
+				return invalid("the only guardian of a student must be the primary contact")
+			}
+		}
+
+		if update.Guardian != nil {
+			link.Guardian.Name = update.Guardian.Name
+			link.Guardian.Email = update.Guardian.Email
+			link.Guardian.Phone = update.Guardian.Phone
+			link.Guardian.Address = update.Guardian.Address
+			if err := tx.Save(link.Guardian).Error; err != nil {
+				return err
+			}
+		}
+		return audit(tx, s.tenantID, studentID, model.AuditGuardianUpdated, "guardian "+guardianID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// RemoveGuardian unlinks a guardian from a student. The last guardian of a
+// student below the configured age cannot be removed.
+func (s *StudentService) RemoveGuardian(ctx context.Context, studentID, guardianID string) error {
+	return s.transaction(ctx, func(tx *gorm.DB) error {
+		student, err := findStudent(tx, studentID)
+		if err != nil {
+			return err
+		}
+		var link model.StudentGuardian
+		if err := findLink(tx, studentID, guardianID, &link); err != nil {
+			return err
+		}
+
+		if s.requiresGuardian(student.Age) {
+			count, err := countGuardians(tx, studentID)
+			if err != nil {
+				return err
+			}
+			if count <= 1 {
+				return invalid("cannot remove the last guardian of a student under %d", s.cfg.GuardianRequiredBelowAge)
+			}
+		}
+
+		if err := tx.Delete(&model.StudentGuardian{}, "student_id = ? AND guardian_id = ?", studentID, guardianID).Error; err != nil {
+			return err
+		}
+		if err := audit(tx, s.tenantID, studentID, model.AuditGuardianRemoved, "guardian "+guardianID); err != nil {
+			return err
+		}
+		if !link.IsPrimary {
+			return nil
+		}
+		_, err = promoteNextPrimary(tx, studentID, guardianID)
+		return err
+	})
+}
+
+// promoteNextPrimary hands the primary contact of a student over to the
+// longest-standing guardian other than guardianID. It reports false when
+// there is no other guardian.
+func promoteNextPrimary(tx *gorm.DB, studentID, guardianID string) (bool, error) {
+	var next model.StudentGuardian
+	err := tx.Where("student_id = ? AND guardian_id <> ?", studentID, guardianID).Order("created_at").First(&next).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, tx.Model(&next).Update("is_primary", true).Error
+}
+
+func findStudent(db *gorm.DB, id string) (*model.Student, error) {
+	var student model.Student
+	if err := db.First(&student, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStudentNotFound
+		}
+		return nil, err
+	}
+	return &student, nil
+}
+
+func findLink(db *gorm.DB, studentID, guardianID string, link *model.StudentGuardian) error {
+	err := db.Preload("Guardian").
+		Where("student_id = ? AND guardian_id = ?", studentID, guardianID).
+		First(link).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrGuardianNotFound
+	}
+	return err
+}
+
+func listGuardians(db *gorm.DB, studentID string) ([]model.StudentGuardian, error) {
+	var links []model.StudentGuardian
+	err := db.Preload("Guardian").
+		Where("student_id = ?", studentID).
+		Order("is_primary DESC, created_at").
+		Find(&links).Error
+	if err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func countGuardians(db *gorm.DB, studentID string) (int64, error) {
+	var count int64
+	err := db.Model(&model.StudentGuardian{}).Where("student_id = ?", studentID).Count(&count).Error
+	return count, err
+}
+
+func clearPrimary(db *gorm.DB, studentID string) error {
+	return db.Model(&model.StudentGuardian{}).
+		Where("student_id = ? AND is_primary = ?", studentID, true).
+		Update("is_primary", false).Error
+}
+
+func validateRelationship(relationship string) error {
+	switch relationship {
+	case model.RelationshipMother, model.RelationshipFather, model.RelationshipParent,
+		model.RelationshipLegalGuardian, model.RelationshipGrandparent,
+		model.RelationshipSibling, model.RelationshipOther:
+		return nil
+	}
+	return invalid("invalid relationship: %q", relationship)
+}
+
+func addGuardian(tx *gorm.DB, tenantID, studentID string, link *model.StudentGuardian) (*model.StudentGuardian, error) {
+	if err := validateRelationship(link.Relationship); err != nil {
+		return nil, err
+	}
+
+	guardian := link.Guardian
+	if link.GuardianID != "" {
+		guardian = &model.Guardian{}
+		if err := tx.First(guardian, "id = ?", link.GuardianID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrGuardianNotFound
+			}
+			return nil, err
+		}
+
+		var existing int64
+		if err := tx.Model(&model.StudentGuardian{}).
+			Where("student_id = ? AND guardian_id = ?", studentID, guardian.ID).
+			Count(&existing).Error; err != nil {
+			return nil, err
+		}
+		if existing > 0 {
+			return nil, ErrGuardianLinked
+		}
+	} else {
+		if guardian == nil {
+			return nil, invalid("guardian_id or guardian details are required")
+		}
+		guardian.ID = uuid.New().String()
+		guardian.TenantID = tenantID
+		if err := tx.Create(guardian).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	count, err := countGuardians(tx, studentID)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		// The first guardian on file is always the primary contact.
+		link.IsPrimary = true
+	} else if link.IsPrimary {
+		if err := clearPrimary(tx, studentID); err != nil {
+			return nil, err
+		}
+	}
+
+	created := model.StudentGuardian{
+		StudentID:    studentID,
+		GuardianID:   guardian.ID,
+		Relationship: link.Relationship,
+		IsPrimary:    link.IsPrimary,
+	}
+	if err := tx.Omit("Guardian").Create(&created).Error; err != nil {
+		return nil, err
+	}
+	created.Guardian = guardian
+	return &created, nil
+}
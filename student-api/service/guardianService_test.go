@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/one2n/student-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func newGuardianLink(name, relationship string) model.StudentGuardian {
+	return model.StudentGuardian{
+		Relationship: relationship,
+		Guardian: &model.Guardian{
+			Name:  name,
+			Phone: "+1-555-0100",
+		},
+	}
+}
+
+func TestCreateMinorRequiresGuardian(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewStudentService(db)
+
+	_, err := service.CreateStudent(context.Background(), &model.Student{
+		Name:  "Tim Minor",
+		Email: "tim@example.com",
+		Age:   12,
+		Grade: "10",
+	})
+	assert.EqualError(t, err, "at least one guardian is required for students under 18")
+
+	student, err := service.CreateStudent(context.Background(), &model.Student{
+		Name:      "Tim Minor",
+		Email:     "tim@example.com",
+		Age:       12,
+		Grade:     "10",
+		Guardians: []model.StudentGuardian{newGuardianLink("Mary Minor", model.RelationshipMother)},
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, student.Guardians, 1) {
+		assert.True(t, student.Guardians[0].IsPrimary)
+		assert.Equal(t, "Mary Minor", student.Guardians[0].Guardian.Name)
+	}
+}
+
+func TestGuardianRequirementIsConfigurable(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewStudentServiceWithConfig(db, Config{GuardianRequiredBelowAge: 10})
+
+	_, err := service.CreateStudent(context.Background(), &model.Student{
+		Name:  "Tim Minor",
+		Email: "tim@example.com",
+		Age:   12,
+		Grade: "10",
+	})
+	assert.NoError(t, err)
+}
+
+func TestAddGuardian(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewStudentService(db)
+
+	first, err := service.CreateStudent(context.Background(), &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	second, err := service.CreateStudent(context.Background(), &model.Student{Name: "Ben", Email: "ben@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+
+	mother := newGuardianLink("Mary", model.RelationshipMother)
+	link, err := service.AddGuardian(context.Background(), first.ID, &mother)
+	assert.NoError(t, err)
+	assert.True(t, link.IsPrimary, "first guardian becomes the primary contact")
+
+	father := newGuardianLink("Mark", model.RelationshipFather)
+	father.IsPrimary = true
+	_, err = service.AddGuardian(context.Background(), first.ID, &father)
+	assert.NoError(t, err)
+
+	links, err := service.ListGuardians(context.Background(), first.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, links, 2) {
+		assert.Equal(t, "Mark", links[0].Guardian.Name)
+		assert.True(t, links[0].IsPrimary)
+		assert.False(t, links[1].IsPrimary)
+	}
+
+	tests := []struct {
+		name      string
+		studentID string
+		link      model.StudentGuardian
+		wantErr   error
+	}{
+		{
+			name:      "link existing guardian to sibling",
+			studentID: second.ID,
+			link:      model.StudentGuardian{GuardianID: link.GuardianID, Relationship: model.RelationshipMother},
+		},
+		{
+			name:      "duplicate link",
+			studentID: first.ID,
+			link:      model.StudentGuardian{GuardianID: link.GuardianID, Relationship: model.RelationshipMother},
+			wantErr:   ErrGuardianLinked,
+		},
+		{
+			name:      "unknown guardian",
+			studentID: first.ID,
+			link:      model.StudentGuardian{GuardianID: "missing", Relationship: model.RelationshipOther},
+			wantErr:   ErrGuardianNotFound,
+		},
+		{
+			name:      "unknown student",
+			studentID: "missing",
+			link:      newGuardianLink("Zed", model.RelationshipOther),
+			wantErr:   ErrStudentNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.AddGuardian(context.Background(), tt.studentID, &tt.link)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRemoveGuardian(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewStudentService(db)
+
+	student, err := service.CreateStudent(context.Background(), &model.Student{
+		Name:      "Tim Minor",
+		Email:     "tim@example.com",
+		Age:       12,
+		Grade:     "10",
+		Guardians: []model.StudentGuardian{newGuardianLink("Mary", model.RelationshipMother)},
+	})
+	assert.NoError(t, err)
+	mother := student.Guardians[0]
+
+	err = service.RemoveGuardian(context.Background(), student.ID, mother.GuardianID)
+	assert.EqualError(t, err, "cannot remove the last guardian of a student under 18")
+
+	father := newGuardianLink("Mark", model.RelationshipFather)
+	_, err = service.AddGuardian(context.Background(), student.ID, &father)
+	assert.NoError(t, err)
+
+	assert.NoError(t, service.RemoveGuardian(context.Background(), student.ID, mother.GuardianID))
+	links, err := service.ListGuardians(context.Background(), student.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, links, 1) {
+		assert.Equal(t, "Mark", links[0].Guardian.Name)
+		assert.True(t, links[0].IsPrimary, "primary contact moves to the remaining guardian")
+	}
+
+	assert.ErrorIs(t, service.RemoveGuardian(context.Background(), student.ID, mother.GuardianID), ErrGuardianNotFound)
+}
+
+func TestUpdateGuardian(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewStudentService(db)
+
+	student, err := service.CreateStudent(context.Background(), &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	link := newGuardianLink("Mary", model.RelationshipMother)
+	created, err := service.AddGuardian(context.Background(), student.ID, &link)
+	assert.NoError(t, err)
+
+	updated, err := service.UpdateGuardian(context.Background(), student.ID, created.GuardianID, &model.StudentGuardian{
+		Relationship: model.RelationshipLegalGuardian,
+		IsPrimary:    true,
+		Guardian: &model.Guardian{
+			Name:    "Mary Smith",
+			Phone:   "+1-555-0199",
+			Address: "1 Main St",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, model.RelationshipLegalGuardian, updated.Relationship)
+	assert.Equal(t, "+1-555-0199", updated.Guardian.Phone)
+	assert.Equal(t, "1 Main St", updated.Guardian.Address)
+
+	_, err = service.UpdateGuardian(context.Background(), student.ID, created.GuardianID, &model.StudentGuardian{Relationship: "neighbour"})
+	assert.EqualError(t, err, `invalid relationship: "neighbour"`)
+
+	_, err = service.UpdateGuardian(context.Background(), student.ID, created.GuardianID, &model.StudentGuardian{Relationship: model.RelationshipMother})
+	assert.EqualError(t, err, "the only guardian of a student must be the primary contact")
+
+	father := newGuardianLink("Mark", model.RelationshipFather)
+	second, err := service.AddGuardian(context.Background(), student.ID, &father)
+	assert.NoError(t, err)
+	updated, err = service.UpdateGuardian(context.Background(), student.ID, created.GuardianID, &model.StudentGuardian{Relationship: model.RelationshipMother})
+	assert.NoError(t, err)
+	assert.False(t, updated.IsPrimary)
+	links, err := service.ListGuardians(context.Background(), student.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, links, 2) {
+		assert.Equal(t, second.GuardianID, links[0].GuardianID)
+		assert.True(t, links[0].IsPrimary, "the primary contact moves to the other guardian")
+	}
+}
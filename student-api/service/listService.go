@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/pii"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// ListStudents returns one page of students matching filter, ordered by
+// creation time, together with the total number of matches.
+func (s *StudentService) ListStudents(ctx context.Context, filter model.StudentFilter, limit, offset int) ([]*model.Student, int64, error) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	if offset < 0 {
+		return nil, 0, invalid("offset must not be negative")
+	}
+
+	if filter.Grade != "" {
+		grade, err := s.NormalizeGrade(filter.Grade)
+		if err != nil {
+			return nil, 0, err
+		}
+		filter.Grade = grade
+	}
+
+	var students []*model.Student
+	var total int64
+	err := s.read(ctx, func(db *gorm.DB) error {
+		query := db.Model(&model.Student{})
+		if filter.Grade != "" {
+			query = query.Where("grade = ?", filter.Grade)
+		}
+		if filter.Status != "" {
+			query = query.Where("status = ?", filter.Status)
+		}
+		if filter.MinAge > 0 {
+			query = query.Where("age >= ?", filter.MinAge)
+		}
+		if filter.MaxAge > 0 {
+			query = query.Where("age <= ?", filter.MaxAge)
+		}
+		if filter.NameContains != "" {
+			if pii.Installed() != nil {
+				// Encrypted names cannot be matched in SQL.
+				var err error
+				students, total, err = listByName(query, filter.NameContains, limit, offset)
+				return err
+			}
+			query = query.Where("LOWER(name) LIKE ?", "%"+strings.ToLower(filter.NameContains)+"%")
+		}
+
+		if err := query.Count(&total).Error; err != nil {
+			return err
+		}
+		return query.Order("created_at, id").Limit(limit).Offset(offset).Find(&students).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return students, total, nil
+}
+
+// listByName filters the students matched by query on their decrypted name
+// and pages through the result in memory.
+func listByName(query *gorm.DB, nameContains string, limit, offset int) ([]*model.Student, int64, error) {
+	var candidates []*model.Student
+	if err := query.Order("created_at, id").Find(&candidates).Error; err != nil {
+		return nil, 0, err
+	}
+	needle := strings.ToLower(nameContains)
+	matches := []*model.Student{}
+	for _, student := range candidates {
+		if strings.Contains(strings.ToLower(student.Name), needle) {
+			matches = append(matches, student)
+		}
+	}
+	total := int64(len(matches))
+	if offset >= len(matches) {
+		return []*model.Student{}, total, nil
+	}
+	return matches[offset:min(offset+limit, len(matches))], total, nil
+}
+
+// GetStudentsByIDs loads several students in one query. Unknown IDs are
+// skipped; the result is in no particular order.
+func (s *StudentService) GetStudentsByIDs(ctx context.Context, ids []string) ([]*model.Student, error) {
+	var students []*model.Student
+	if len(ids) == 0 {
+		return students, nil
+	}
+	err := s.read(ctx, func(db *gorm.DB) error {
+		return db.Where("id IN ?", ids).Find(&students).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return students, nil
+}
+
+// ListGuardiansForStudents loads the guardians of several students in one
+// query, keyed by student ID.
+func (s *StudentService) ListGuardiansForStudents(ctx context.Context, studentIDs []string) (map[string][]model.StudentGuardian, error) {
+	byStudent := make(map[string][]model.StudentGuardian, len(studentIDs))
+	if len(studentIDs) == 0 {
+		return byStudent, nil
+	}
+	var links []model.StudentGuardian
+	err := s.read(ctx, func(db *gorm.DB) error {
+		return db.Preload("Guardian").
+			Where("student_id IN ?", studentIDs).
+			Order("is_primary DESC, created_at").
+			Find(&links).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		byStudent[link.StudentID] = append(byStudent[link.StudentID], link)
+	}
+	return byStudent, nil
+}
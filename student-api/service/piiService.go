@@ -0,0 +1,127 @@
+package service
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/pii"
+	"gorm.io/gorm"
+)
+
+// storedStudent is a student row as stored, bypassing the pii serializer.
+type storedStudent struct {
+	ID         string
+	Name       string
+	Email      string
+	EmailIndex string
+}
+
+// emailIndexName is the unique index on the blind email index.
+const emailIndexName = "idx_students_tenant_email_index"
+
+// migrateEmailIndex moves email uniqueness onto the blind index. The first
+// time, every row is brought in line with the installed keyring and the
+// email rules in cfg, and the unique index is built, before older indexes on
+// the email column itself are dropped, since an encrypted email differs on
+// every write. Should any step fail, the older indexes stay and emails remain
+// unique. Once the index exists rows are left alone; ProtectStudents is run
+// on demand instead.
+func migrateEmailIndex(db *gorm.DB, cfg Config) error {
+	if !db.Migrator().HasIndex(&model.Student{}, emailIndexName) {
+		if _, err := ProtectStudents(db, cfg); err != nil {
+			return err
+		}
+		if err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS " + emailIndexName + " ON students (tenant_id, email_index)").Error; err != nil {
+			return err
+		}
+	}
+	for _, legacy := range []string{"idx_students_email", "idx_students_tenant_email"} {
+		if db.Migrator().HasIndex(&model.Student{}, legacy) {
+			if err := db.Migrator().DropIndex(&model.Student{}, legacy); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ProtectStudents encrypts plaintext names and emails, rewraps values whose
+// data key is wrapped by a retired key, normalizes emails and brings blind
+// indexes in line with cfg. It backs the rewrap subcommand, run after a key
+// is added to the key file or the email rules change. Every row is read,
+// since encrypted emails can only be checked once decrypted. A row whose
+// normalized email collides with another student's is logged and left as it
+// is. Returns the number of rows changed.
+func ProtectStudents(db *gorm.DB, cfg Config) (int, error) {
+	keyring := pii.Installed()
+	query := db.Table("students").Select("id, name, email, email_index").Order("id")
+
+	changed := 0
+	var rows []storedStudent
+	err := query.FindInBatches(&rows, 500, func(tx *gorm.DB, _ int) error {
+		for _, row := range rows {
+			updates, err := protectRow(keyring, cfg, row)
+			if err != nil {
+				return fmt.Errorf("student %s: %w", row.ID, err)
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			if err := db.Table("students").Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+				if isUniqueViolation(err) {
+					log.Printf("Student %s has the same email as another student; leaving it unnormalized", row.ID)
+					continue
+				}
+				return err
+			}
+			changed++
+		}
+		return nil
+	}).Error
+	return changed, err
+}
+
+func protectRow(keyring *pii.Keyring, cfg Config, row storedStudent) (map[string]any, error) {
+	updates := map[string]any{}
+	email := row.Email
+	if keyring != nil {
+		var err error
+		if email, err = keyring.Decrypt("email", row.Email); err != nil {
+			return nil, err
+		}
+		for column, value := range map[string]string{"name": row.Name, "email": row.Email} {
+			if !keyring.NeedsRewrap(value) {
+				continue
+			}
+			var protected string
+			if pii.IsEncrypted(value) {
+				protected, err = keyring.Rewrap(value)
+			} else {
+				protected, err = keyring.Encrypt(column, value)
+			}
+			if err != nil {
+				return nil, err
+			}
+			updates[column] = protected
+		}
+	} else if pii.IsEncrypted(email) {
+		return nil, pii.ErrNoKeyring
+	}
+
+	if normalized := normalizeEmail(email); normalized != email {
+		email = normalized
+		updates["email"] = normalized
+		if keyring != nil {
+			protected, err := keyring.Encrypt("email", normalized)
+			if err != nil {
+				return nil, err
+			}
+			updates["email"] = protected
+		}
+	}
+	if index := pii.BlindIndex(emailKey(cfg, email)); index != row.EmailIndex {
+		updates["email_index"] = index
+	}
+	return updates, nil
+}
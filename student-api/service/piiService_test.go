@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/pii"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// installTestKeyring installs a keyring for the duration of the test. Keys
+// are derived from their IDs, so keyrings built in one test share keys.
+func installTestKeyring(t *testing.T, active string, ids ...string) {
+	file := pii.KeyFile{Active: active, Keys: map[string]string{}, IndexKey: testPIIKey("index")}
+	for _, id := range ids {
+		file.Keys[id] = testPIIKey(id)
+	}
+	keyring, err := pii.NewKeyring(file)
+	assert.NoError(t, err)
+	pii.Install(keyring)
+	t.Cleanup(func() { pii.Install(nil) })
+}
+
+func testPIIKey(id string) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(id, 32)[:32]))
+}
+
+func storedRow(t *testing.T, db *gorm.DB, id string) storedStudent {
+	var row storedStudent
+	assert.NoError(t, db.Table("students").Select("id, name, email, email_index").Where("id = ?", id).Take(&row).Error)
+	return row
+}
+
+func TestStudentPIIEncryption(t *testing.T) {
+	installTestKeyring(t, "k1", "k1")
+	db := setupTestDB(t)
+	service := NewStudentService(db)
+
+	created, err := service.CreateStudent(context.Background(), &model.Student{Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+
+	row := storedRow(t, db, created.ID)
+	assert.True(t, strings.HasPrefix(row.Name, "enc:v1:k1:"))
+	assert.True(t, strings.HasPrefix(row.Email, "enc:v1:k1:"))
+	assert.NotContains(t, row.EmailIndex, "john")
+
+	found, err := service.GetStudentByID(context.Background(), created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "John Doe", found.Name)
+	assert.Equal(t, "john@doe.com", found.Email)
+
+	_, err = service.CreateStudent(context.Background(), &model.Student{Name: "Johnny", Email: "john@doe.com", Age: 20, Grade: "10"})
+	assert.ErrorIs(t, err, ErrEmailExists, "uniqueness is checked through the blind index")
+
+	page, total, err := service.ListStudents(context.Background(), model.StudentFilter{NameContains: "doe"}, 10, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Len(t, page, 1)
+
+	results, err := service.SearchStudents(context.Background(), "john", 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+}
+
+func TestProtectStudents(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewStudentService(db)
+	created, err := service.CreateStudent(context.Background(), &model.Student{Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	assert.Equal(t, "john@doe.com", storedRow(t, db, created.ID).Email, "without a keyring values are stored as is")
+
+	// Installing a keyring leaves existing rows alone until they are
+	// protected; plaintext rows are still read as they are.
+	installTestKeyring(t, "k1", "k1")
+	found, err := NewStudentService(db).GetStudentByID(context.Background(), created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "John Doe", found.Name)
+	assert.Equal(t, "john@doe.com", storedRow(t, db, created.ID).Email, "startup does not rewrite rows")
+	changed, err := ProtectStudents(db, DefaultConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, changed)
+	encrypted := storedRow(t, db, created.ID)
+	assert.True(t, strings.HasPrefix(encrypted.Email, "enc:v1:k1:"))
+	assert.Equal(t, pii.BlindIndex("john@doe.com"), encrypted.EmailIndex)
+
+	// Rotating to k2 rewraps every value.
+	installTestKeyring(t, "k2", "k1", "k2")
+	NewStudentService(db)
+	assert.Equal(t, encrypted, storedRow(t, db, created.ID), "startup does not rewrap")
+	changed, err = ProtectStudents(db, DefaultConfig())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, changed)
+	rotated := storedRow(t, db, created.ID)
+	assert.True(t, strings.HasPrefix(rotated.Name, "enc:v1:k2:"))
+	assert.True(t, strings.HasPrefix(rotated.Email, "enc:v1:k2:"))
+	assert.Equal(t, encrypted.EmailIndex, rotated.EmailIndex)
+
+	changed, err = ProtectStudents(db, DefaultConfig())
+	assert.NoError(t, err)
+	assert.Zero(t, changed)
+
+	// Once rotated, k1 can be retired.
+	installTestKeyring(t, "k2", "k2")
+	found, err = NewStudentService(db).GetStudentByID(context.Background(), created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "John Doe", found.Name)
+}
+
+func TestMigrateEmailIndexKeepsLegacyIndexOnFailure(t *testing.T) {
+	// An encrypted row cannot be checked without a keyring.
+	installTestKeyring(t, "k1", "k1")
+	db := setupTestDB(t)
+	created, err := NewStudentService(db).CreateStudent(context.Background(), &model.Student{Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Exec("DROP INDEX idx_students_tenant_email_index").Error)
+	assert.NoError(t, db.Exec("CREATE UNIQUE INDEX idx_students_tenant_email ON students (tenant_id, email)").Error)
+	pii.Install(nil)
+
+	assert.ErrorIs(t, migrateEmailIndex(db, DefaultConfig()), pii.ErrNoKeyring)
+	assert.True(t, db.Migrator().HasIndex(&model.Student{}, "idx_students_tenant_email"), "emails stay unique")
+	assert.False(t, db.Migrator().HasIndex(&model.Student{}, "idx_students_tenant_email_index"))
+
+	installTestKeyring(t, "k1", "k1")
+	assert.NoError(t, migrateEmailIndex(db, DefaultConfig()))
+	assert.False(t, db.Migrator().HasIndex(&model.Student{}, "idx_students_tenant_email"))
+	assert.True(t, db.Migrator().HasIndex(&model.Student{}, "idx_students_tenant_email_index"))
+	assert.Equal(t, pii.BlindIndex("john@doe.com"), storedRow(t, db, created.ID).EmailIndex)
+}
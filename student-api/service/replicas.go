@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Replicas routes read-only queries to read replicas of the primary
+// database. Replicas are used in turn; one that fails a health check is
+// skipped until it passes again, and when none is healthy reads go to the
+// primary.
+//
+// Replication is asynchronous, so a read right after a write could miss it.
+// Reads of a client that wrote within the sticky window, which should cover
+// the usual replication lag, stay on the primary. The client's last write is
+// tracked by the WriteClock in the context, which the client carries from
+// one request to the next, so it holds whichever server answers.
+type Replicas struct {
+	replicas []*replica
+	next     atomic.Uint64
+	sticky   time.Duration
+}
+
+type replica struct {
+	name    string
+	db      *gorm.DB
+	healthy atomic.Bool
+	reads   atomic.Int64
+	errors  atomic.Int64
+}
+
+// ReplicaStats reports the state of one replica.
+type ReplicaStats struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Reads   int64  `json:"reads"`
+	Errors  int64  `json:"errors"`
+}
+
+// NewReplicas routes reads to dbs, keyed by a name used in logs and stats.
+// Every replica starts out healthy.
+func NewReplicas(dbs map[string]*gorm.DB, sticky time.Duration) *Replicas {
+	r := &Replicas{sticky: sticky}
+	names := make([]string, 0, len(dbs))
+	for name := range dbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rep := &replica{name: name, db: dbs[name]}
+		rep.healthy.Store(true)
+		r.replicas = append(r.replicas, rep)
+	}
+	return r
+}
+
+// CheckHealth pings every replica and updates its health.
+func (r *Replicas) CheckHealth() {
+	for _, rep := range r.replicas {
+		rep.check()
+	}
+}
+
+// Monitor runs CheckHealth every interval until stop is called.
+func (r *Replicas) Monitor(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.CheckHealth()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (r *Replicas) Stats() []ReplicaStats {
+	stats := make([]ReplicaStats, len(r.replicas))
+	for i, rep := range r.replicas {
+		stats[i] = ReplicaStats{
+			Name:    rep.name,
+			Healthy: rep.healthy.Load(),
+			Reads:   rep.reads.Load(),
+			Errors:  rep.errors.Load(),
+		}
+	}
+	return stats
+}
+
+// pick returns the replica a read in ctx should use, or nil for the primary.
+func (r *Replicas) pick(ctx context.Context) *replica {
+	if clock := writeClockFrom(ctx); clock != nil && time.Since(clock.Last()) < r.sticky {
+		return nil
+	}
+
+	start := r.next.Add(1)
+	for i := range r.replicas {
+		rep := r.replicas[(start+uint64(i))%uint64(len(r.replicas))]
+		if rep.healthy.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+// WriteClock holds the time of a client's last write. The client is given
+// it after every write, through Header, and sends it back on later requests,
+// where ParseWriteClock reads it.
+type WriteClock struct {
+	mu    sync.Mutex
+	last  time.Time
+	wrote bool
+}
+
+type writeClockKey struct{}
+
+// ParseWriteClock returns a clock set to value, the Unix time in
+// milliseconds of the client's last write as sent by the client. Malformed
+// values are ignored, and times in the future taken as now.
+func ParseWriteClock(value string) *WriteClock {
+	clock := &WriteClock{}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil && ms > 0 {
+		clock.last = time.UnixMilli(ms)
+		if now := time.Now(); clock.last.After(now) {
+			clock.last = now
+		}
+	}
+	return clock
+}
+
+// WithWriteClock returns a context whose reads and writes use clock.
+func WithWriteClock(ctx context.Context, clock *WriteClock) context.Context {
+	return context.WithValue(ctx, writeClockKey{}, clock)
+}
+
+func writeClockFrom(ctx context.Context) *WriteClock {
+	clock, _ := ctx.Value(writeClockKey{}).(*WriteClock)
+	return clock
+}
+
+// Last returns the time of the client's last write.
+func (c *WriteClock) Last() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// Wrote reports whether a write went through the clock since it was parsed.
+func (c *WriteClock) Wrote() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.wrote
+}
+
+// Header formats the clock for the client to send back.
+func (c *WriteClock) Header() string {
+	return strconv.FormatInt(c.Last().UnixMilli(), 10)
+}
+
+func (c *WriteClock) record(at time.Time) {
+	c.mu.Lock()
+	c.last = at
+	c.wrote = true
+	c.mu.Unlock()
+}
+
+// wrote records a write in ctx, which starts the sticky window of its client.
+func (r *Replicas) wrote(ctx context.Context) {
+	if clock := writeClockFrom(ctx); clock != nil {
+		clock.record(time.Now())
+	}
+}
+
+func (rep *replica) check() bool {
+	sqlDB, err := rep.db.DB()
+	if err == nil {
+		err = sqlDB.Ping()
+	}
+	healthy := err == nil
+	if rep.healthy.Swap(healthy) != healthy {
+		if healthy {
+			log.Printf("Read replica %s is healthy again", rep.name)
+		} else {
+			log.Printf("Read replica %s is unhealthy, reading from the primary: %v", rep.name, err)
+		}
+	}
+	return healthy
+}
+
+// read runs the read-only query fn on a replica when one is available, and on
+// the primary otherwise. A query that fails on a replica is run again on the
+// primary, and the replica is taken out of rotation if it no longer answers
+// a ping.
+func (s *StudentService) read(ctx context.Context, fn func(db *gorm.DB) error) error {
+	if s.nested || s.cfg.Replicas == nil {
+		return fn(s.db.WithContext(ctx))
+	}
+	rep := s.cfg.Replicas.pick(ctx)
+	if rep == nil {
+		return fn(s.db.WithContext(ctx))
+	}
+
+	rep.reads.Add(1)
+	err := fn(rep.db.WithContext(ctx).Scopes(tenantScope(s.tenantID)).Session(&gorm.Session{}))
+	if err == nil || errors.Is(err, gorm.ErrRecordNotFound) || ctx.Err() != nil {
+		return err
+	}
+	rep.errors.Add(1)
+	log.Printf("Read from replica %s failed, retrying on the primary: %v", rep.name, err)
+	rep.check()
+	return fn(s.db.WithContext(ctx))
+}
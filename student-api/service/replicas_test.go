@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/one2n/student-api/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupReplicaDBs opens a primary and a replica as two SQLite files. Nothing
+// replicates between them, which makes it visible where a read went.
+func setupReplicaDBs(t *testing.T) (primary, replica *gorm.DB) {
+	dir := t.TempDir()
+	primary, err := gorm.Open(sqlite.Open(filepath.Join(dir, "primary.db")), &gorm.Config{})
+	assert.NoError(t, err)
+	replica, err = gorm.Open(sqlite.Open(filepath.Join(dir, "replica.db")), &gorm.Config{})
+	assert.NoError(t, err)
+	NewStudentService(replica)
+	return primary, replica
+}
+
+func TestReadReplicas(t *testing.T) {
+	primary, replicaDB := setupReplicaDBs(t)
+	replicas := NewReplicas(map[string]*gorm.DB{"replica-1": replicaDB}, time.Minute)
+	cfg := DefaultConfig()
+	cfg.Replicas = replicas
+	service := NewStudentServiceWithConfig(primary, cfg)
+
+	clock := ParseWriteClock("")
+	writer := WithWriteClock(context.Background(), clock)
+	ann, err := service.CreateStudent(writer, &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	assert.True(t, clock.Wrote())
+	found, err := service.GetStudentByID(writer, ann.ID)
+	assert.NoError(t, err, "reads after a write stay on the primary")
+	assert.Equal(t, "Ann", found.Name)
+	assert.Zero(t, replicas.Stats()[0].Reads)
+
+	// The client carries its last write to its next request.
+	next := WithWriteClock(context.Background(), ParseWriteClock(clock.Header()))
+	_, err = service.GetStudentByID(next, ann.ID)
+	assert.NoError(t, err)
+	assert.Zero(t, replicas.Stats()[0].Reads)
+
+	others, err := service.GetAllStudents(WithWriteClock(context.Background(), ParseWriteClock("")))
+	assert.NoError(t, err)
+	assert.Empty(t, others)
+	assert.Equal(t, int64(1), replicas.Stats()[0].Reads, "other clients are not held to the primary")
+
+	// Once the sticky window has passed, reads go to the replica, which has
+	// not seen Ann but has seen Ben.
+	stale := WithWriteClock(context.Background(), ParseWriteClock(strconv.FormatInt(time.Now().Add(-time.Hour).UnixMilli(), 10)))
+	assert.NoError(t, replicaDB.Create(&model.Student{
+		ID: "ben", TenantID: model.DefaultTenantID, Name: "Ben", Email: "ben@example.com", EmailIndex: "ben@example.com",
+		Age: 20, Grade: "10", Status: model.StatusEnrolled,
+	}).Error)
+	students, total, err := service.ListStudents(stale, model.StudentFilter{Grade: "10"}, 10, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "Ben", students[0].Name)
+	results, err := service.SearchStudents(stale, "ben", 10)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	_, err = service.GetStudentByID(stale, ann.ID)
+	assert.ErrorIs(t, err, ErrStudentNotFound)
+
+	// A replica that stops answering is skipped.
+	sqlDB, err := replicaDB.DB()
+	assert.NoError(t, err)
+	assert.NoError(t, sqlDB.Close())
+	all, err := service.GetAllStudents(context.Background())
+	assert.NoError(t, err, "a failed replica read is retried on the primary")
+	if assert.Len(t, all, 1) {
+		assert.Equal(t, "Ann", all[0].Name)
+	}
+	stats := replicas.Stats()[0]
+	assert.False(t, stats.Healthy)
+	assert.Equal(t, int64(1), stats.Errors)
+
+	reads := stats.Reads
+	_, err = service.GetStudentByID(context.Background(), ann.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, reads, replicas.Stats()[0].Reads, "unhealthy replicas get no reads")
+
+	replicas.CheckHealth()
+	assert.False(t, replicas.Stats()[0].Healthy)
+
+	future := ParseWriteClock(strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10))
+	assert.False(t, future.Last().After(time.Now()), "clients cannot pin themselves to the primary")
+	assert.True(t, ParseWriteClock("soon").Last().IsZero())
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/one2n/student-api/model"
+	"gorm.io/gorm"
+)
+
+// GradeReport counts the enrolled students in each grade, in grade order.
+func (s *StudentService) GradeReport(ctx context.Context, r model.ReportRange) ([]model.GradeCount, error) {
+	if err := validateRange(r); err != nil {
+		return nil, err
+	}
+	counts := []model.GradeCount{}
+	err := s.read(ctx, func(db *gorm.DB) error {
+		return withinRange(db.Model(&model.Student{}), "created_at", r).
+			Select("grade, COUNT(*) AS students").
+			Where("status = ?", model.StatusEnrolled).
+			Group("grade").
+			Scan(&counts).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		a, aok := s.gradeIndex(counts[i].Grade)
+		b, bok := s.gradeIndex(counts[j].Grade)
+		if aok != bok {
+			// Grades that are no longer configured go last.
+			return aok
+		}
+		if !aok {
+			return counts[i].Grade < counts[j].Grade
+		}
+		return a < b
+	})
+	return counts, nil
+}
+
+// AgeReport counts the enrolled students of each age, youngest first.
+func (s *StudentService) AgeReport(ctx context.Context, r model.ReportRange) ([]model.AgeCount, error) {
+	if err := validateRange(r); err != nil {
+		return nil, err
+	}
+	counts := []model.AgeCount{}
+	err := s.read(ctx, func(db *gorm.DB) error {
+		return withinRange(db.Model(&model.Student{}), "created_at", r).
+			Select("age, COUNT(*) AS students").
+			Where("status = ?", model.StatusEnrolled).
+			Group("age").
+			Order("age").
+			Scan(&counts).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// EnrollmentReport counts the students created in each week or month of r,
+// including those deleted since. Periods without enrollments are left out.
+func (s *StudentService) EnrollmentReport(ctx context.Context, r model.ReportRange, period string) ([]model.EnrollmentPeriod, error) {
+	if err := validatePeriod(period); err != nil {
+		return nil, err
+	}
+	if err := validateRange(r); err != nil {
+		return nil, err
+	}
+	periods := []model.EnrollmentPeriod{}
+	err := s.read(ctx, func(db *gorm.DB) error {
+		periods = periods[:0]
+		created, err := countByPeriod(db, "created_at", period, r)
+		if err != nil {
+			return err
+		}
+		for _, c := range created {
+			periods = append(periods, model.EnrollmentPeriod{Period: c.Period, Enrolled: c.Count})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return periods, nil
+}
+
+// ChurnReport sets the students deleted in each week or month of r against
+// the students created in it. Erased students are gone without a trace and
+// are not counted.
+func (s *StudentService) ChurnReport(ctx context.Context, r model.ReportRange, period string) ([]model.ChurnPeriod, error) {
+	if err := validatePeriod(period); err != nil {
+		return nil, err
+	}
+	if err := validateRange(r); err != nil {
+		return nil, err
+	}
+	byPeriod := make(map[string]*model.ChurnPeriod)
+	entry := func(p string) *model.ChurnPeriod {
+		if byPeriod[p] == nil {
+			byPeriod[p] = &model.ChurnPeriod{Period: p}
+		}
+		return byPeriod[p]
+	}
+	err := s.read(ctx, func(db *gorm.DB) error {
+		clear(byPeriod)
+		created, err := countByPeriod(db, "created_at", period, r)
+		if err != nil {
+			return err
+		}
+		deleted, err := countByPeriod(db.Where("deleted_at IS NOT NULL"), "deleted_at", period, r)
+		if err != nil {
+			return err
+		}
+		for _, c := range created {
+			entry(c.Period).Enrolled = c.Count
+		}
+		for _, c := range deleted {
+			entry(c.Period).Withdrawn = c.Count
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	periods := make([]model.ChurnPeriod, 0, len(byPeriod))
+	for _, p := range byPeriod {
+		p.Net = p.Enrolled - p.Withdrawn
+		periods = append(periods, *p)
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Period < periods[j].Period })
+	return periods, nil
+}
+
+type periodCount struct {
+	Period string
+	Count  int64
+}
+
+// countByPeriod counts students, deleted ones included, by the week or month
+// of column.
+func countByPeriod(db *gorm.DB, column, period string, r model.ReportRange) ([]periodCount, error) {
+	bucket, err := periodStart(db, column, period)
+	if err != nil {
+		return nil, err
+	}
+	var counts []periodCount
+	err = withinRange(db.Unscoped().Model(&model.Student{}), column, r).
+		Select(bucket + " AS period, COUNT(*) AS count").
+		Group("period").
+		Order("period").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// periodStart returns the SQL for the first day of the week or month of
+// column, as YYYY-MM-DD text. Weeks start on Monday on both databases.
+func periodStart(db *gorm.DB, column, period string) (string, error) {
+	postgres := db.Dialector.Name() == "postgres"
+	switch {
+	case period == model.PeriodWeek && postgres:
+		return fmt.Sprintf("to_char(date_trunc('week', %s), 'YYYY-MM-DD')", column), nil
+	case period == model.PeriodMonth && postgres:
+		return fmt.Sprintf("to_char(date_trunc('month', %s), 'YYYY-MM-DD')", column), nil
+	case period == model.PeriodWeek:
+		// Move forward to the next Sunday, or stay on one, then back to the
+		// Monday before it.
+		return fmt.Sprintf("date(%s, 'weekday 0', '-6 days')", column), nil
+	case period == model.PeriodMonth:
+		return fmt.Sprintf("date(%s, 'start of month')", column), nil
+	default:
+		return "", validatePeriod(period)
+	}
+}
+
+func withinRange(db *gorm.DB, column string, r model.ReportRange) *gorm.DB {
+	if !r.From.IsZero() {
+		db = db.Where(column+" >= ?", r.From)
+	}
+	if !r.To.IsZero() {
+		db = db.Where(column+" < ?", r.To)
+	}
+	return db
+}
+
+func validatePeriod(period string) error {
+	if period != model.PeriodWeek && period != model.PeriodMonth {
+		return invalid("invalid period %q: use %s or %s", period, model.PeriodWeek, model.PeriodMonth)
+	}
+	return nil
+}
+
+func validateRange(r model.ReportRange) error {
+	if !r.From.IsZero() && !r.To.IsZero() && !r.From.Before(r.To) {
+		return invalid("the start of the range must be before its end")
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/one2n/student-api/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// enrollAt creates a student and backdates its creation.
+func enrollAt(t *testing.T, db *gorm.DB, service *StudentService, email string, age int, grade string, created time.Time) *model.Student {
+	student, err := service.CreateStudent(context.Background(), &model.Student{Name: "Student", Email: email, Age: age, Grade: grade})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Model(student).UpdateColumn("created_at", created).Error)
+	return student
+}
+
+func TestReports(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewStudentService(db)
+	ctx := context.Background()
+
+	// 2026-03-02 is a Monday.
+	monday := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	enrollAt(t, db, service, "a@example.com", 18, "12", monday)
+	enrollAt(t, db, service, "b@example.com", 19, "10", monday.Add(6*24*time.Hour+14*time.Hour)) // Sunday night
+	enrollAt(t, db, service, "c@example.com", 19, "10", monday.AddDate(0, 0, 7))
+	left := enrollAt(t, db, service, "d@example.com", 20, "11", monday.AddDate(0, 1, 0))
+	assert.NoError(t, service.DeleteStudent(ctx, left.ID))
+	assert.NoError(t, db.Unscoped().Model(left).UpdateColumn("deleted_at", monday.AddDate(0, 1, 10)).Error)
+
+	grades, err := service.GradeReport(ctx, model.ReportRange{})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.GradeCount{{Grade: "10", Students: 2}, {Grade: "12", Students: 1}}, grades)
+
+	ages, err := service.AgeReport(ctx, model.ReportRange{From: monday.AddDate(0, 0, 1)})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.AgeCount{{Age: 19, Students: 2}}, ages)
+
+	weeks, err := service.EnrollmentReport(ctx, model.ReportRange{}, model.PeriodWeek)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.EnrollmentPeriod{
+		{Period: "2026-03-02", Enrolled: 2},
+		{Period: "2026-03-09", Enrolled: 1},
+		{Period: "2026-03-30", Enrolled: 1},
+	}, weeks)
+
+	months, err := service.EnrollmentReport(ctx, model.ReportRange{To: monday.AddDate(0, 1, 0)}, model.PeriodMonth)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.EnrollmentPeriod{{Period: "2026-03-01", Enrolled: 3}}, months)
+
+	churn, err := service.ChurnReport(ctx, model.ReportRange{}, model.PeriodMonth)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ChurnPeriod{
+		{Period: "2026-03-01", Enrolled: 3, Withdrawn: 0, Net: 3},
+		{Period: "2026-04-01", Enrolled: 1, Withdrawn: 1, Net: 0},
+	}, churn)
+
+	var validationErr *ValidationError
+	_, err = service.EnrollmentReport(ctx, model.ReportRange{}, "year")
+	assert.ErrorAs(t, err, &validationErr)
+	_, err = service.GradeReport(ctx, model.ReportRange{From: monday, To: monday})
+	assert.ErrorAs(t, err, &validationErr)
+
+	other, err := service.ForTenant("other").(*StudentService).GradeReport(ctx, model.ReportRange{})
+	assert.NoError(t, err)
+	assert.Empty(t, other, "reports only count the tenant's students")
+}
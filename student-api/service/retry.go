@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/one2n/student-api/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RetryPolicy controls how transactions that fail with a transient database
+// error, such as a serialization failure, a deadlock or a dropped connection,
+// are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of tries, including the first. Values below
+	// two disable retries.
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry. It doubles on every
+	// further retry, up to MaxBackoff, with jitter so that transactions that
+	// collided do not collide again.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, InitialBackoff: 50 * time.Millisecond, MaxBackoff: time.Second}
+}
+
+var retries atomic.Int64
+
+// Retries returns the number of transaction retries made so far, for
+// monitoring.
+func Retries() int64 {
+	return retries.Load()
+}
+
+// do runs fn until it succeeds, fails with an error that is not transient,
+// runs out of attempts or ctx is done.
+func (p RetryPolicy) do(ctx context.Context, fn func() error) error {
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !isTransient(err) || ctx.Err() != nil {
+			return err
+		}
+		retries.Add(1)
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt), attribute.String("error.type", tracing.ErrorClass(err))))
+		log.Printf("Retrying transaction after transient error (attempt %d of %d): %v", attempt, p.MaxAttempts, err)
+		if backoff > 0 {
+			timer := time.NewTimer(backoff/2 + rand.N(backoff/2+1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+		backoff = min(backoff*2, p.MaxBackoff)
+	}
+}
+
+// isTransient reports whether err is worth retrying the whole transaction
+// for: it failed because of other transactions or the connection, not
+// because of what it did.
+func isTransient(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"53300", // too_many_connections
+			"57P01": // admin_shutdown
+			return true
+		}
+		// Class 08 is connection exceptions.
+		return len(pgErr.Code) == 5 && pgErr.Code[:2] == "08"
+	}
+	return errors.Is(err, driver.ErrBadConn) || pgconn.SafeToRetry(err)
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/one2n/student-api/model"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&pgconn.PgError{Code: "40001"}, true},
+		{&pgconn.PgError{Code: "40P01"}, true},
+		{&pgconn.PgError{Code: "08006"}, true},
+		{fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: "40001"}), true},
+		{driver.ErrBadConn, true},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{ErrStudentNotFound, false},
+		{errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, isTransient(tt.err), "%v", tt.err)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+	calls := 0
+	err := policy.do(context.Background(), func() error {
+		calls++
+		return &pgconn.PgError{Code: "40001"}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = policy.do(context.Background(), func() error {
+		calls++
+		return ErrEmailExists
+	})
+	assert.ErrorIs(t, err, ErrEmailExists)
+	assert.Equal(t, 1, calls, "other errors are not retried")
+
+	calls = 0
+	assert.NoError(t, RetryPolicy{}.do(context.Background(), func() error {
+		calls++
+		return nil
+	}))
+	assert.Equal(t, 1, calls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls = 0
+	err = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}.do(ctx, func() error {
+		calls++
+		cancel()
+		return &pgconn.PgError{Code: "40001"}
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls, "a cancelled context stops retries")
+}
+
+// failCreates makes the next n inserts into table fail with a serialization
+// failure, as a concurrent transaction would on Postgres.
+func failCreates(t *testing.T, db *gorm.DB, table string, n int) {
+	err := db.Callback().Create().Before("gorm:create").Register("test:fail_"+table, func(tx *gorm.DB) {
+		if tx.Statement.Table == table && n > 0 {
+			n--
+			tx.AddError(&pgconn.PgError{Code: "40001", Message: "could not serialize access"})
+		}
+	})
+	assert.NoError(t, err)
+}
+
+func TestTransactionsRetryTransientErrors(t *testing.T) {
+	db := setupTestDB(t)
+	cfg := DefaultConfig()
+	cfg.Retry.InitialBackoff = 0
+	service := NewStudentServiceWithConfig(db, cfg)
+
+	before := Retries()
+	failCreates(t, db, "audit_entries", 1)
+	created, err := service.CreateStudent(context.Background(), &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, Retries())
+	var count int64
+	assert.NoError(t, db.Model(&model.Student{}).Where("email_index = ?", "ann@example.com").Count(&count).Error)
+	assert.Equal(t, int64(1), count, "the failed attempt was rolled back")
+
+	failCreates(t, db, "guardians", 1)
+	outcomes, err := service.RunBatch(context.Background(), []model.BatchOperation{
+		{Op: model.BatchUpdate, ID: created.ID, Student: &model.Student{Name: "Ann B", Email: "ann@example.com", Age: 20, Grade: "10"}},
+		{Op: model.BatchCreate, Student: &model.Student{
+			Name: "Tim", Email: "tim@example.com", Age: 12, Grade: "10",
+			Guardians: []model.StudentGuardian{newGuardianLink("Mary", model.RelationshipMother)},
+		}},
+	}, true)
+	assert.NoError(t, err)
+	for _, outcome := range outcomes {
+		assert.NoError(t, outcome.Err)
+	}
+	assert.Len(t, outcomes[1].Student.Guardians, 1, "the retried batch starts from the request")
+
+	failCreates(t, db, "promotion_batches", 5)
+	_, err = service.PromoteGrade(context.Background(), "10", false)
+	var pgErr *pgconn.PgError
+	assert.ErrorAs(t, err, &pgErr, "retries give up after MaxAttempts")
+	found, err := service.GetStudentByID(context.Background(), created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "10", found.Grade)
+}
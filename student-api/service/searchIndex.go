@@ -0,0 +1,199 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/one2n/student-api/model"
+	"gorm.io/gorm"
+)
+
+// searchIndexOverlap is how far before its previous refresh the search index
+// looks for changed rows, so that rows written by transactions still open at
+// the time, or by servers whose clocks run behind, are not missed.
+const searchIndexOverlap = time.Minute
+
+// searchIndex is the in-process search index, used when names and emails are
+// encrypted at rest or the database has no full-text search. It keeps the
+// searchable fields of every student, decrypted, along with the words they
+// contain. Before each search it reads the rows changed since the previous
+// one, so writes made by other servers are picked up too.
+type searchIndex struct {
+	mu      sync.Mutex
+	tenants map[string]*tenantSearchIndex
+}
+
+type tenantSearchIndex struct {
+	mu sync.Mutex
+	// docs holds the searchable fields of each student by ID.
+	docs map[string]*model.Student
+	// words maps every word of docs to the IDs of the students it is in.
+	words map[string]map[string]struct{}
+	// syncedAt is when the last refresh started; zero until the index is
+	// built.
+	syncedAt time.Time
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{tenants: make(map[string]*tenantSearchIndex)}
+}
+
+func (x *searchIndex) tenant(tenantID string) *tenantSearchIndex {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	t, ok := x.tenants[tenantID]
+	if !ok {
+		t = &tenantSearchIndex{
+			docs:  make(map[string]*model.Student),
+			words: make(map[string]map[string]struct{}),
+		}
+		x.tenants[tenantID] = t
+	}
+	return t
+}
+
+// forget drops a student from the index at once, rather than at the next
+// refresh; erased personal data must not linger in memory.
+func (x *searchIndex) forget(tenantID, studentID string) {
+	t := x.tenant(tenantID)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remove(studentID)
+}
+
+// search refreshes the index of the tenant db is scoped to and returns the
+// IDs of the students matching every term, best first, with at most limit
+// entries.
+func (x *searchIndex) search(db *gorm.DB, tenantID string, terms []string, limit int) ([]string, error) {
+	t := x.tenant(tenantID)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.refresh(db); err != nil {
+		return nil, err
+	}
+
+	var candidates map[string]struct{}
+	for _, term := range terms {
+		matches := make(map[string]struct{})
+		for word, ids := range t.words {
+			if termScore(term, word) == 0 {
+				continue
+			}
+			for id := range ids {
+				if _, ok := candidates[id]; ok || candidates == nil {
+					matches[id] = struct{}{}
+				}
+			}
+		}
+		if len(matches) == 0 {
+			return nil, nil
+		}
+		candidates = matches
+	}
+
+	type hit struct {
+		id    string
+		score float64
+	}
+	hits := make([]hit, 0, len(candidates))
+	for id := range candidates {
+		if score, _ := matchStudent(t.docs[id], terms); score > 0 {
+			hits = append(hits, hit{id: id, score: score})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].id < hits[j].id
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	ids := make([]string, len(hits))
+	for i, h := range hits {
+		ids[i] = h.id
+	}
+	return ids, nil
+}
+
+// refresh builds the index on first use, and afterwards applies the rows
+// created, updated, deleted or erased since the previous refresh.
+func (t *tenantSearchIndex) refresh(db *gorm.DB) error {
+	started := time.Now()
+	if t.syncedAt.IsZero() {
+		var batch []*model.Student
+		err := db.Select("id, name, email, grade").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for _, student := range batch {
+				t.put(student)
+			}
+			return nil
+		}).Error
+		if err != nil {
+			return err
+		}
+		t.syncedAt = started
+		return nil
+	}
+
+	since := t.syncedAt.Add(-searchIndexOverlap)
+	var changed []*model.Student
+	err := db.Unscoped().Select("id, name, email, grade, deleted_at").
+		Where("(updated_at >= ? OR deleted_at >= ?)", since, since).
+		Find(&changed).Error
+	if err != nil {
+		return err
+	}
+	for _, student := range changed {
+		if student.DeletedAt.Valid {
+			t.remove(student.ID)
+		} else {
+			t.put(student)
+		}
+	}
+	var erased []string
+	if err := db.Model(&model.StudentTombstone{}).Where("erased_at >= ?", since).Pluck("student_id", &erased).Error; err != nil {
+		return err
+	}
+	for _, id := range erased {
+		t.remove(id)
+	}
+	t.syncedAt = started
+	return nil
+}
+
+func (t *tenantSearchIndex) put(student *model.Student) {
+	t.remove(student.ID)
+	doc := &model.Student{ID: student.ID, Name: student.Name, Email: student.Email, Grade: student.Grade}
+	t.docs[doc.ID] = doc
+	for _, field := range searchFields {
+		for _, word := range wordSpans(field.value(doc)) {
+			word := strings.ToLower(word.text)
+			ids, ok := t.words[word]
+			if !ok {
+				ids = make(map[string]struct{})
+				t.words[word] = ids
+			}
+			ids[doc.ID] = struct{}{}
+		}
+	}
+}
+
+func (t *tenantSearchIndex) remove(id string) {
+	doc, ok := t.docs[id]
+	if !ok {
+		return
+	}
+	delete(t.docs, id)
+	for _, field := range searchFields {
+		for _, word := range wordSpans(field.value(doc)) {
+			word := strings.ToLower(word.text)
+			delete(t.words[word], id)
+			if len(t.words[word]) == 0 {
+				delete(t.words, word)
+			}
+		}
+	}
+}
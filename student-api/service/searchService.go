@@ -0,0 +1,447 @@
+package service
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/pii"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// searchDocument is the SQL expression searched on Postgres.
+const searchDocument = `(coalesce(name, '') || ' ' || coalesce(email, '') || ' ' || coalesce(grade, ''))`
+
+// searchFields lists the searchable fields and how much a match in each counts.
+var searchFields = []struct {
+	name   string
+	weight float64
+	value  func(*model.Student) string
+}{
+	{name: "name", weight: 1.0, value: func(s *model.Student) string { return s.Name }},
+	{name: "email", weight: 0.8, value: func(s *model.Student) string { return s.Email }},
+	{name: "grade", weight: 0.5, value: func(s *model.Student) string { return s.Grade }},
+}
+
+// maxSearchCandidates caps the rows SQLite full-text search hands to
+// matchStudent for ranking.
+const maxSearchCandidates = 1000
+
+// maxTermExpansions caps how many indexed words a query term may stand for
+// in a full-text query, besides those it is a prefix of.
+const maxTermExpansions = 50
+
+// migrateSearch prepares the full-text search of the database and reports
+// whether SearchStudents can use it. On Postgres that is a trigram index; on
+// SQLite an FTS5 table kept up to date by triggers, available when the driver
+// is built with the sqlite_fts5 tag. Neither can search encrypted names and
+// emails, so with a keyring installed the FTS5 table is dropped and the
+// in-process index is used instead.
+func migrateSearch(db *gorm.DB) (bool, error) {
+	switch db.Dialector.Name() {
+	case "postgres":
+		if err := db.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+			return false, err
+		}
+		err := db.Exec("CREATE INDEX IF NOT EXISTS idx_students_search_trgm ON students USING gin (" +
+			searchDocument + " gin_trgm_ops)").Error
+		return err == nil && pii.Installed() == nil, err
+	case "sqlite":
+		return migrateSQLiteSearch(db)
+	}
+	return false, nil
+}
+
+var sqliteSearchStatements = []string{
+	`CREATE VIRTUAL TABLE students_fts USING fts5(name, email, grade, content='students', tokenize='unicode61 remove_diacritics 0')`,
+	`CREATE VIRTUAL TABLE students_fts_vocab USING fts5vocab(students_fts, row)`,
+	`CREATE TRIGGER students_fts_insert AFTER INSERT ON students BEGIN
+		INSERT INTO students_fts(rowid, name, email, grade) VALUES (new.rowid, new.name, new.email, new.grade);
+	END`,
+	`CREATE TRIGGER students_fts_delete AFTER DELETE ON students BEGIN
+		INSERT INTO students_fts(students_fts, rowid, name, email, grade) VALUES ('delete', old.rowid, old.name, old.email, old.grade);
+	END`,
+	`CREATE TRIGGER students_fts_update AFTER UPDATE ON students BEGIN
+		INSERT INTO students_fts(students_fts, rowid, name, email, grade) VALUES ('delete', old.rowid, old.name, old.email, old.grade);
+		INSERT INTO students_fts(rowid, name, email, grade) VALUES (new.rowid, new.name, new.email, new.grade);
+	END`,
+	`INSERT INTO students_fts(students_fts) VALUES ('rebuild')`,
+}
+
+func migrateSQLiteSearch(db *gorm.DB) (bool, error) {
+	exists := db.Migrator().HasTable("students_fts")
+	if pii.Installed() != nil {
+		if !exists {
+			return false, nil
+		}
+		return false, db.Transaction(func(tx *gorm.DB) error {
+			for _, stmt := range []string{
+				"DROP TRIGGER IF EXISTS students_fts_insert",
+				"DROP TRIGGER IF EXISTS students_fts_delete",
+				"DROP TRIGGER IF EXISTS students_fts_update",
+				"DROP TABLE IF EXISTS students_fts_vocab",
+				"DROP TABLE IF EXISTS students_fts",
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if exists {
+		return true, nil
+	}
+	var fts5 bool
+	if err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5).Error; err != nil || !fts5 {
+		return false, err
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range sqliteSearchStatements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err == nil, err
+}
+
+// SearchStudents returns students ranked by how well their name, email or
+// grade match query, tolerating small typos. On Postgres ranking is done with
+// full-text and trigram similarity, and on SQLite candidates come from FTS5.
+// Whenever names and emails are encrypted at rest, or the database has no
+// full-text search, the in-process search index is used.
+func (s *StudentService) SearchStudents(ctx context.Context, query string, limit int) ([]model.StudentSearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, invalid("search query must contain at least one letter or digit")
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	if !s.fullText || pii.Installed() != nil {
+		return s.searchIndexed(ctx, terms, limit)
+	}
+	var results []model.StudentSearchResult
+	err := s.read(ctx, func(db *gorm.DB) error {
+		var err error
+		if db.Dialector.Name() == "postgres" {
+			results, err = searchPostgres(db, query, terms, limit)
+		} else {
+			results, err = searchSQLite(db, terms, limit)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func searchPostgres(db *gorm.DB, query string, terms []string, limit int) ([]model.StudentSearchResult, error) {
+	var hits []struct {
+		ID    string
+		Score float64
+	}
+	err := db.Model(&model.Student{}).
+		Select("id, ts_rank(to_tsvector('simple', "+searchDocument+"), plainto_tsquery('simple', ?)) + "+
+			"word_similarity(?, "+searchDocument+") AS score", query, query).
+		Where("to_tsvector('simple', "+searchDocument+") @@ plainto_tsquery('simple', ?) OR ? <% "+searchDocument,
+			query, query).
+		Order("score DESC").
+		Limit(limit).
+		Scan(&hits).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(hits) == 0 {
+		return []model.StudentSearchResult{}, nil
+	}
+
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	var students []*model.Student
+	if err := db.Where("id IN ?", ids).Find(&students).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*model.Student, len(students))
+	for _, student := range students {
+		byID[student.ID] = student
+	}
+
+	results := make([]model.StudentSearchResult, 0, len(hits))
+	for _, hit := range hits {
+		student, ok := byID[hit.ID]
+		if !ok {
+			continue
+		}
+		_, highlights := matchStudent(student, terms)
+		results = append(results, model.StudentSearchResult{Student: student, Score: hit.Score, Highlights: highlights})
+	}
+	return results, nil
+}
+
+// searchSQLite finds candidates with FTS5, each term matching the words it is
+// a prefix of or, looked up in the vocabulary, close to, and ranks them with
+// matchStudent.
+func searchSQLite(db *gorm.DB, terms []string, limit int) ([]model.StudentSearchResult, error) {
+	var vocabulary []string
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) >= 3 {
+			if err := db.Raw("SELECT term FROM students_fts_vocab").Scan(&vocabulary).Error; err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	expressions := make([]string, len(terms))
+	for i, term := range terms {
+		alternatives := []string{`"` + term + `"*`}
+		for _, word := range expandTerm(term, vocabulary) {
+			alternatives = append(alternatives, `"`+word+`"`)
+		}
+		expressions[i] = "(" + strings.Join(alternatives, " OR ") + ")"
+	}
+
+	var candidates []*model.Student
+	err := db.Joins("JOIN students_fts ON students_fts.rowid = students.rowid").
+		Where("students_fts MATCH ?", strings.Join(expressions, " AND ")).
+		Order("bm25(students_fts, 1.0, 0.8, 0.5)").
+		Limit(maxSearchCandidates).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	return rankStudents(candidates, terms, limit), nil
+}
+
+// expandTerm returns the words of vocabulary that term matches other than as
+// a prefix, best first.
+func expandTerm(term string, vocabulary []string) []string {
+	type match struct {
+		word  string
+		score float64
+	}
+	var matches []match
+	for _, word := range vocabulary {
+		if strings.HasPrefix(word, term) {
+			continue
+		}
+		if score := termScore(term, word); score > 0 {
+			matches = append(matches, match{word: word, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	if len(matches) > maxTermExpansions {
+		matches = matches[:maxTermExpansions]
+	}
+	words := make([]string, len(matches))
+	for i, m := range matches {
+		words[i] = m.word
+	}
+	return words
+}
+
+// searchIndexed finds students through the in-process search index and loads
+// the best of them.
+func (s *StudentService) searchIndexed(ctx context.Context, terms []string, limit int) ([]model.StudentSearchResult, error) {
+	var students []*model.Student
+	err := s.read(ctx, func(db *gorm.DB) error {
+		ids, err := s.search.search(db, s.tenantID, terms, limit)
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		return db.Where("id IN ?", ids).Find(&students).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rankStudents(students, terms, limit), nil
+}
+
+// rankStudents scores students against the query terms and returns the best
+// limit of those matching.
+func rankStudents(students []*model.Student, terms []string, limit int) []model.StudentSearchResult {
+	results := []model.StudentSearchResult{}
+	for _, student := range students {
+		score, highlights := matchStudent(student, terms)
+		if score > 0 {
+			results = append(results, model.StudentSearchResult{Student: student, Score: score, Highlights: highlights})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// matchStudent scores a student against the query terms. Every term has to
+// match some word of some field, otherwise the score is zero.
+func matchStudent(student *model.Student, terms []string) (float64, map[string]string) {
+	var total float64
+	matched := make(map[string][]span)
+	for _, term := range terms {
+		best := 0.0
+		for _, field := range searchFields {
+			for _, word := range wordSpans(field.value(student)) {
+				score := termScore(term, strings.ToLower(word.text))
+				if score == 0 {
+					continue
+				}
+				matched[field.name] = append(matched[field.name], word)
+				if weighted := score * field.weight; weighted > best {
+					best = weighted
+				}
+			}
+		}
+		if best == 0 {
+			return 0, nil
+		}
+		total += best
+	}
+
+	highlights := make(map[string]string, len(matched))
+	for _, field := range searchFields {
+		if spans, ok := matched[field.name]; ok {
+			highlights[field.name] = highlight(field.value(student), spans)
+		}
+	}
+	return total / float64(len(terms)), highlights
+}
+
+// termScore rates how well a query term matches a single word: exact matches
+// beat prefixes, which beat substrings, which beat near-misses within a small
+// edit distance.
+func termScore(term, word string) float64 {
+	termLen := utf8.RuneCountInString(term)
+	switch {
+	case term == word:
+		return 1
+	case strings.HasPrefix(word, term):
+		return 0.9
+	case termLen >= 3 && strings.Contains(word, term):
+		return 0.7
+	}
+
+	allowed := 0
+	switch {
+	case termLen >= 8:
+		allowed = 2
+	case termLen >= 4:
+		allowed = 1
+	}
+	if allowed == 0 {
+		return 0
+	}
+	// Compare against the word and against its prefix of the same length, so
+	// a misspelled partial name ("jonh" for "johnson") still matches.
+	distance := editDistance(term, word)
+	if runes := []rune(word); len(runes) > termLen {
+		if d := editDistance(term, string(runes[:termLen])); d < distance {
+			distance = d
+		}
+	}
+	if distance > allowed {
+		return 0
+	}
+	return 0.6 - 0.1*float64(distance)
+}
+
+func searchTerms(query string) []string {
+	var terms []string
+	for _, word := range wordSpans(query) {
+		terms = append(terms, strings.ToLower(word.text))
+	}
+	return terms
+}
+
+type span struct {
+	start, end int
+	text       string
+}
+
+// wordSpans splits s into runs of letters and digits, remembering where each
+// one starts so it can be highlighted in place.
+func wordSpans(s string) []span {
+	var spans []span
+	start := -1
+	for i, r := range s {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			spans = append(spans, span{start: start, end: i, text: s[start:i]})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start: start, end: len(s), text: s[start:]})
+	}
+	return spans
+}
+
+func highlight(value string, spans []span) string {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	var b strings.Builder
+	pos := 0
+	for _, sp := range spans {
+		if sp.start < pos {
+			continue
+		}
+		b.WriteString(html.EscapeString(value[pos:sp.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(value[sp.start:sp.end]))
+		b.WriteString("</mark>")
+		pos = sp.end
+	}
+	b.WriteString(html.EscapeString(value[pos:]))
+	return b.String()
+}
+
+// editDistance is the optimal string alignment distance between a and b:
+// insertions, deletions, substitutions and adjacent transpositions each cost
+// one, so "jhon" is a single edit away from "john".
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
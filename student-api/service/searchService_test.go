@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/one2n/student-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchStudents(t *testing.T) {
+	t.Run("index", func(t *testing.T) {
+		service := NewStudentService(setupTestDB(t))
+		service.fullText = false
+		testSearchStudents(t, service)
+	})
+	t.Run("fts5", func(t *testing.T) {
+		service := NewStudentService(setupTestDB(t))
+		if !service.fullText {
+			t.Skip("SQLite is built without FTS5; run with -tags sqlite_fts5")
+		}
+		testSearchStudents(t, service)
+	})
+}
+
+func testSearchStudents(t *testing.T, service *StudentService) {
+
+	students := []*model.Student{
+		{Name: "Johnathan Smith", Email: "jsmith@example.com", Age: 20, Grade: "10"},
+		{Name: "John Doe", Email: "john@doe.com", Age: 20, Grade: "11"},
+		{Name: "Jane <Roe>", Email: "jane@example.com", Age: 20, Grade: "12"},
+	}
+	for _, student := range students {
+		_, err := service.CreateStudent(context.Background(), student)
+		assert.NoError(t, err)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantFirst string
+		wantCount int
+	}{
+		{name: "exact name ranks first", query: "john", wantFirst: "John Doe", wantCount: 2},
+		{name: "typo", query: "jhon doe", wantFirst: "John Doe", wantCount: 1},
+		{name: "partial email", query: "smith", wantFirst: "Johnathan Smith", wantCount: 1},
+		{name: "grade", query: "12", wantFirst: "Jane <Roe>", wantCount: 1},
+		{name: "no match", query: "zebra", wantCount: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := service.SearchStudents(context.Background(), tt.query, 0)
+			assert.NoError(t, err)
+			assert.Len(t, results, tt.wantCount)
+			if tt.wantFirst != "" && len(results) > 0 {
+				assert.Equal(t, tt.wantFirst, results[0].Student.Name)
+			}
+		})
+	}
+
+	results, err := service.SearchStudents(context.Background(), "roe", 0)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "Jane &lt;<mark>Roe</mark>&gt;", results[0].Highlights["name"])
+	}
+
+	_, err = service.SearchStudents(context.Background(), "  ", 0)
+	assert.Error(t, err)
+
+	// Changes are searchable at once, and other tenants' students never are.
+	updated, err := service.UpdateStudent(context.Background(), results[0].Student.ID,
+		&model.Student{Name: "Janet Roe", Email: "janet@example.com", Age: 20, Grade: "12"})
+	assert.NoError(t, err)
+	results, err = service.SearchStudents(context.Background(), "janet", 0)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	results, err = service.SearchStudents(context.Background(), "roe", 0)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "Janet <mark>Roe</mark>", results[0].Highlights["name"], "the old name is gone")
+	}
+
+	_, err = service.ForTenant("other").CreateStudent(context.Background(),
+		&model.Student{Name: "Janet Other", Email: "janet@other.com", Age: 20, Grade: "12"})
+	assert.NoError(t, err)
+	results, err = service.SearchStudents(context.Background(), "janet", 0)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	assert.NoError(t, service.DeleteStudent(context.Background(), updated.ID))
+	results, err = service.SearchStudents(context.Background(), "janet", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestSearchIndex(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewStudentService(db)
+	service.fullText = false
+	ctx := context.Background()
+
+	ann, err := service.CreateStudent(ctx, &model.Student{Name: "Ann Hale", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	results, err := service.SearchStudents(ctx, "hale", 0)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	index := service.search.tenant(model.DefaultTenantID)
+	assert.Contains(t, index.words, "hale")
+
+	// Rows written by another server are picked up by the next search.
+	other := NewStudentService(db)
+	_, err = other.CreateStudent(ctx, &model.Student{Name: "Ben Hale", Email: "ben@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	_, err = other.UpdateStudent(ctx, ann.ID, &model.Student{Name: "Ann Moss", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	results, err = service.SearchStudents(ctx, "hale", 0)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "Ben Hale", results[0].Student.Name)
+	}
+
+	// Erased students leave the index at once.
+	_, err = service.EraseStudent(ctx, ann.ID)
+	assert.NoError(t, err)
+	assert.NotContains(t, index.docs, ann.ID)
+	assert.NotContains(t, index.words, "moss")
+}
+
+func TestTermScore(t *testing.T) {
+	tests := []struct {
+		term, word string
+		matches    bool
+	}{
+		{"john", "john", true},
+		{"jo", "john", true},
+		{"ohn", "john", true},
+		{"jhon", "john", true},
+		{"jonh", "johnson", true},
+		{"jx", "john", false},
+		{"mary", "john", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.matches, termScore(tt.term, tt.word) > 0, "%s vs %s", tt.term, tt.word)
+	}
+}
@@ -1,111 +1,321 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/one2n/student-api/events"
 	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/storage"
+	"github.com/one2n/student-api/tracing"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// Config holds the business rules that vary between deployments.
+type Config struct {
+	// GuardianRequiredBelowAge is the age under which a student must have at
+	// least one guardian on file. Zero disables the rule.
+	GuardianRequiredBelowAge int
+	// GradeLevels is the ordered list of valid grades, lowest first. Promotion
+	// moves students from one level to the next and graduates the last one.
+	GradeLevels []string
+	// MaxBatchOperations caps the number of operations in one batch request.
+	// Zero means no limit.
+	MaxBatchOperations int
+	// Publisher receives an event for every committed create, update and
+	// delete. Nil disables events.
+	Publisher events.Publisher
+	// Files stores uploaded student files. Nil disables uploads.
+	Files storage.Store
+	// MaxFileSize caps the size of one uploaded file, in bytes. Zero means
+	// no limit.
+	MaxFileSize int64
+	// Retry decides how transactions failing with transient errors are
+	// retried.
+	Retry RetryPolicy
+	// Replicas serves read-only queries. Nil sends everything to the
+	// primary.
+	Replicas *Replicas
+	// StripPlusAddressing treats "ann+tag@example.com" as the same address
+	// as "ann@example.com" when checking that emails are unique.
+	StripPlusAddressing bool
+}
+
+func DefaultConfig() Config {
+	return Config{
+		GuardianRequiredBelowAge: 18,
+		GradeLevels:              DefaultGradeLevels,
+		MaxBatchOperations:       100,
+		MaxFileSize:              10 << 20,
+		Retry:                    DefaultRetryPolicy(),
+	}
+}
+
 type StudentService struct {
-	db *gorm.DB
+	// db is scoped to tenantID; base is the same connection without the
+	// tenant scope, used to derive services for other tenants.
+	db       *gorm.DB
+	base     *gorm.DB
+	tenantID string
+	cfg      Config
+	// pending collects events raised inside a transaction that is still
+	// open; they are published once it commits.
+	pending *[]events.Event
+	// nested is set when db is a transaction opened by the caller.
+	nested bool
+	// fullText is set when SearchStudents can use the database's full-text
+	// search; otherwise it uses search, shared by all tenants.
+	fullText bool
+	search   *searchIndex
 }
 
 func NewStudentService(db *gorm.DB) *StudentService {
-	err := db.AutoMigrate(&model.Student{})
+	return NewStudentServiceWithConfig(db, DefaultConfig())
+}
+
+func NewStudentServiceWithConfig(db *gorm.DB, cfg Config) *StudentService {
+	if len(cfg.GradeLevels) == 0 {
+		cfg.GradeLevels = DefaultGradeLevels
+	}
+	err := db.AutoMigrate(&model.Student{}, &model.Guardian{}, &model.StudentGuardian{},
+		&model.PromotionBatch{}, &model.PromotionRecord{}, &model.AuditEntry{}, &model.StudentTombstone{}, &model.StudentFile{})
 	if err != nil {
 		fmt.Printf("Error migrating schema: %v\n", err)
 	}
-	return &StudentService{db: db}
+	if err := migrateEmailIndex(db, cfg); err != nil {
+		fmt.Printf("Error migrating email index: %v\n", err)
+	}
+	fullText, err := migrateSearch(db)
+	if err != nil {
+		fmt.Printf("Error preparing search index: %v\n", err)
+	}
+	s := &StudentService{base: db, cfg: cfg, fullText: fullText, search: newSearchIndex()}
+	return s.forTenant(model.DefaultTenantID)
+}
+
+// ForTenant returns a copy of the service whose queries only see, and whose
+// writes only create, rows owned by tenantID.
+func (s *StudentService) ForTenant(tenantID string) Students {
+	return s.forTenant(tenantID)
+}
+
+func (s *StudentService) forTenant(tenantID string) *StudentService {
+	clone := *s
+	clone.tenantID = tenantID
+	clone.db = s.base.Scopes(tenantScope(tenantID)).Session(&gorm.Session{})
+	return &clone
+}
+
+// tenantScope restricts a statement to rows owned by tenantID. Models without
+// a TenantID field, such as join tables, are left alone.
+func tenantScope(tenantID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		target := db.Statement.Model
+		if target == nil {
+			target = db.Statement.Dest
+		}
+		if target == nil || db.Statement.Parse(target) != nil || db.Statement.Schema.LookUpField("TenantID") == nil {
+			return db
+		}
+		return db.Where(clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: "tenant_id"},
+			Value:  tenantID,
+		})
+	}
+}
+
+// transaction runs fn in a transaction, and runs it again in a new one when
+// it fails with a transient error, so fn must not depend on state left over
+// from an earlier try. Inside a caller's transaction fn joins it instead and
+// retrying is left to whoever opened it.
+func (s *StudentService) transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	db := s.db.WithContext(ctx)
+	if s.nested {
+		return db.Transaction(fn)
+	}
+	err := s.cfg.Retry.do(ctx, func() error {
+		return db.Transaction(fn)
+	})
+	if err == nil && s.cfg.Replicas != nil {
+		s.cfg.Replicas.wrote(ctx)
+	}
+	return err
 }
 
-func (s *StudentService) CreateStudent(student *model.Student) (*model.Student, error) {
-	if student.Age <= 0 || student.Age > 100 {
-		return nil, errors.New("invalid age: must be between 1 and 100")
+func (s *StudentService) validateAge(age int) error {
+	if age <= 0 || age > 100 {
+		return invalid("invalid age: must be between 1 and 100")
 	}
+	return nil
+}
+
+// requiresGuardian reports whether a student of the given age must have a
+// guardian on file.
+func (s *StudentService) requiresGuardian(age int) bool {
+	return age < s.cfg.GuardianRequiredBelowAge
+}
 
-	// Check if email already exists
-	var existingStudent model.Student
-	if err := s.db.Where("email = ?", student.Email).First(&existingStudent).Error; err == nil {
-		return nil, errors.New("email already exists")
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, fmt.Errorf("error checking email uniqueness: %v", err)
+func (s *StudentService) CreateStudent(ctx context.Context, student *model.Student) (*model.Student, error) {
+	if err := s.validateAge(student.Age); err != nil {
+		return nil, err
 	}
+	if s.requiresGuardian(student.Age) && len(student.Guardians) == 0 {
+		return nil, invalid("at least one guardian is required for students under %d", s.cfg.GuardianRequiredBelowAge)
+	}
+	grade, err := s.NormalizeGrade(student.Grade)
+	if err != nil {
+		return nil, err
+	}
+	student.Grade = grade
 
 	student.ID = uuid.New().String()
+	student.TenantID = s.tenantID
+	student.Email = normalizeEmail(student.Email)
+	student.EmailIndex = s.emailIndex(student.Email)
+	student.Status = model.StatusEnrolled
 	student.CreatedAt = time.Now()
 	student.UpdatedAt = time.Now()
 
-	result := s.db.Create(student)
-	if result.Error != nil {
-		return nil, result.Error
+	links := student.Guardians
+	student.Guardians = nil
+	err = s.transaction(ctx, func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(student).Error; err != nil {
+			return emailConflict(err)
+		}
+		for i := range links {
+			if _, err := addGuardian(tx, s.tenantID, student.ID, &links[i]); err != nil {
+				return err
+			}
+		}
+		return audit(tx, s.tenantID, student.ID, model.AuditStudentCreated, "")
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	if len(links) > 0 {
+		student.Guardians, err = listGuardians(s.db.WithContext(ctx), student.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.emit(ctx, events.StudentCreated, student.ID, student)
 	return student, nil
 }
 
-func (s *StudentService) GetAllStudents() ([]*model.Student, error) {
+func (s *StudentService) GetAllStudents(ctx context.Context) ([]*model.Student, error) {
 	var students []*model.Student
-	result := s.db.Find(&students)
-	if result.Error != nil {
-		return nil, result.Error
+	err := s.read(ctx, func(db *gorm.DB) error {
+		return db.Find(&students).Error
+	})
+	if err != nil {
+		return nil, err
 	}
 	return students, nil
 }
 
-func (s *StudentService) GetStudentByID(id string) (*model.Student, error) {
+func (s *StudentService) GetStudentByID(ctx context.Context, id string) (*model.Student, error) {
 	var student model.Student
-	result := s.db.First(&student, "id = ?", id)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, errors.New("student not found")
+	err := s.read(ctx, func(db *gorm.DB) error {
+		return db.Preload("Guardians.Guardian").First(&student, "id = ?", id).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStudentNotFound
 		}
-		return nil, result.Error
+		return nil, err
 	}
 	return &student, nil
 }
 
-func (s *StudentService) UpdateStudent(id string, updatedStudent *model.Student) (*model.Student, error) {
+func (s *StudentService) UpdateStudent(ctx context.Context, id string, updatedStudent *model.Student) (*model.Student, error) {
 	var student model.Student
-	result := s.db.First(&student, "id = ?", id)
+	result := s.db.WithContext(ctx).First(&student, "id = ?", id)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, errors.New("student not found")
+			return nil, ErrStudentNotFound
 		}
 		return nil, result.Error
 	}
 
-	if updatedStudent.Email != student.Email {
-		var existingStudent model.Student
-		if err := s.db.Where("email = ? AND id != ?", updatedStudent.Email, id).First(&existingStudent).Error; err == nil {
-			return nil, errors.New("email already exists")
-		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("error checking email uniqueness: %v", err)
+	if err := s.validateAge(updatedStudent.Age); err != nil {
+		return nil, err
+	}
+	grade, err := s.NormalizeGrade(updatedStudent.Grade)
+	if err != nil {
+		return nil, err
+	}
+	if s.requiresGuardian(updatedStudent.Age) {
+		count, err := countGuardians(s.db.WithContext(ctx), id)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, invalid("at least one guardian is required for students under %d", s.cfg.GuardianRequiredBelowAge)
 		}
 	}
 
+	before := student
 	student.Name = updatedStudent.Name
-	student.Email = updatedStudent.Email
+	student.Email = normalizeEmail(updatedStudent.Email)
+	student.EmailIndex = s.emailIndex(updatedStudent.Email)
 	student.Age = updatedStudent.Age
-	student.Grade = updatedStudent.Grade
+	student.Grade = grade
 	student.UpdatedAt = time.Now()
 
-	result = s.db.Save(&student)
-	if result.Error != nil {
-		return nil, result.Error
+	err = s.transaction(ctx, func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(&student).Error; err != nil {
+			return emailConflict(err)
+		}
+		return audit(tx, s.tenantID, student.ID, model.AuditStudentUpdated, changedFields(&before, &student))
+	})
+	if err != nil {
+		return nil, err
 	}
+	s.emit(ctx, events.StudentUpdated, student.ID, &student)
 	return &student, nil
 }
 
-func (s *StudentService) DeleteStudent(id string) error {
-	result := s.db.Delete(&model.Student{}, "id = ?", id)
-	if result.Error != nil {
-		return result.Error
-	}
-	if result.RowsAffected == 0 {
-		return errors.New("student not found")
+func (s *StudentService) DeleteStudent(ctx context.Context, id string) error {
+	err := s.transaction(ctx, func(tx *gorm.DB) error {
+		result := tx.Delete(&model.Student{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStudentNotFound
+		}
+		return audit(tx, s.tenantID, id, model.AuditStudentDeleted, "")
+	})
+	if err != nil {
+		return err
 	}
+	s.emit(ctx, events.StudentDeleted, id, nil)
 	return nil
 }
+
+// emit publishes a change event, or queues it until the surrounding batch
+// transaction commits. The event carries the trace of the request that made
+// the change.
+func (s *StudentService) emit(ctx context.Context, eventType, studentID string, student *model.Student) {
+	if s.cfg.Publisher == nil {
+		return
+	}
+	event := events.Event{
+		Type:        eventType,
+		TenantID:    s.tenantID,
+		StudentID:   studentID,
+		Student:     student,
+		Time:        time.Now(),
+		TraceParent: tracing.TraceParent(ctx),
+	}
+	if s.pending != nil {
+		*s.pending = append(*s.pending, event)
+		return
+	}
+	s.cfg.Publisher.Publish(event)
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"testing"
 
 	"github.com/one2n/student-api/model"
@@ -31,7 +32,7 @@ func TestCreateStudent(t *testing.T) {
 				Name:  "John Doe",
 				Email: "john@example.com",
 				Age:   20,
-				Grade: "A",
+				Grade: "10",
 			},
 			wantErr: false,
 		},
@@ -41,7 +42,7 @@ func TestCreateStudent(t *testing.T) {
 				Name:  "John Doe",
 				Email: "john@example.com",
 				Age:   0, // Below minimum age
-				Grade: "A",
+				Grade: "10",
 			},
 			wantErr: true,
 			errMsg:  "invalid age: must be between 1 and 100",
@@ -52,7 +53,7 @@ func TestCreateStudent(t *testing.T) {
 				Name:  "Jane Doe",
 				Email: "john@example.com", // Same email as first test
 				Age:   21,
-				Grade: "B",
+				Grade: "11",
 			},
 			wantErr: true,
 			errMsg:  "email already exists",
@@ -61,7 +62,7 @@ func TestCreateStudent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			student, err := service.CreateStudent(tt.student)
+			student, err := service.CreateStudent(context.Background(), tt.student)
 			if tt.wantErr {
 				assert.Error(t, err)
 				if tt.errMsg != "" {
@@ -88,9 +89,9 @@ func TestGetStudentByID(t *testing.T) {
 		Name:  "John Doe",
 		Email: "john@example.com",
 		Age:   20,
-		Grade: "A",
+		Grade: "10",
 	}
-	createdStudent, err := service.CreateStudent(student)
+	createdStudent, err := service.CreateStudent(context.Background(), student)
 	assert.NoError(t, err)
 
 	tests := []struct {
@@ -112,7 +113,7 @@ func TestGetStudentByID(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			student, err := service.GetStudentByID(tt.id)
+			student, err := service.GetStudentByID(context.Background(), tt.id)
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
@@ -129,17 +130,17 @@ func TestGetAllStudents(t *testing.T) {
 
 	// Create test students
 	students := []*model.Student{
-		{Name: "John Doe", Email: "john@example.com", Age: 20, Grade: "A"},
-		{Name: "Jane Smith", Email: "jane@example.com", Age: 21, Grade: "B"},
+		{Name: "John Doe", Email: "john@example.com", Age: 20, Grade: "10"},
+		{Name: "Jane Smith", Email: "jane@example.com", Age: 21, Grade: "11"},
 	}
 
 	for _, student := range students {
-		_, err := service.CreateStudent(student)
+		_, err := service.CreateStudent(context.Background(), student)
 		assert.NoError(t, err)
 	}
 
 	// Test getting all students
-	retrievedStudents, err := service.GetAllStudents()
+	retrievedStudents, err := service.GetAllStudents(context.Background())
 	assert.NoError(t, err)
 	assert.Len(t, retrievedStudents, len(students))
 }
@@ -153,17 +154,17 @@ func TestUpdateStudent(t *testing.T) {
 		Name:  "John Doe",
 		Email: "john@example.com",
 		Age:   20,
-		Grade: "A",
+		Grade: "10",
 	}
 	student2 := &model.Student{
 		Name:  "Jane Smith",
 		Email: "jane@example.com",
 		Age:   21,
-		Grade: "B",
+		Grade: "11",
 	}
-	createdStudent1, err := service.CreateStudent(student1)
+	createdStudent1, err := service.CreateStudent(context.Background(), student1)
 	assert.NoError(t, err)
-	_, err = service.CreateStudent(student2)
+	_, err = service.CreateStudent(context.Background(), student2)
 	assert.NoError(t, err)
 
 	tests := []struct {
@@ -180,7 +181,7 @@ func TestUpdateStudent(t *testing.T) {
 				Name:  "John Updated",
 				Email: "john.updated@example.com",
 				Age:   21,
-				Grade: "A+",
+				Grade: "12",
 			},
 			wantErr: false,
 		},
@@ -191,7 +192,7 @@ func TestUpdateStudent(t *testing.T) {
 				Name:  "John Updated",
 				Email: "jane@example.com", // Using student2's email
 				Age:   21,
-				Grade: "A+",
+				Grade: "12",
 			},
 			wantErr: true,
 			errMsg:  "email already exists",
@@ -203,7 +204,7 @@ func TestUpdateStudent(t *testing.T) {
 				Name:  "John Updated",
 				Email: "john.updated@example.com",
 				Age:   21,
-				Grade: "A+",
+				Grade: "12",
 			},
 			wantErr: true,
 		},
@@ -211,7 +212,7 @@ func TestUpdateStudent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			updatedStudent, err := service.UpdateStudent(tt.id, tt.update)
+			updatedStudent, err := service.UpdateStudent(context.Background(), tt.id, tt.update)
 			if tt.wantErr {
 				assert.Error(t, err)
 				if tt.errMsg != "" {
@@ -237,9 +238,9 @@ func TestDeleteStudent(t *testing.T) {
 		Name:  "John Doe",
 		Email: "john@example.com",
 		Age:   20,
-		Grade: "A",
+		Grade: "10",
 	}
-	createdStudent, err := service.CreateStudent(student)
+	createdStudent, err := service.CreateStudent(context.Background(), student)
 	assert.NoError(t, err)
 
 	tests := []struct {
@@ -261,7 +262,7 @@ func TestDeleteStudent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := service.DeleteStudent(tt.id)
+			err := service.DeleteStudent(context.Background(), tt.id)
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
@@ -269,7 +270,7 @@ func TestDeleteStudent(t *testing.T) {
 			assert.NoError(t, err)
 
 			// Verify student is deleted
-			_, err = service.GetStudentByID(tt.id)
+			_, err = service.GetStudentByID(context.Background(), tt.id)
 			assert.Error(t, err)
 		})
 	}
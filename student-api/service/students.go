@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"io"
+
+	"github.com/one2n/student-api/model"
+)
+
+// Students is the set of student operations the API layer depends on.
+// StudentService implements it; CachedStudentService and
+// TracedStudentService decorate it.
+type Students interface {
+	// ForTenant returns the same operations restricted to one tenant.
+	ForTenant(tenantID string) Students
+
+	CreateStudent(ctx context.Context, student *model.Student) (*model.Student, error)
+	GetAllStudents(ctx context.Context) ([]*model.Student, error)
+	GetStudentByID(ctx context.Context, id string) (*model.Student, error)
+	UpdateStudent(ctx context.Context, id string, updatedStudent *model.Student) (*model.Student, error)
+	DeleteStudent(ctx context.Context, id string) error
+	ExportStudent(ctx context.Context, id string) (*model.StudentExport, error)
+	EraseStudent(ctx context.Context, id string) (*model.StudentTombstone, error)
+	SearchStudents(ctx context.Context, query string, limit int) ([]model.StudentSearchResult, error)
+	ListStudents(ctx context.Context, filter model.StudentFilter, limit, offset int) ([]*model.Student, int64, error)
+	GetStudentsByIDs(ctx context.Context, ids []string) ([]*model.Student, error)
+
+	ListGuardians(ctx context.Context, studentID string) ([]model.StudentGuardian, error)
+	AddGuardian(ctx context.Context, studentID string, link *model.StudentGuardian) (*model.StudentGuardian, error)
+	UpdateGuardian(ctx context.Context, studentID, guardianID string, update *model.StudentGuardian) (*model.StudentGuardian, error)
+	RemoveGuardian(ctx context.Context, studentID, guardianID string) error
+	ListGuardiansForStudents(ctx context.Context, studentIDs []string) (map[string][]model.StudentGuardian, error)
+
+	UploadFile(ctx context.Context, studentID, kind, filename string, content io.Reader) (*model.StudentFile, error)
+	ListFiles(ctx context.Context, studentID string) ([]model.StudentFile, error)
+	OpenFile(ctx context.Context, studentID, fileID string, thumbnail bool) (*model.StudentFile, io.ReadSeekCloser, error)
+	DeleteFile(ctx context.Context, studentID, fileID string) error
+
+	Grades() []string
+	NormalizeGrade(grade string) (string, error)
+	PromoteGrade(ctx context.Context, grade string, dryRun bool) (*model.PromotionBatch, error)
+	RunBatch(ctx context.Context, ops []model.BatchOperation, atomic bool) ([]BatchOutcome, error)
+
+	GradeReport(ctx context.Context, r model.ReportRange) ([]model.GradeCount, error)
+	AgeReport(ctx context.Context, r model.ReportRange) ([]model.AgeCount, error)
+	EnrollmentReport(ctx context.Context, r model.ReportRange, period string) ([]model.EnrollmentPeriod, error)
+	ChurnReport(ctx context.Context, r model.ReportRange, period string) ([]model.ChurnPeriod, error)
+}
+
+var _ Students = (*StudentService)(nil)
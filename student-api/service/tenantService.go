@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/one2n/student-api/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// apiKeyPrefix marks tenant API keys so they are easy to spot in logs and
+// secret scanners.
+const apiKeyPrefix = "sk_"
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// TenantResolver finds the tenant a request acts for.
+type TenantResolver interface {
+	ResolveTenant(ctx context.Context, ref string) (*model.Tenant, error)
+	TenantForAPIKey(ctx context.Context, key string) (*model.Tenant, error)
+}
+
+// TenantService manages tenants and their API keys. Only a hash of each key
+// is stored; the key itself is returned once, when it is issued.
+type TenantService struct {
+	db *gorm.DB
+}
+
+var _ TenantResolver = (*TenantService)(nil)
+
+func NewTenantService(db *gorm.DB) *TenantService {
+	if err := db.AutoMigrate(&model.Tenant{}); err != nil {
+		fmt.Printf("Error migrating schema: %v\n", err)
+	}
+	// Rows written before tenants existed belong to the default tenant.
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.Tenant{
+		ID:   model.DefaultTenantID,
+		Name: "Default",
+		Slug: model.DefaultTenantID,
+	}).Error
+	if err != nil {
+		fmt.Printf("Error creating default tenant: %v\n", err)
+	}
+	return &TenantService{db: db}
+}
+
+// ResolveRequestTenant picks the tenant a request acts for. An API key, when
+// present, decides the tenant; ref (a tenant ID or slug, usually from a
+// header) must then agree with it. Without a key only the default tenant can
+// be used: anyone can send a header, so ref alone never grants access to
+// another tenant.
+func ResolveRequestTenant(ctx context.Context, tenants TenantResolver, apiKey, ref string) (*model.Tenant, error) {
+	if tenants == nil {
+		return &model.Tenant{ID: model.DefaultTenantID, Slug: model.DefaultTenantID}, nil
+	}
+	if apiKey == "" {
+		if ref != "" && ref != model.DefaultTenantID {
+			return nil, ErrAPIKeyRequired
+		}
+		return tenants.ResolveTenant(ctx, model.DefaultTenantID)
+	}
+
+	tenant, err := tenants.TenantForAPIKey(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	if ref != "" && ref != tenant.ID && ref != tenant.Slug {
+		return nil, ErrTenantMismatch
+	}
+	return tenant, nil
+}
+
+func (s *TenantService) CreateTenant(ctx context.Context, tenant *model.Tenant) (*model.Tenant, error) {
+	if !slugPattern.MatchString(tenant.Slug) {
+		return nil, invalid("invalid slug %q: use lowercase letters, digits and dashes", tenant.Slug)
+	}
+	var count int64
+	if err := s.db.WithContext(ctx).Unscoped().Model(&model.Tenant{}).Where("slug = ?", tenant.Slug).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("error checking slug uniqueness: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrTenantExists
+	}
+
+	key, err := newAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	tenant.ID = uuid.New().String()
+	tenant.APIKey = key
+	tenant.APIKeyHash = hashAPIKey(key)
+	if err := s.db.WithContext(ctx).Create(tenant).Error; err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+func (s *TenantService) ListTenants(ctx context.Context) ([]*model.Tenant, error) {
+	var tenants []*model.Tenant
+	if err := s.db.WithContext(ctx).Order("created_at, id").Find(&tenants).Error; err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+func (s *TenantService) GetTenant(ctx context.Context, id string) (*model.Tenant, error) {
+	var tenant model.Tenant
+	if err := s.db.WithContext(ctx).First(&tenant, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTenantNotFound
+		}
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// ResolveTenant looks a tenant up by ID or slug.
+func (s *TenantService) ResolveTenant(ctx context.Context, ref string) (*model.Tenant, error) {
+	var tenant model.Tenant
+	if err := s.db.WithContext(ctx).Where("id = ? OR slug = ?", ref, ref).First(&tenant).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTenantNotFound
+		}
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (s *TenantService) TenantForAPIKey(ctx context.Context, key string) (*model.Tenant, error) {
+	var tenant model.Tenant
+	if err := s.db.WithContext(ctx).First(&tenant, "api_key_hash = ?", hashAPIKey(key)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// RotateAPIKey issues a new API key for a tenant. The old key stops working
+// immediately.
+func (s *TenantService) RotateAPIKey(ctx context.Context, id string) (*model.Tenant, error) {
+	tenant, err := s.GetTenant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	key, err := newAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	tenant.APIKey = key
+	tenant.APIKeyHash = hashAPIKey(key)
+	if err := s.db.WithContext(ctx).Model(tenant).Update("api_key_hash", tenant.APIKeyHash).Error; err != nil {
+		return nil, err
+	}
+	return tenant, nil
+}
+
+// DeleteTenant removes a tenant. Its students are kept but can no longer be
+// reached through the API.
+func (s *TenantService) DeleteTenant(ctx context.Context, id string) error {
+	if id == model.DefaultTenantID {
+		return invalid("the default tenant cannot be deleted")
+	}
+	result := s.db.WithContext(ctx).Delete(&model.Tenant{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTenantNotFound
+	}
+	return nil
+}
+
+func newAPIKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating API key: %w", err)
+	}
+	return apiKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/one2n/student-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantService(t *testing.T) {
+	db := setupTestDB(t)
+	tenants := NewTenantService(db)
+
+	defaultTenant, err := tenants.ResolveTenant(context.Background(), model.DefaultTenantID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.DefaultTenantID, defaultTenant.ID)
+
+	created, err := tenants.CreateTenant(context.Background(), &model.Tenant{Name: "North High", Slug: "north-high"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, created.APIKey)
+	assert.NotEqual(t, created.APIKey, created.APIKeyHash)
+
+	_, err = tenants.CreateTenant(context.Background(), &model.Tenant{Name: "North High again", Slug: "north-high"})
+	assert.ErrorIs(t, err, ErrTenantExists)
+	_, err = tenants.CreateTenant(context.Background(), &model.Tenant{Name: "Bad", Slug: "Bad Slug"})
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+
+	bySlug, err := tenants.ResolveTenant(context.Background(), "north-high")
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, bySlug.ID)
+	assert.Empty(t, bySlug.APIKey, "the API key is only returned when issued")
+
+	byKey, err := tenants.TenantForAPIKey(context.Background(), created.APIKey)
+	assert.NoError(t, err)
+	assert.Equal(t, created.ID, byKey.ID)
+
+	rotated, err := tenants.RotateAPIKey(context.Background(), created.ID)
+	assert.NoError(t, err)
+	_, err = tenants.TenantForAPIKey(context.Background(), created.APIKey)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	_, err = tenants.TenantForAPIKey(context.Background(), rotated.APIKey)
+	assert.NoError(t, err)
+
+	list, err := tenants.ListTenants(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	assert.ErrorAs(t, tenants.DeleteTenant(context.Background(), model.DefaultTenantID), &validationErr)
+	assert.NoError(t, tenants.DeleteTenant(context.Background(), created.ID))
+	assert.ErrorIs(t, tenants.DeleteTenant(context.Background(), created.ID), ErrTenantNotFound)
+	_, err = tenants.ResolveTenant(context.Background(), "north-high")
+	assert.ErrorIs(t, err, ErrTenantNotFound)
+	_, err = tenants.TenantForAPIKey(context.Background(), rotated.APIKey)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+}
+
+func TestResolveRequestTenant(t *testing.T) {
+	db := setupTestDB(t)
+	tenants := NewTenantService(db)
+	north, err := tenants.CreateTenant(context.Background(), &model.Tenant{Name: "North", Slug: "north"})
+	assert.NoError(t, err)
+	south, err := tenants.CreateTenant(context.Background(), &model.Tenant{Name: "South", Slug: "south"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		apiKey  string
+		ref     string
+		want    string
+		wantErr error
+	}{
+		{name: "nothing given", want: model.DefaultTenantID},
+		{name: "default header", ref: model.DefaultTenantID, want: model.DefaultTenantID},
+		{name: "header by slug without api key", ref: "north", wantErr: ErrAPIKeyRequired},
+		{name: "header by id without api key", ref: south.ID, wantErr: ErrAPIKeyRequired},
+		{name: "unknown header", ref: "west", wantErr: ErrAPIKeyRequired},
+		{name: "api key with id header", apiKey: south.APIKey, ref: south.ID, want: south.ID},
+		{name: "api key", apiKey: north.APIKey, want: north.ID},
+		{name: "api key with matching header", apiKey: north.APIKey, ref: "north", want: north.ID},
+		{name: "api key with other tenant header", apiKey: north.APIKey, ref: "south", wantErr: ErrTenantMismatch},
+		{name: "unknown api key", apiKey: "sk_nope", wantErr: ErrInvalidAPIKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenant, err := ResolveRequestTenant(context.Background(), tenants, tt.apiKey, tt.ref)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, tenant.ID)
+		})
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	db := setupTestDB(t)
+	base := NewStudentService(db)
+	north := base.ForTenant("north")
+	south := base.ForTenant("south")
+
+	newStudent := func(name string) *model.Student {
+		return &model.Student{
+			Name: name, Email: "shared@school.com", Age: 16, Grade: "10",
+			Guardians: []model.StudentGuardian{{
+				Relationship: model.RelationshipMother,
+				Guardian:     &model.Guardian{Name: name + "'s Mom", Phone: "+1-555-0100"},
+			}},
+		}
+	}
+
+	northStudent, err := north.CreateStudent(context.Background(), newStudent("Nora"))
+	assert.NoError(t, err)
+	southStudent, err := south.CreateStudent(context.Background(), newStudent("Sam"))
+	assert.NoError(t, err, "email is unique per tenant, not globally")
+	_, err = north.CreateStudent(context.Background(), newStudent("Nora Again"))
+	assert.ErrorIs(t, err, ErrEmailExists)
+
+	all, err := north.GetAllStudents(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+	assert.Equal(t, northStudent.ID, all[0].ID)
+	all, err = base.GetAllStudents(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, all, "the default tenant sees neither")
+
+	_, err = north.GetStudentByID(context.Background(), southStudent.ID)
+	assert.ErrorIs(t, err, ErrStudentNotFound)
+	_, err = north.UpdateStudent(context.Background(), southStudent.ID, newStudent("Hijacked"))
+	assert.ErrorIs(t, err, ErrStudentNotFound)
+	assert.ErrorIs(t, north.DeleteStudent(context.Background(), southStudent.ID), ErrStudentNotFound)
+
+	page, total, err := north.ListStudents(context.Background(), model.StudentFilter{}, 10, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Len(t, page, 1)
+	byIDs, err := north.GetStudentsByIDs(context.Background(), []string{northStudent.ID, southStudent.ID})
+	assert.NoError(t, err)
+	assert.Len(t, byIDs, 1)
+	results, err := north.SearchStudents(context.Background(), "Sam", 10)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	_, err = north.ListGuardians(context.Background(), southStudent.ID)
+	assert.ErrorIs(t, err, ErrStudentNotFound)
+	southGuardianID := southStudent.Guardians[0].GuardianID
+	_, err = north.AddGuardian(context.Background(), northStudent.ID, &model.StudentGuardian{
+		GuardianID:   southGuardianID,
+		Relationship: model.RelationshipOther,
+	})
+	assert.ErrorIs(t, err, ErrGuardianNotFound, "guardians of another tenant cannot be linked")
+
+	batch, err := north.PromoteGrade(context.Background(), "10", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, batch.StudentCount)
+	unchanged, err := south.GetStudentByID(context.Background(), southStudent.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "10", unchanged.Grade)
+
+	outcomes, err := north.RunBatch(context.Background(), []model.BatchOperation{
+		{Op: model.BatchDelete, ID: southStudent.ID},
+	}, false)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, outcomes[0].Err, ErrStudentNotFound)
+	_, err = south.GetStudentByID(context.Background(), southStudent.ID)
+	assert.NoError(t, err)
+}
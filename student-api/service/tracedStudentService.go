@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracedStudentService records a span around every operation of another
+// Students implementation. The span is the parent of the database spans the
+// operation causes, so a trace shows which call ran which queries.
+type TracedStudentService struct {
+	Students
+	tenantID string
+}
+
+func NewTracedStudentService(inner Students) *TracedStudentService {
+	return &TracedStudentService{Students: inner, tenantID: model.DefaultTenantID}
+}
+
+func (t *TracedStudentService) ForTenant(tenantID string) Students {
+	return &TracedStudentService{Students: t.Students.ForTenant(tenantID), tenantID: tenantID}
+}
+
+func (t *TracedStudentService) start(ctx context.Context, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, tracing.TenantAttribute(t.tenantID))
+	return tracing.Tracer().Start(ctx, "StudentService."+operation, trace.WithAttributes(attrs...))
+}
+
+// finish ends span, marking it failed when err is something other than the
+// caller's mistake.
+func finish(span trace.Span, err error) {
+	var validationErr *ValidationError
+	switch {
+	case err == nil:
+	case errors.As(err, &validationErr), errors.Is(err, ErrStudentNotFound), errors.Is(err, ErrGuardianNotFound),
+		errors.Is(err, ErrFileNotFound), errors.Is(err, ErrEmailExists), errors.Is(err, ErrGuardianLinked):
+		span.SetAttributes(attribute.String("error.message", err.Error()))
+	default:
+		tracing.RecordErrorClass(span, err)
+	}
+	span.End()
+}
+
+// traced runs fn inside a span named after operation.
+func traced[T any](ctx context.Context, t *TracedStudentService, operation string, fn func(context.Context) (T, error), attrs ...attribute.KeyValue) (T, error) {
+	ctx, span := t.start(ctx, operation, attrs...)
+	result, err := fn(ctx)
+	finish(span, err)
+	return result, err
+}
+
+func studentAttr(id string) attribute.KeyValue {
+	return attribute.String("student.id", id)
+}
+
+func (t *TracedStudentService) CreateStudent(ctx context.Context, student *model.Student) (*model.Student, error) {
+	return traced(ctx, t, "CreateStudent", func(ctx context.Context) (*model.Student, error) {
+		return t.Students.CreateStudent(ctx, student)
+	})
+}
+
+func (t *TracedStudentService) GetAllStudents(ctx context.Context) ([]*model.Student, error) {
+	return traced(ctx, t, "GetAllStudents", t.Students.GetAllStudents)
+}
+
+func (t *TracedStudentService) GetStudentByID(ctx context.Context, id string) (*model.Student, error) {
+	return traced(ctx, t, "GetStudentByID", func(ctx context.Context) (*model.Student, error) {
+		return t.Students.GetStudentByID(ctx, id)
+	}, studentAttr(id))
+}
+
+func (t *TracedStudentService) UpdateStudent(ctx context.Context, id string, updatedStudent *model.Student) (*model.Student, error) {
+	return traced(ctx, t, "UpdateStudent", func(ctx context.Context) (*model.Student, error) {
+		return t.Students.UpdateStudent(ctx, id, updatedStudent)
+	}, studentAttr(id))
+}
+
+func (t *TracedStudentService) DeleteStudent(ctx context.Context, id string) error {
+	_, err := traced(ctx, t, "DeleteStudent", func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, t.Students.DeleteStudent(ctx, id)
+	}, studentAttr(id))
+	return err
+}
+
+func (t *TracedStudentService) ExportStudent(ctx context.Context, id string) (*model.StudentExport, error) {
+	return traced(ctx, t, "ExportStudent", func(ctx context.Context) (*model.StudentExport, error) {
+		return t.Students.ExportStudent(ctx, id)
+	}, studentAttr(id))
+}
+
+func (t *TracedStudentService) EraseStudent(ctx context.Context, id string) (*model.StudentTombstone, error) {
+	return traced(ctx, t, "EraseStudent", func(ctx context.Context) (*model.StudentTombstone, error) {
+		return t.Students.EraseStudent(ctx, id)
+	}, studentAttr(id))
+}
+
+// SearchStudents leaves the query out of the span; it is usually a name.
+func (t *TracedStudentService) SearchStudents(ctx context.Context, query string, limit int) ([]model.StudentSearchResult, error) {
+	return traced(ctx, t, "SearchStudents", func(ctx context.Context) ([]model.StudentSearchResult, error) {
+		return t.Students.SearchStudents(ctx, query, limit)
+	}, attribute.Int("limit", limit))
+}
+
+func (t *TracedStudentService) ListStudents(ctx context.Context, filter model.StudentFilter, limit, offset int) ([]*model.Student, int64, error) {
+	ctx, span := t.start(ctx, "ListStudents", attribute.Int("limit", limit), attribute.Int("offset", offset))
+	students, total, err := t.Students.ListStudents(ctx, filter, limit, offset)
+	finish(span, err)
+	return students, total, err
+}
+
+func (t *TracedStudentService) GetStudentsByIDs(ctx context.Context, ids []string) ([]*model.Student, error) {
+	return traced(ctx, t, "GetStudentsByIDs", func(ctx context.Context) ([]*model.Student, error) {
+		return t.Students.GetStudentsByIDs(ctx, ids)
+	}, attribute.Int("student.count", len(ids)))
+}
+
+func (t *TracedStudentService) ListGuardians(ctx context.Context, studentID string) ([]model.StudentGuardian, error) {
+	return traced(ctx, t, "ListGuardians", func(ctx context.Context) ([]model.StudentGuardian, error) {
+		return t.Students.ListGuardians(ctx, studentID)
+	}, studentAttr(studentID))
+}
+
+func (t *TracedStudentService) AddGuardian(ctx context.Context, studentID string, link *model.StudentGuardian) (*model.StudentGuardian, error) {
+	return traced(ctx, t, "AddGuardian", func(ctx context.Context) (*model.StudentGuardian, error) {
+		return t.Students.AddGuardian(ctx, studentID, link)
+	}, studentAttr(studentID))
+}
+
+func (t *TracedStudentService) UpdateGuardian(ctx context.Context, studentID, guardianID string, update *model.StudentGuardian) (*model.StudentGuardian, error) {
+	return traced(ctx, t, "UpdateGuardian", func(ctx context.Context) (*model.StudentGuardian, error) {
+		return t.Students.UpdateGuardian(ctx, studentID, guardianID, update)
+	}, studentAttr(studentID), attribute.String("guardian.id", guardianID))
+}
+
+func (t *TracedStudentService) RemoveGuardian(ctx context.Context, studentID, guardianID string) error {
+	_, err := traced(ctx, t, "RemoveGuardian", func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, t.Students.RemoveGuardian(ctx, studentID, guardianID)
+	}, studentAttr(studentID), attribute.String("guardian.id", guardianID))
+	return err
+}
+
+func (t *TracedStudentService) ListGuardiansForStudents(ctx context.Context, studentIDs []string) (map[string][]model.StudentGuardian, error) {
+	return traced(ctx, t, "ListGuardiansForStudents", func(ctx context.Context) (map[string][]model.StudentGuardian, error) {
+		return t.Students.ListGuardiansForStudents(ctx, studentIDs)
+	}, attribute.Int("student.count", len(studentIDs)))
+}
+
+func (t *TracedStudentService) UploadFile(ctx context.Context, studentID, kind, filename string, content io.Reader) (*model.StudentFile, error) {
+	return traced(ctx, t, "UploadFile", func(ctx context.Context) (*model.StudentFile, error) {
+		return t.Students.UploadFile(ctx, studentID, kind, filename, content)
+	}, studentAttr(studentID), attribute.String("file.kind", kind))
+}
+
+func (t *TracedStudentService) ListFiles(ctx context.Context, studentID string) ([]model.StudentFile, error) {
+	return traced(ctx, t, "ListFiles", func(ctx context.Context) ([]model.StudentFile, error) {
+		return t.Students.ListFiles(ctx, studentID)
+	}, studentAttr(studentID))
+}
+
+// OpenFile covers finding the file; the download itself happens after the
+// span ends.
+func (t *TracedStudentService) OpenFile(ctx context.Context, studentID, fileID string, thumbnail bool) (*model.StudentFile, io.ReadSeekCloser, error) {
+	ctx, span := t.start(ctx, "OpenFile", studentAttr(studentID), attribute.String("file.id", fileID))
+	file, content, err := t.Students.OpenFile(ctx, studentID, fileID, thumbnail)
+	finish(span, err)
+	return file, content, err
+}
+
+func (t *TracedStudentService) DeleteFile(ctx context.Context, studentID, fileID string) error {
+	_, err := traced(ctx, t, "DeleteFile", func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, t.Students.DeleteFile(ctx, studentID, fileID)
+	}, studentAttr(studentID), attribute.String("file.id", fileID))
+	return err
+}
+
+func (t *TracedStudentService) PromoteGrade(ctx context.Context, grade string, dryRun bool) (*model.PromotionBatch, error) {
+	return traced(ctx, t, "PromoteGrade", func(ctx context.Context) (*model.PromotionBatch, error) {
+		return t.Students.PromoteGrade(ctx, grade, dryRun)
+	}, attribute.String("grade", grade), attribute.Bool("dry_run", dryRun))
+}
+
+func (t *TracedStudentService) RunBatch(ctx context.Context, ops []model.BatchOperation, atomic bool) ([]BatchOutcome, error) {
+	return traced(ctx, t, "RunBatch", func(ctx context.Context) ([]BatchOutcome, error) {
+		return t.Students.RunBatch(ctx, ops, atomic)
+	}, attribute.Int("batch.size", len(ops)), attribute.Bool("batch.atomic", atomic))
+}
+
+func (t *TracedStudentService) GradeReport(ctx context.Context, r model.ReportRange) ([]model.GradeCount, error) {
+	return traced(ctx, t, "GradeReport", func(ctx context.Context) ([]model.GradeCount, error) {
+		return t.Students.GradeReport(ctx, r)
+	})
+}
+
+func (t *TracedStudentService) AgeReport(ctx context.Context, r model.ReportRange) ([]model.AgeCount, error) {
+	return traced(ctx, t, "AgeReport", func(ctx context.Context) ([]model.AgeCount, error) {
+		return t.Students.AgeReport(ctx, r)
+	})
+}
+
+func (t *TracedStudentService) EnrollmentReport(ctx context.Context, r model.ReportRange, period string) ([]model.EnrollmentPeriod, error) {
+	return traced(ctx, t, "EnrollmentReport", func(ctx context.Context) ([]model.EnrollmentPeriod, error) {
+		return t.Students.EnrollmentReport(ctx, r, period)
+	}, attribute.String("report.period", period))
+}
+
+func (t *TracedStudentService) ChurnReport(ctx context.Context, r model.ReportRange, period string) ([]model.ChurnPeriod, error) {
+	return traced(ctx, t, "ChurnReport", func(ctx context.Context) ([]model.ChurnPeriod, error) {
+		return t.Students.ChurnReport(ctx, r, period)
+	}, attribute.String("report.period", period))
+}
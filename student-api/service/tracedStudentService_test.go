@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracedStudentService(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown := tracing.Install(exporter, "test")
+	t.Cleanup(func() { shutdown(context.Background()) })
+
+	db := setupTestDB(t)
+	assert.NoError(t, db.Use(tracing.GormPlugin{}))
+	publisher := &recordingPublisher{}
+	cfg := DefaultConfig()
+	cfg.Publisher = publisher
+	service := NewTracedStudentService(NewStudentServiceWithConfig(db, cfg)).ForTenant(model.DefaultTenantID)
+
+	ctx, request := tracing.Tracer().Start(context.Background(), "request")
+	created, err := service.CreateStudent(ctx, &model.Student{Name: "Ann", Email: "ann@example.com", Age: 20, Grade: "10"})
+	assert.NoError(t, err)
+	_, err = service.GetStudentByID(ctx, "missing")
+	assert.ErrorIs(t, err, ErrStudentNotFound)
+	request.End()
+
+	var create, get *tracetest.SpanStub
+	spans := exporter.GetSpans()
+	for i := range spans {
+		switch spans[i].Name {
+		case "StudentService.CreateStudent":
+			create = &spans[i]
+		case "StudentService.GetStudentByID":
+			get = &spans[i]
+		}
+	}
+	if assert.NotNil(t, create) && assert.NotNil(t, get) {
+		assert.Equal(t, request.SpanContext().SpanID(), create.Parent.SpanID())
+		assert.Equal(t, codes.Unset, get.Status.Code, "a missing student is not a failed span")
+
+		queries := 0
+		for _, span := range spans {
+			if span.Parent.SpanID() == create.SpanContext.SpanID() {
+				queries++
+			}
+		}
+		assert.Positive(t, queries, "queries are children of the service span")
+	}
+
+	if assert.Len(t, publisher.events, 1) {
+		assert.Equal(t, created.ID, publisher.events[0].StudentID)
+		assert.Contains(t, publisher.events[0].TraceParent, request.SpanContext().TraceID().String())
+	}
+}
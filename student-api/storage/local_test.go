@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocal(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocal(filepath.Join(root, "files"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Put("tenant/student/file", strings.NewReader("hello world")))
+	assert.NoError(t, store.Put("tenant/student/file", strings.NewReader("hello again")))
+
+	f, err := store.Open("tenant/student/file")
+	assert.NoError(t, err)
+	_, err = f.Seek(6, io.SeekStart)
+	assert.NoError(t, err)
+	rest, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "again", string(rest))
+	assert.NoError(t, f.Close())
+
+	entries, err := os.ReadDir(filepath.Join(root, "files", "tenant", "student"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files are left behind")
+
+	assert.NoError(t, store.Delete("tenant/student/file"))
+	_, err = store.Open("tenant/student/file")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Delete("tenant/student/file"), ErrNotFound)
+
+	for _, key := range []string{"../outside", "/etc/passwd", ""} {
+		assert.Error(t, store.Put(key, strings.NewReader("x")), key)
+	}
+}
@@ -0,0 +1,23 @@
+// Package storage provides the blob stores that hold uploaded files.
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned by Open and Delete for keys that hold nothing.
+var ErrNotFound = errors.New("stored file not found")
+
+// Store keeps file contents under slash-separated keys. Its shape follows
+// object stores such as S3 so a networked backend can be swapped in for the
+// local filesystem.
+type Store interface {
+	// Put stores everything read from r under key, replacing any previous
+	// content. Readers never see a partially written file.
+	Put(key string, r io.Reader) error
+	// Open returns the content stored under key. It is seekable so downloads
+	// can serve byte ranges.
+	Open(key string) (io.ReadSeekCloser, error)
+	Delete(key string) error
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/events"
+	"github.com/one2n/student-api/middleware"
+	"github.com/one2n/student-api/pii"
+)
+
+// streamStudentsHandler serves GET /students/stream as Server-Sent Events.
+// Clients resume with the Last-Event-ID header (or last_event_id query
+// parameter); if that ID is no longer buffered a "resync" event tells them to
+// refetch. A comment line is sent every keepAlive to hold the connection open
+// through proxies. Only changes to the request's tenant are sent, with that
+// tenant's event IDs, masked for the caller's role.
+func streamStudentsHandler(broker *events.Broker, keepAlive time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lastID := c.GetHeader("Last-Event-ID")
+		if lastID == "" {
+			lastID = c.Query("last_event_id")
+		}
+		var lastEventID uint64
+		if lastID != "" {
+			var err error
+			if lastEventID, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+				c.String(http.StatusBadRequest, "invalid Last-Event-ID")
+				return
+			}
+		}
+
+		tenantID := middleware.TenantID(c)
+		sub := broker.Subscribe(tenantID, lastEventID)
+		defer sub.Close()
+		log.Printf("Student stream opened from %s (last event %d, %d subscribers)", c.ClientIP(), lastEventID, broker.Subscribers())
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+
+		if sub.Resync {
+			writeEvent(c, sse.Event{Event: "resync", Data: gin.H{"reason": "events since Last-Event-ID are no longer available"}})
+		}
+		for _, event := range sub.Replay {
+			writeStudentEvent(c, event)
+		}
+		c.Writer.Flush()
+
+		ticker := time.NewTicker(keepAlive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				log.Printf("Student stream closed by %s", c.ClientIP())
+				return
+			case event, ok := <-sub.Events:
+				if !ok {
+					log.Printf("Student stream to %s dropped: subscriber fell behind or server shutting down", c.ClientIP())
+					return
+				}
+				writeStudentEvent(c, event)
+			case <-ticker.C:
+				fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeStudentEvent(c *gin.Context, event events.Event) {
+	event.Student = pii.PolicyFromContext(c.Request.Context()).Student(event.Student)
+	writeEvent(c, sse.Event{
+		Id:    strconv.FormatUint(event.ID, 10),
+		Event: event.Type,
+		Data:  event,
+	})
+}
+
+func writeEvent(c *gin.Context, event sse.Event) {
+	if err := sse.Encode(c.Writer, event); err != nil {
+		log.Printf("Failed to write stream event: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/pii"
+)
+
+const (
+	mimeNDJSON  = "application/x-ndjson"
+	mimeMsgPack = "application/msgpack"
+)
+
+// studentFormats are the types the student list and get endpoints answer
+// in, the default first.
+var studentFormats = []string{binding.MIMEJSON, mimeCSV, mimeNDJSON, mimeMsgPack, binding.MIMEMSGPACK}
+
+// csvWriter is a csv.Writer for files meant to be opened in a spreadsheet,
+// which would run a cell starting with =, +, - or @ (or a tab or carriage
+// return) as a formula. Such cells are prefixed with a quote, which makes
+// them plain text; numbers, negative ones included, are left alone.
+type csvWriter struct {
+	*csv.Writer
+}
+
+func newCSVWriter(w io.Writer) csvWriter {
+	return csvWriter{csv.NewWriter(w)}
+}
+
+func (w csvWriter) Write(record []string) error {
+	escaped := make([]string, len(record))
+	for i, cell := range record {
+		escaped[i] = escapeCSVCell(cell)
+	}
+	return w.Writer.Write(escaped)
+}
+
+func (w csvWriter) WriteAll(records [][]string) error {
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func escapeCSVCell(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return cell
+	}
+	if _, err := strconv.ParseFloat(cell, 64); err == nil {
+		return cell
+	}
+	return "'" + cell
+}
+
+// studentCSVHeader names the columns of studentCSVRow.
+var studentCSVHeader = []string{"id", "name", "email", "age", "grade", "status", "created_at"}
+
+func studentCSVRow(student *model.Student) []string {
+	return []string{
+		student.ID,
+		student.Name,
+		student.Email,
+		strconv.Itoa(student.Age),
+		student.Grade,
+		student.Status,
+		student.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// negotiateStudentFormat picks the type to answer in from the Accept header.
+// When it names none of studentFormats it answers 406 and returns "".
+func negotiateStudentFormat(c *gin.Context) string {
+	c.Writer.Header().Add("Vary", "Accept")
+	format := c.NegotiateFormat(studentFormats...)
+	if format == "" {
+		c.JSON(http.StatusNotAcceptable, model.StudentResponse{
+			Success: false,
+			Message: fmt.Sprintf("cannot answer in %s; available types are %s", c.GetHeader("Accept"), strings.Join(studentFormats, ", ")),
+		})
+	}
+	return format
+}
+
+// renderStudents writes students in format, as returned by
+// negotiateStudentFormat. With single the response is the one student
+// rather than a list. JSON is masked by the MaskPII middleware; the other
+// formats are masked here.
+func renderStudents(c *gin.Context, format string, students []*model.Student, single bool) {
+	if format == binding.MIMEJSON {
+		var data any = students
+		if single {
+			data = students[0]
+		}
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Data:    data,
+		})
+		return
+	}
+
+	policy := pii.PolicyFromContext(c.Request.Context())
+	masked := make([]*model.Student, len(students))
+	for i, student := range students {
+		masked[i] = policy.Student(student)
+	}
+
+	switch format {
+	case mimeCSV:
+		c.Header("Content-Type", mimeCSV+"; charset=utf-8")
+		c.Status(http.StatusOK)
+		w := newCSVWriter(c.Writer)
+		w.Write(studentCSVHeader)
+		for _, student := range masked {
+			w.Write(studentCSVRow(student))
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			log.Printf("Failed to write students as CSV: %v", err)
+		}
+	case mimeNDJSON:
+		c.Header("Content-Type", mimeNDJSON)
+		c.Status(http.StatusOK)
+		encoder := json.NewEncoder(c.Writer)
+		for _, student := range masked {
+			if err := encoder.Encode(student); err != nil {
+				log.Printf("Failed to write students as NDJSON: %v", err)
+				return
+			}
+		}
+	default:
+		var data any = masked
+		if single {
+			data = masked[0]
+		}
+		c.Header("Content-Type", format)
+		c.Render(http.StatusOK, render.MsgPack{Data: model.StudentResponse{
+			Success: true,
+			Data:    data,
+		}})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/one2n/student-api/middleware"
+	"github.com/one2n/student-api/model"
+	"github.com/one2n/student-api/service"
+)
+
+// requestTenantID returns the tenant resolved for the request.
+func requestTenantID(c *gin.Context) string {
+	if tenantID := middleware.TenantID(c); tenantID != "" {
+		return tenantID
+	}
+	return model.DefaultTenantID
+}
+
+// tenantStudents returns the student operations of the tenant resolved for
+// the request.
+func tenantStudents(c *gin.Context, studentService service.Students) service.Students {
+	return studentService.ForTenant(requestTenantID(c))
+}
+
+// requireAdmin only lets through requests carrying adminAPIKey as a bearer
+// token.
+func requireAdmin(adminAPIKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := middleware.BearerToken(c)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminAPIKey)) != 1 {
+			log.Printf("Rejected admin request from %s", c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, model.StudentResponse{
+				Success: false,
+				Message: "admin API key required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func registerTenantRoutes(admin *gin.RouterGroup, tenants *service.TenantService) {
+	admin.POST("/tenants", func(c *gin.Context) {
+		log.Printf("Creating tenant - Request from %s", c.ClientIP())
+		var tenant model.Tenant
+		if err := c.ShouldBindJSON(&tenant); err != nil {
+			log.Printf("Invalid tenant data: %v", err)
+			c.JSON(http.StatusBadRequest, model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		created, err := tenants.CreateTenant(c.Request.Context(), &tenant)
+		if err != nil {
+			log.Printf("Failed to create tenant: %v", err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		log.Printf("Successfully created tenant %s (%s)", created.ID, created.Slug)
+		c.JSON(http.StatusCreated, model.StudentResponse{
+			Success: true,
+			Data:    created,
+		})
+	})
+
+	admin.GET("/tenants", func(c *gin.Context) {
+		list, err := tenants.ListTenants(c.Request.Context())
+		if err != nil {
+			log.Printf("Failed to fetch tenants: %v", err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Data:    list,
+		})
+	})
+
+	admin.GET("/tenants/:id", func(c *gin.Context) {
+		tenant, err := tenants.GetTenant(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Data:    tenant,
+		})
+	})
+
+	admin.POST("/tenants/:id/api-key", func(c *gin.Context) {
+		id := c.Param("id")
+		log.Printf("Rotating API key of tenant %s - Request from %s", id, c.ClientIP())
+		tenant, err := tenants.RotateAPIKey(c.Request.Context(), id)
+		if err != nil {
+			log.Printf("Failed to rotate API key of tenant %s: %v", id, err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Data:    tenant,
+		})
+	})
+
+	admin.DELETE("/tenants/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		log.Printf("Deleting tenant %s - Request from %s", id, c.ClientIP())
+		if err := tenants.DeleteTenant(c.Request.Context(), id); err != nil {
+			log.Printf("Failed to delete tenant %s: %v", id, err)
+			c.JSON(errorStatus(err), model.StudentResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		log.Printf("Successfully deleted tenant %s", id)
+		c.JSON(http.StatusOK, model.StudentResponse{
+			Success: true,
+			Message: "Tenant deleted successfully",
+		})
+	})
+}
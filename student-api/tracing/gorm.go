@@ -0,0 +1,170 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+// GormPlugin records a client span for every query run with a context that
+// carries a span, as a child of that span; queries outside a traced request,
+// such as migrations, are not recorded. The span carries the statement with
+// its literals replaced by "?", and failures only by ErrorClass, so student
+// data never reaches the tracing backend.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	type register func(name string, fn func(*gorm.DB)) error
+	callbacks := []struct {
+		operation     string
+		before, after register
+	}{
+		{"create", db.Callback().Create().Before("gorm:create").Register, db.Callback().Create().After("gorm:create").Register},
+		{"query", db.Callback().Query().Before("gorm:query").Register, db.Callback().Query().After("gorm:query").Register},
+		{"update", db.Callback().Update().Before("gorm:update").Register, db.Callback().Update().After("gorm:update").Register},
+		{"delete", db.Callback().Delete().Before("gorm:delete").Register, db.Callback().Delete().After("gorm:delete").Register},
+		{"row", db.Callback().Row().Before("gorm:row").Register, db.Callback().Row().After("gorm:row").Register},
+		{"raw", db.Callback().Raw().Before("gorm:raw").Register, db.Callback().Raw().After("gorm:raw").Register},
+	}
+	for _, cb := range callbacks {
+		if err := cb.before("tracing:before_"+cb.operation, startSpan(cb.operation)); err != nil {
+			return err
+		}
+		if err := cb.after("tracing:after_"+cb.operation, endSpan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startSpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+		attrs := []attribute.KeyValue{dbSystem(db), semconv.DBOperationName(operation)}
+		if db.Statement.Table != "" {
+			attrs = append(attrs, semconv.DBCollectionName(db.Statement.Table))
+		}
+		ctx, span := Tracer().Start(ctx, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+		db.Statement.Context = ctx
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func endSpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+
+	if statement := db.Statement.SQL.String(); statement != "" {
+		span.SetAttributes(semconv.DBQueryText(SanitizeSQL(statement)))
+	}
+	span.SetAttributes(attribute.Int64("db.rows_affected", db.Statement.RowsAffected))
+	if err := db.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		RecordErrorClass(span, err)
+	}
+}
+
+// RecordErrorClass marks span failed with the class of err rather than its
+// message: driver messages can quote the values of the row, such as the key
+// of a unique violation, and with them student data.
+func RecordErrorClass(span trace.Span, err error) {
+	class := ErrorClass(err)
+	span.SetAttributes(semconv.ErrorTypeKey.String(class))
+	span.SetStatus(codes.Error, class)
+}
+
+// ErrorClass names the kind of err without its details: the SQLSTATE of a
+// Postgres error, the extended result code of a SQLite one, and the Go type
+// of anything else.
+func ErrorClass(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return "SQLSTATE " + pgErr.Code
+	}
+	if code, ok := sqliteCode(err); ok {
+		return fmt.Sprintf("SQLite %d", code)
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "context canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "context deadline exceeded"
+	case errors.Is(err, driver.ErrBadConn):
+		return "bad connection"
+	}
+	return fmt.Sprintf("%T", err)
+}
+
+// sqliteCode returns the extended result code of the go-sqlite3 error in
+// the chain of err. The driver's types only exist when built with cgo, so the
+// error is recognised by its package and decoded through its exported fields,
+// as gorm's SQLite dialector does.
+func sqliteCode(err error) (int, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		t := reflect.TypeOf(err)
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.PkgPath() != "github.com/mattn/go-sqlite3" {
+			continue
+		}
+		encoded, jsonErr := json.Marshal(err)
+		if jsonErr != nil {
+			return 0, false
+		}
+		var fields struct{ ExtendedCode int }
+		if json.Unmarshal(encoded, &fields) != nil {
+			return 0, false
+		}
+		return fields.ExtendedCode, true
+	}
+	return 0, false
+}
+
+func dbSystem(db *gorm.DB) attribute.KeyValue {
+	switch name := db.Dialector.Name(); name {
+	case "postgres":
+		return semconv.DBSystemPostgreSQL
+	case "sqlite":
+		return semconv.DBSystemSqlite
+	default:
+		return semconv.DBSystemKey.String(name)
+	}
+}
+
+var (
+	stringLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numericLiteral = regexp.MustCompile(`([^\w$.]|^)-?\d+(?:\.\d+)?\b`)
+)
+
+// SanitizeSQL replaces the string and numeric literals in statement with
+// "?". Values bound to placeholders are never part of the statement, but
+// raw SQL and LIMIT clauses can still carry literals.
+func SanitizeSQL(statement string) string {
+	statement = stringLiteral.ReplaceAllString(statement, "?")
+	return numericLiteral.ReplaceAllString(statement, "${1}?")
+}
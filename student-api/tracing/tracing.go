@@ -0,0 +1,95 @@
+// Package tracing sets up OpenTelemetry tracing and instruments the database
+// layer. Spans are recorded through the global tracer provider, so code that
+// is not traced simply produces no-op spans.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporter names accepted by NewExporter.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterMemory = "memory"
+)
+
+// instrumentationName identifies the spans this service creates.
+const instrumentationName = "github.com/one2n/student-api"
+
+// Tracer returns the tracer the service creates its spans with.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// NewExporter builds the span exporter called name. OTLP sends spans over
+// HTTP to the collector named by the standard OTEL_EXPORTER_OTLP_* variables,
+// localhost:4318 by default; stdout prints them; memory keeps them for tests.
+// ExporterNone returns a nil exporter.
+func NewExporter(ctx context.Context, name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case ExporterNone, "":
+		return nil, nil
+	case ExporterOTLP:
+		return otlptracehttp.New(ctx)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterMemory:
+		return tracetest.NewInMemoryExporter(), nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", name)
+	}
+}
+
+// Install makes exporter the destination of every span and W3C trace context
+// the propagation format. It returns a function that flushes buffered spans
+// and stops the provider. With a nil exporter spans are still propagated but
+// not recorded.
+func Install(exporter sdktrace.SpanExporter, serviceName string) func(context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if exporter == nil {
+		return func(context.Context) error { return nil }
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		res = resource.Default()
+	}
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	if _, ok := exporter.(*tracetest.InMemoryExporter); ok {
+		// Tests look at spans as soon as they end.
+		opts = append(opts, sdktrace.WithSyncer(exporter))
+	} else {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown
+}
+
+// TraceParent returns the W3C traceparent header value for the span in ctx,
+// or "" when ctx carries no span.
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// TenantAttribute labels a span with the tenant it acted for.
+func TenantAttribute(tenantID string) attribute.KeyValue {
+	return attribute.String("tenant.id", tenantID)
+}
@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// installMemory records spans in memory for the duration of the test.
+func installMemory(t *testing.T) *tracetest.InMemoryExporter {
+	exporter, err := NewExporter(context.Background(), ExporterMemory)
+	assert.NoError(t, err)
+	shutdown := Install(exporter, "test")
+	t.Cleanup(func() { shutdown(context.Background()) })
+	return exporter.(*tracetest.InMemoryExporter)
+}
+
+func attributeOf(attrs []attribute.KeyValue, key attribute.Key) string {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return attr.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestNewExporter(t *testing.T) {
+	exporter, err := NewExporter(context.Background(), ExporterNone)
+	assert.NoError(t, err)
+	assert.Nil(t, exporter)
+
+	exporter, err = NewExporter(context.Background(), ExporterStdout)
+	assert.NoError(t, err)
+	assert.NotNil(t, exporter)
+
+	_, err = NewExporter(context.Background(), "jaeger")
+	assert.ErrorContains(t, err, "unknown trace exporter")
+}
+
+func TestTraceParent(t *testing.T) {
+	installMemory(t)
+	assert.Empty(t, TraceParent(context.Background()))
+
+	ctx, span := Tracer().Start(context.Background(), "test")
+	defer span.End()
+	parent := TraceParent(ctx)
+	assert.Contains(t, parent, span.SpanContext().TraceID().String())
+	assert.Contains(t, parent, span.SpanContext().SpanID().String())
+}
+
+func TestSanitizeSQL(t *testing.T) {
+	tests := map[string]string{
+		`SELECT * FROM "students" WHERE email_index = $1 LIMIT 1`:            `SELECT * FROM "students" WHERE email_index = $1 LIMIT ?`,
+		`SELECT * FROM students WHERE name = 'Ann O''Neil' AND age > 17`:     `SELECT * FROM students WHERE name = ? AND age > ?`,
+		`UPDATE students SET grade = '11' WHERE id IN (?,?)`:                 `UPDATE students SET grade = ? WHERE id IN (?,?)`,
+		`SELECT count(*) FROM "promotion_records" WHERE batch_id = 3.5`:      `SELECT count(*) FROM "promotion_records" WHERE batch_id = ?`,
+		`CREATE INDEX idx_students_v2 ON students USING gin (search_vector)`: `CREATE INDEX idx_students_v2 ON students USING gin (search_vector)`,
+	}
+	for statement, want := range tests {
+		assert.Equal(t, want, SanitizeSQL(statement))
+	}
+}
+
+func TestGormPlugin(t *testing.T) {
+	exporter := installMemory(t)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Use(GormPlugin{}))
+
+	type Note struct {
+		ID   uint
+		Text string
+	}
+	assert.NoError(t, db.AutoMigrate(&Note{}))
+	assert.NoError(t, db.Create(&Note{Text: "untraced"}).Error)
+	assert.Empty(t, exporter.GetSpans(), "queries outside a span are not recorded")
+
+	ctx, parent := Tracer().Start(context.Background(), "request")
+	assert.NoError(t, db.WithContext(ctx).Create(&Note{Text: "secret"}).Error)
+	var note Note
+	assert.Error(t, db.WithContext(ctx).Raw("SELECT * FROM missing WHERE text = 'secret'").Scan(&note).Error)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 3)
+	create, row := spans[0], spans[1]
+	assert.Equal(t, "gorm.create", create.Name)
+	assert.Equal(t, parent.SpanContext().SpanID(), create.Parent.SpanID())
+	assert.Equal(t, "sqlite", attributeOf(create.Attributes, semconv.DBSystemKey))
+	assert.Equal(t, "notes", attributeOf(create.Attributes, "db.collection.name"))
+	assert.NotContains(t, attributeOf(create.Attributes, "db.query.text"), "secret")
+
+	assert.Equal(t, "gorm.row", row.Name)
+	assert.Equal(t, "SELECT * FROM missing WHERE text = ?", attributeOf(row.Attributes, "db.query.text"))
+	assert.Equal(t, "Error", row.Status.Code.String())
+	assert.Equal(t, "SQLite 1", row.Status.Description)
+	assert.NotContains(t, row.Status.Description, "missing", "the driver's message is not recorded")
+	assert.Empty(t, row.Events)
+}
+
+func TestErrorClass(t *testing.T) {
+	unique := &pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint",
+		Detail: "Key (email_index)=(john@doe.com) already exists."}
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("creating student: %w", unique), "SQLSTATE 23505"},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}, "SQLite 2067"},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), "context deadline exceeded"},
+		{errors.New("anything at all"), "*errors.errorString"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ErrorClass(tt.err))
+	}
+}